@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"backend/models"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+
+	"github.com/gorilla/sessions"
+)
+
+const (
+	sessionUserIDKey      = "userID"
+	sessionFingerprintKey = "fingerprint"
+)
+
+// Fingerprint changes with the password hash, so changing the password signs out the other sessions.
+// The session cookie is only signed, it contains the fingerprint instead of the hash.
+func Fingerprint(u *models.User) string {
+	sum := sha256.Sum256([]byte(u.Password))
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+// StoreUserInSession signs the user in.
+func StoreUserInSession(sess *sessions.Session, u *models.User) {
+	sess.Values[sessionUserIDKey] = u.ID
+	sess.Values[sessionFingerprintKey] = Fingerprint(u)
+}
+
+// UserIDFromSession returns the id of the signed in user and the fingerprint of the session.
+func UserIDFromSession(sess *sessions.Session) (int, string, bool) {
+	id, ok1 := sess.Values[sessionUserIDKey].(int)
+	fingerprint, ok2 := sess.Values[sessionFingerprintKey].(string)
+	return id, fingerprint, ok1 && ok2
+}
+
+// MatchesSession reports whether the fingerprint from the session belongs to the current password of the user.
+func MatchesSession(u *models.User, fingerprint string) bool {
+	return subtle.ConstantTimeCompare([]byte(Fingerprint(u)), []byte(fingerprint)) == 1
+}
+
+// ClearSession signs the user out.
+func ClearSession(sess *sessions.Session) {
+	delete(sess.Values, sessionUserIDKey)
+	delete(sess.Values, sessionFingerprintKey)
+}
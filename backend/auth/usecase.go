@@ -7,7 +7,8 @@ import (
 )
 
 type Usecase interface {
-	Signup(ctx context.Context, input models.UserInput) (*models.User, error)
+	// Signup creates the account, inviteCode is optional unless the registration is disabled.
+	Signup(ctx context.Context, input models.UserInput, inviteCode string) (*models.User, error)
 	Signin(ctx context.Context, login, password string) (*models.User, error)
 	GenerateNewActivationToken(ctx context.Context, id int) (*models.User, error)
 	Activate(ctx context.Context, id int, token string) (*models.User, error)
@@ -2,12 +2,20 @@ package usecase
 
 import (
 	"backend/auth"
+	"backend/breach"
 	_errors "backend/errors"
+	_i18n "backend/i18n"
+	"backend/invitation"
+	"backend/middleware"
 	"backend/models"
+	"backend/outbox"
+	"backend/postgres"
 	"backend/user"
 	"backend/user/validation"
 	"backend/utils"
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -16,41 +24,79 @@ import (
 	"github.com/sethvargo/go-password/password"
 )
 
+const (
+	// generatedPasswordLength is the length of the passwords set by ResetPassword unless the policy doesn't allow it
+	generatedPasswordLength       = 16
+	generatedPasswordDigits       = 4
+	generatedPasswordSymbols      = 4
+	maxPasswordGenerationAttempts = 10
+)
+
 type Config struct {
-	UserRepo                        user.Repository
+	UserRepo   user.Repository
+	UserEvents user.Events
+	OutboxRepo outbox.Repository
+	// InvitationRepo is needed to sign up with invite codes.
+	InvitationRepo invitation.Repository
+	// Transactor makes the token updates and the enqueued emails atomic, it is optional.
+	Transactor                      postgres.Transactor
 	PasswordGenerator               password.PasswordGenerator
+	FrontendURL                     string
 	IntervalBetweenTokensGeneration int
 	ResetPasswordTokenExpiresIn     int
 	RegistrationDisabled            bool
+	// PasswordPolicy is validation.DefaultPasswordPolicy when nil, the generated passwords satisfy it.
+	PasswordPolicy *validation.PasswordPolicy
+	// BreachChecker rejects the breached passwords, it is optional.
+	BreachChecker breach.Checker
 }
 
 type usecase struct {
 	userRepo                        user.Repository
+	userEvents                      user.Events
+	outboxRepo                      outbox.Repository
+	invitationRepo                  invitation.Repository
+	transactor                      postgres.Transactor
 	generator                       password.PasswordGenerator
+	frontendURL                     string
 	logrus                          *logrus.Entry
 	intervalBetweenTokensGeneration int
 	resetPasswordTokenExpiresIn     int
 	registrationDisabled            bool
+	passwordPolicy                  validation.PasswordPolicy
+	breachChecker                   breach.Checker
 }
 
 func NewAuthUsecase(cfg Config) auth.Usecase {
 	if cfg.PasswordGenerator == nil {
 		cfg.PasswordGenerator, _ = password.NewGenerator(nil)
 	}
+	passwordPolicy := validation.DefaultPasswordPolicy()
+	if cfg.PasswordPolicy != nil {
+		passwordPolicy = *cfg.PasswordPolicy
+	}
 	return &usecase{
 		cfg.UserRepo,
+		cfg.UserEvents,
+		cfg.OutboxRepo,
+		cfg.InvitationRepo,
+		cfg.Transactor,
 		cfg.PasswordGenerator,
+		cfg.FrontendURL,
 		logrus.WithField("package", "auth/usecase"),
 		cfg.IntervalBetweenTokensGeneration,
 		cfg.ResetPasswordTokenExpiresIn,
 		cfg.RegistrationDisabled,
+		passwordPolicy,
+		cfg.BreachChecker,
 	}
 }
 
-func (ucase *usecase) Signup(ctx context.Context, input models.UserInput) (*models.User, error) {
-	entry := ucase.logrus.WithField("input", input)
+func (ucase *usecase) Signup(ctx context.Context, input models.UserInput, inviteCode string) (*models.User, error) {
+	entry := ucase.logrus.WithField("input", input).WithField("inviteCode", inviteCode)
 	entry.Debug("Signup")
-	if ucase.registrationDisabled {
+	inviteCode = strings.TrimSpace(inviteCode)
+	if ucase.registrationDisabled && inviteCode == "" {
 		entry.Debug("Signup - registration disabled")
 		return nil, _errors.Wrap(_errors.ErrRegistrationDisabled)
 	}
@@ -59,17 +105,81 @@ func (ucase *usecase) Signup(ctx context.Context, input models.UserInput) (*mode
 	activated := false
 	u.Activated = &activated
 	u.ActivationToken = uuid.New().String()
+	u.Locale = _i18n.MatchLanguage(middleware.LanguageFromContext(ctx))
 	cfg := validation.NewConfig()
+	cfg.PasswordPolicy = &ucase.passwordPolicy
 	if err := cfg.Validate(u); err != nil {
 		entry.Debugf("Signup - Cannot create user: %s", err.Error())
 		return nil, err
 	}
-	if err := ucase.userRepo.Store(ctx, &u); err != nil {
+	if err := breach.Validate(ctx, ucase.breachChecker, u.Password); err != nil {
+		entry.Debugf("Signup - Cannot create user: %s", err.Error())
+		return nil, err
+	}
+	if err := ucase.withinTx(ctx, func(ctx context.Context) error {
+		var inv *models.Invitation
+		if inviteCode != "" {
+			var err error
+			if inv, err = ucase.redeemInvitation(ctx, inviteCode, &u); err != nil {
+				entry.Debugf("Signup - Cannot redeem invitation: %s", err.Error())
+				return err
+			}
+		}
+		if err := ucase.userRepo.Store(ctx, &u); err != nil {
+			return err
+		}
+		if inv != nil {
+			if err := ucase.invitationRepo.StoreRedemption(ctx, &models.InvitationRedemption{
+				InvitationID: inv.ID,
+				UserID:       u.ID,
+			}); err != nil {
+				return err
+			}
+		}
+		if *u.Activated {
+			return nil
+		}
+		return ucase.enqueueActivationEmail(ctx, &u)
+	}); err != nil {
 		return nil, err
 	}
+	if ucase.userEvents != nil {
+		if err := ucase.userEvents.PublishUserCreated(ctx, &u); err != nil {
+			entry.Debugf("Signup - Cannot publish event: %s", err.Error())
+		}
+	}
 	return &u, nil
 }
 
+// redeemInvitation checks the invitation, counts the use and applies its role to the user.
+// Invitations sent to the email address activate the account, the address is confirmed.
+func (ucase *usecase) redeemInvitation(ctx context.Context, code string, u *models.User) (*models.Invitation, error) {
+	invalid := _errors.WrapField(_errors.ErrInvalidInviteCode, "inviteCode", nil)
+	if ucase.invitationRepo == nil {
+		return nil, invalid
+	}
+	inv, err := ucase.invitationRepo.GetByCode(ctx, code)
+	if err != nil {
+		if _errors.Code(_errors.ToGqlError(err)) == _errors.ErrInvitationNotFound {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	if err := inv.Check(u.Email, time.Now()); err != nil {
+		return nil, err
+	}
+	inv.Uses++
+	if err := ucase.invitationRepo.Update(ctx, inv); err != nil {
+		return nil, err
+	}
+	u.Role = inv.Role
+	if inv.Email != "" {
+		activated := true
+		u.Activated = &activated
+	}
+	return inv, nil
+}
+
 func (ucase *usecase) Signin(ctx context.Context, login, password string) (*models.User, error) {
 	ucase.logrus.WithField("password", password).WithField("login", login).Debug("Sign in")
 	return ucase.userRepo.GetByCredentials(ctx, login, password)
@@ -91,7 +201,15 @@ func (ucase *usecase) GenerateNewActivationToken(ctx context.Context, id int) (*
 	}
 	u.ActivationToken = uuid.New().String()
 	u.ActivationTokenGeneratedAt = now
-	return u, ucase.userRepo.Update(ctx, u)
+	if err := ucase.withinTx(ctx, func(ctx context.Context) error {
+		if err := ucase.userRepo.Update(ctx, u); err != nil {
+			return err
+		}
+		return ucase.enqueueActivationEmail(ctx, u)
+	}); err != nil {
+		return nil, err
+	}
+	return u, nil
 }
 
 func (ucase *usecase) Activate(ctx context.Context, id int, token string) (*models.User, error) {
@@ -110,6 +228,7 @@ func (ucase *usecase) Activate(ctx context.Context, id int, token string) (*mode
 		if err := ucase.userRepo.Update(ctx, u); err != nil {
 			return nil, err
 		}
+		ucase.publishAccountChanged(ctx, u, models.AccountEventTypeActivated)
 		return u, nil
 	}
 	entry.Debug("Activate - Wrong activation token.")
@@ -129,7 +248,14 @@ func (ucase *usecase) GenerateNewResetPasswordToken(ctx context.Context, email s
 	}
 	u.ResetPasswordToken = uuid.New().String()
 	u.ResetPasswordTokenGeneratedAt = time.Now()
-	if err := ucase.userRepo.Update(ctx, u); err != nil {
+	if err := ucase.withinTx(ctx, func(ctx context.Context) error {
+		if err := ucase.userRepo.Update(ctx, u); err != nil {
+			return err
+		}
+		return ucase.enqueueEmail(ctx, u, outbox.ResetPasswordTemplate, map[string]interface{}{
+			"Href": fmt.Sprintf("%s/%d/reset-password/%s", ucase.frontendURL, u.ID, u.ResetPasswordToken),
+		})
+	}); err != nil {
 		return nil, err
 	}
 	return u, nil
@@ -147,18 +273,142 @@ func (ucase *usecase) ResetPassword(ctx context.Context, id int, token string) (
 			entry.Debug("ResetPassword - Reset password token expired.")
 			return nil, "", _errors.Wrap(_errors.ErrTokenExpired)
 		}
-		pswd := ucase.generator.MustGenerate(16, 4, 4, false, false)
-		u.Password = pswd
+		pswd, err := ucase.generatePassword(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := u.SetPassword(pswd); err != nil {
+			return nil, "", err
+		}
 		u.ResetPasswordToken = uuid.New().String()
-		if err := ucase.userRepo.Update(ctx, u); err != nil {
+		if err := ucase.withinTx(ctx, func(ctx context.Context) error {
+			if err := ucase.userRepo.Update(ctx, u); err != nil {
+				return err
+			}
+			return ucase.enqueueEmail(ctx, u, outbox.PasswordChangedTemplate, map[string]interface{}{
+				"Password": pswd,
+			})
+		}); err != nil {
 			return nil, "", err
 		}
+		ucase.publishAccountChanged(ctx, u, models.AccountEventTypeLoggedOut)
 		return u, pswd, nil
 	}
 	entry.Debug("ResetPassword - Wrong reset password token")
 	return u, "", _errors.Wrap(_errors.ErrWrongResetPasswordToken)
 }
 
+// generatePassword returns a random password, a breached one is only possible with false positives of the index.
+func (ucase *usecase) generatePassword(ctx context.Context) (string, error) {
+	var err error
+	for attempt := 0; attempt < maxPasswordGenerationAttempts; attempt++ {
+		pswd, genErr := newPassword(ucase.generator, ucase.passwordPolicy)
+		if genErr != nil {
+			ucase.logrus.Errorf("generatePassword - Cannot generate password: %s", genErr.Error())
+			return "", _errors.Wrap(_errors.ErrInternalServerError, genErr)
+		}
+		if err = breach.Validate(ctx, ucase.breachChecker, pswd); err == nil {
+			return pswd, nil
+		}
+	}
+	return "", err
+}
+
+// newPassword returns a random password which satisfies the policy. The letters are random, so a short
+// password can lack the uppercase or the lowercase ones and is generated again.
+func newPassword(generator password.PasswordGenerator, policy validation.PasswordPolicy) (string, error) {
+	var err error
+	for attempt := 0; attempt < maxPasswordGenerationAttempts; attempt++ {
+		// the repeats are allowed, the generator has too few letters for the long passwords without them
+		pswd, genErr := generator.Generate(fitGeneratedPasswordLength(policy),
+			generatedPasswordDigits, generatedPasswordSymbols, false, true)
+		if genErr != nil {
+			return "", genErr
+		}
+		errs := policy.Validate(models.User{Password: pswd})
+		if len(errs) == 0 {
+			return pswd, nil
+		}
+		err = fmt.Errorf("the generated passwords don't satisfy the policy: %s", errs.Error())
+	}
+	return "", err
+}
+
+// CheckPasswordPolicy returns an error when the passwords generated by ResetPassword can't satisfy the policy.
+func CheckPasswordPolicy(policy validation.PasswordPolicy) error {
+	length := fitGeneratedPasswordLength(policy)
+	if length < generatedPasswordDigits+generatedPasswordSymbols {
+		return fmt.Errorf("the generated passwords have %d digits and %d symbols, the maximum length %d is too short",
+			generatedPasswordDigits, generatedPasswordSymbols, length)
+	}
+	generator, err := password.NewGenerator(nil)
+	if err != nil {
+		return err
+	}
+	_, err = newPassword(generator, policy)
+	return err
+}
+
+// fitGeneratedPasswordLength fits the length of the generated passwords to the password policy.
+func fitGeneratedPasswordLength(policy validation.PasswordPolicy) int {
+	length := generatedPasswordLength
+	if length < policy.MinLength {
+		length = policy.MinLength
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		length = policy.MaxLength
+	}
+	return length
+}
+
+func (ucase *usecase) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ucase.transactor == nil {
+		return fn(ctx)
+	}
+	return ucase.transactor.WithinTx(ctx, fn)
+}
+
+func (ucase *usecase) enqueueActivationEmail(ctx context.Context, u *models.User) error {
+	return ucase.enqueueEmail(ctx, u, outbox.ActivateAccountTemplate, map[string]interface{}{
+		"Href": fmt.Sprintf("%s/%d/activate/%s", ucase.frontendURL, u.ID, u.ActivationToken),
+	})
+}
+
+// enqueueEmail adds the email to the outbox, it is localized to the locale of the user
+// or, if it isn't set, to the language of the request.
+func (ucase *usecase) enqueueEmail(ctx context.Context, u *models.User, template string, data map[string]interface{}) error {
+	if ucase.outboxRepo == nil {
+		return nil
+	}
+	data["Login"] = u.Login
+	lang := u.Locale
+	if lang == "" {
+		lang = middleware.LanguageFromContext(ctx)
+	}
+	return ucase.outboxRepo.Enqueue(ctx, &models.Email{
+		To:       u.Email,
+		Template: template,
+		Language: lang,
+		Data:     data,
+	})
+}
+
+func (ucase *usecase) publishAccountChanged(ctx context.Context, u *models.User, t models.AccountEventType) {
+	if ucase.userEvents == nil {
+		return
+	}
+	entry := ucase.logrus.WithField("id", u.ID).WithField("type", t)
+	if err := ucase.userEvents.PublishUserUpdated(ctx, u); err != nil {
+		entry.Debugf("Cannot publish user updated event: %s", err.Error())
+	}
+	if err := ucase.userEvents.PublishAccountChanged(ctx, &models.AccountEvent{
+		Type: t,
+		User: u,
+	}); err != nil {
+		entry.Debugf("Cannot publish account changed event: %s", err.Error())
+	}
+}
+
 func isProperInterval(a, b time.Time, interval int) bool {
 	year, month, day, hour, min, _ := utils.DateDifference(a, b)
 	return year == 0 &&
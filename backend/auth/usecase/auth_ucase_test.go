@@ -0,0 +1,308 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/email"
+	_errors "backend/errors"
+	_invitationRepository "backend/invitation/repository"
+	"backend/models"
+	"backend/outbox"
+	_outboxRepository "backend/outbox/repository"
+	"backend/outbox/worker"
+	"backend/user/repository"
+	"backend/user/validation"
+	"backend/utils/testutil"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+// memoryOutbox keeps the enqueued emails, the other methods aren't used by the usecase.
+type memoryOutbox struct {
+	outbox.Repository
+	mu     sync.Mutex
+	emails []*models.Email
+}
+
+func (o *memoryOutbox) Enqueue(ctx context.Context, e *models.Email) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.emails = append(o.emails, e)
+	return nil
+}
+
+func (o *memoryOutbox) last() *models.Email {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.emails) == 0 {
+		return nil
+	}
+	return o.emails[len(o.emails)-1]
+}
+
+// serialTransactor runs one transaction at a time, it stands for the lock which GetByCode of the postgres
+// repository holds on the invitation until the transaction ends.
+type serialTransactor struct {
+	mu sync.Mutex
+}
+
+func (tr *serialTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return fn(ctx)
+}
+
+func TestAuthUsecase(t *testing.T) {
+	ctx := context.Background()
+	newUcase := func(cfg Config) (*usecase, *memoryOutbox) {
+		out := &memoryOutbox{}
+		cfg.UserRepo = repository.NewMemoryUserRepository()
+		cfg.OutboxRepo = out
+		cfg.FrontendURL = "http://frontend"
+		cfg.IntervalBetweenTokensGeneration = 5
+		cfg.ResetPasswordTokenExpiresIn = 30
+		return NewAuthUsecase(cfg).(*usecase), out
+	}
+	input := models.UserInput{Login: "john", Password: "Password123", Email: "john@example.com"}
+
+	t.Run("Signup", func(t *testing.T) {
+		ucase, out := newUcase(Config{})
+		u, err := ucase.Signup(ctx, input, "")
+		require.Equal(t, nil, err)
+		require.Equal(t, false, *u.Activated)
+		require.Equal(t, models.UserDefaultRole, u.Role)
+		require.NotEqual(t, "", u.ActivationToken)
+
+		e := out.last()
+		require.Equal(t, outbox.ActivateAccountTemplate, e.Template)
+		require.Equal(t, "john@example.com", e.To)
+		require.Equal(t, fmt.Sprintf("http://frontend/%d/activate/%s", u.ID, u.ActivationToken), e.Data["Href"])
+
+		// the password is stored hashed
+		_, err = ucase.Signin(ctx, "john", "Password123")
+		require.Equal(t, nil, err)
+
+		_, err = ucase.Signup(ctx, models.UserInput{Login: "John", Password: "Password123", Email: "other@example.com"}, "")
+		require.Equal(t, _errors.ErrLoginMustBeUnique, testutil.ErrorCode(err))
+		_, err = ucase.Signup(ctx, models.UserInput{Login: "other", Password: "Password123", Email: "other"}, "")
+		require.Equal(t, _errors.ErrEmailPolicy, testutil.ErrorCode(err))
+		require.Equal(t, 1, len(out.emails))
+	})
+
+	t.Run("Signup with the registration disabled", func(t *testing.T) {
+		ucase, _ := newUcase(Config{RegistrationDisabled: true})
+		_, err := ucase.Signup(ctx, input, "")
+		require.Equal(t, _errors.ErrRegistrationDisabled, testutil.ErrorCode(err))
+		_, err = ucase.Signup(ctx, input, "CODE")
+		require.Equal(t, _errors.ErrInvalidInviteCode, testutil.ErrorCode(err))
+	})
+
+	t.Run("Signup with an invite code", func(t *testing.T) {
+		ucase, out := newUcase(Config{RegistrationDisabled: true, InvitationRepo: _invitationRepository.NewMemoryInvitationRepository()})
+		inv := &models.Invitation{Code: "SHARED", Role: models.UserAdminRole, MaxUses: 2}
+		require.Equal(t, nil, ucase.invitationRepo.Store(ctx, inv))
+
+		u, err := ucase.Signup(ctx, input, " shared ")
+		require.Equal(t, nil, err)
+		require.Equal(t, models.UserAdminRole, u.Role)
+		require.Equal(t, false, *u.Activated)
+		require.Equal(t, outbox.ActivateAccountTemplate, out.last().Template)
+		stored, err := ucase.invitationRepo.GetByCode(ctx, "SHARED")
+		require.Equal(t, nil, err)
+		require.Equal(t, 1, stored.Uses)
+		redemptions, err := ucase.invitationRepo.GetRedemptions(ctx, inv.ID)
+		require.Equal(t, nil, err)
+		require.Equal(t, 1, len(redemptions))
+		require.Equal(t, u.ID, redemptions[0].UserID)
+
+		_, err = ucase.Signup(ctx, models.UserInput{Login: "jane", Password: "Password123", Email: "jane@example.com"}, "SHARED")
+		require.Equal(t, nil, err)
+		// the code is used up
+		_, err = ucase.Signup(ctx, models.UserInput{Login: "bob", Password: "Password123", Email: "bob@example.com"}, "SHARED")
+		require.Equal(t, _errors.ErrInviteCodeUsedUp, testutil.ErrorCode(err))
+		stored, err = ucase.invitationRepo.GetByCode(ctx, "SHARED")
+		require.Equal(t, nil, err)
+		require.Equal(t, 2, stored.Uses)
+		redemptions, err = ucase.invitationRepo.GetRedemptions(ctx, inv.ID)
+		require.Equal(t, nil, err)
+		require.Equal(t, 2, len(redemptions))
+
+		_, err = ucase.Signup(ctx, models.UserInput{Login: "bob", Password: "Password123", Email: "bob@example.com"}, "UNKNOWN")
+		require.Equal(t, _errors.ErrInvalidInviteCode, testutil.ErrorCode(err))
+	})
+
+	t.Run("Signup with an expired invite code", func(t *testing.T) {
+		ucase, _ := newUcase(Config{RegistrationDisabled: true, InvitationRepo: _invitationRepository.NewMemoryInvitationRepository()})
+		expiredAt := time.Now().Add(-time.Minute)
+		inv := &models.Invitation{Code: "EXPIRED", Role: models.UserDefaultRole, MaxUses: 1, ExpiresAt: &expiredAt}
+		require.Equal(t, nil, ucase.invitationRepo.Store(ctx, inv))
+
+		_, err := ucase.Signup(ctx, input, "EXPIRED")
+		require.Equal(t, _errors.ErrInviteCodeExpired, testutil.ErrorCode(err))
+		stored, err := ucase.invitationRepo.GetByCode(ctx, "EXPIRED")
+		require.Equal(t, nil, err)
+		require.Equal(t, 0, stored.Uses)
+		redemptions, err := ucase.invitationRepo.GetRedemptions(ctx, inv.ID)
+		require.Equal(t, nil, err)
+		require.Equal(t, 0, len(redemptions))
+	})
+
+	t.Run("Signup with an invite code sent to the email", func(t *testing.T) {
+		ucase, out := newUcase(Config{RegistrationDisabled: true, InvitationRepo: _invitationRepository.NewMemoryInvitationRepository()})
+		require.Equal(t, nil, ucase.invitationRepo.Store(ctx, &models.Invitation{Code: "PERSONAL", Email: "John@Example.com", Role: models.UserDefaultRole, MaxUses: 1}))
+
+		_, err := ucase.Signup(ctx, models.UserInput{Login: "jane", Password: "Password123", Email: "jane@example.com"}, "PERSONAL")
+		require.Equal(t, _errors.ErrInviteCodeEmailMismatch, testutil.ErrorCode(err))
+		// the address is confirmed by the invitation
+		u, err := ucase.Signup(ctx, input, "PERSONAL")
+		require.Equal(t, nil, err)
+		require.Equal(t, true, *u.Activated)
+		require.Equal(t, true, out.last() == nil)
+	})
+
+	t.Run("Signup with a one-use invite code at the same time", func(t *testing.T) {
+		ucase, _ := newUcase(Config{
+			RegistrationDisabled: true,
+			InvitationRepo:       _invitationRepository.NewMemoryInvitationRepository(),
+			Transactor:           &serialTransactor{},
+		})
+		inv := &models.Invitation{Code: "ONCE", Role: models.UserDefaultRole, MaxUses: 1}
+		require.Equal(t, nil, ucase.invitationRepo.Store(ctx, inv))
+
+		errs := make([]error, 2)
+		var wg sync.WaitGroup
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				login := fmt.Sprintf("user%d", i)
+				_, errs[i] = ucase.Signup(ctx, models.UserInput{Login: login, Password: "Password123", Email: login + "@example.com"}, "ONCE")
+			}(i)
+		}
+		wg.Wait()
+
+		codes := []string{testutil.ErrorCode(errs[0]), testutil.ErrorCode(errs[1])}
+		require.ElementsMatch(t, []string{"", _errors.ErrInviteCodeUsedUp}, codes)
+		stored, err := ucase.invitationRepo.GetByCode(ctx, "ONCE")
+		require.Equal(t, nil, err)
+		require.Equal(t, 1, stored.Uses)
+		redemptions, err := ucase.invitationRepo.GetRedemptions(ctx, inv.ID)
+		require.Equal(t, nil, err)
+		require.Equal(t, 1, len(redemptions))
+	})
+
+	t.Run("Activate", func(t *testing.T) {
+		ucase, _ := newUcase(Config{})
+		u, err := ucase.Signup(ctx, input, "")
+		require.Equal(t, nil, err)
+
+		_, err = ucase.Activate(ctx, u.ID, "wrong")
+		require.Equal(t, _errors.ErrWrongActivationToken, testutil.ErrorCode(err))
+		activated, err := ucase.Activate(ctx, u.ID, u.ActivationToken)
+		require.Equal(t, nil, err)
+		require.Equal(t, true, *activated.Activated)
+		stored, err := ucase.userRepo.GetByID(ctx, u.ID)
+		require.Equal(t, nil, err)
+		require.Equal(t, true, *stored.Activated)
+
+		_, err = ucase.Activate(ctx, u.ID, u.ActivationToken)
+		require.Equal(t, _errors.ErrUnauthorized, testutil.ErrorCode(err))
+		_, err = ucase.Activate(ctx, u.ID+1, u.ActivationToken)
+		require.Equal(t, _errors.ErrUserNotFound, testutil.ErrorCode(err))
+	})
+
+	t.Run("ResetPassword", func(t *testing.T) {
+		policy := validation.DefaultPasswordPolicy()
+		ucase, out := newUcase(Config{PasswordPolicy: &policy})
+		u, err := ucase.Signup(ctx, input, "")
+		require.Equal(t, nil, err)
+
+		// the time of the token generation defaults to the time of the signup
+		require.Equal(t, nil, ucase.userRepo.UpdateColumns(ctx, &models.User{
+			ID:                            u.ID,
+			ResetPasswordTokenGeneratedAt: time.Now().Add(-time.Hour),
+		}, "reset_password_token_generated_at"))
+		u, err = ucase.GenerateNewResetPasswordToken(ctx, "JOHN@example.com")
+		require.Equal(t, nil, err)
+		require.Equal(t, fmt.Sprintf("http://frontend/%d/reset-password/%s", u.ID, u.ResetPasswordToken), out.last().Data["Href"])
+		_, err = ucase.GenerateNewResetPasswordToken(ctx, "john@example.com")
+		require.Equal(t, _errors.ErrResetPasswordTokenHasBeenGeneratedRecently, testutil.ErrorCode(err))
+
+		_, _, err = ucase.ResetPassword(ctx, u.ID, "wrong")
+		require.Equal(t, _errors.ErrWrongResetPasswordToken, testutil.ErrorCode(err))
+		reset, pswd, err := ucase.ResetPassword(ctx, u.ID, u.ResetPasswordToken)
+		require.Equal(t, nil, err)
+		require.Equal(t, 0, len(policy.Validate(models.User{Password: pswd})))
+		require.Equal(t, outbox.PasswordChangedTemplate, out.last().Template)
+		require.Equal(t, pswd, out.last().Data["Password"])
+
+		_, err = ucase.Signin(ctx, "john", "Password123")
+		require.Equal(t, _errors.ErrInvalidCredentials, testutil.ErrorCode(err))
+		_, err = ucase.Signin(ctx, "john", pswd)
+		require.Equal(t, nil, err)
+
+		// the token can be used once
+		_, _, err = ucase.ResetPassword(ctx, u.ID, u.ResetPasswordToken)
+		require.Equal(t, _errors.ErrWrongResetPasswordToken, testutil.ErrorCode(err))
+		require.NotEqual(t, u.ResetPasswordToken, reset.ResetPasswordToken)
+	})
+}
+
+func TestSignupEmail(t *testing.T) {
+	ctx := context.Background()
+	transport := email.NewMemoryTransport()
+	mailer := email.New(email.Config{Transport: transport, Address: "noreply@example.com"})
+	require.Equal(t, nil, mailer.LoadTemplates("../../email/templates"))
+	outboxRepo := _outboxRepository.NewMemoryOutboxRepository()
+	ucase := NewAuthUsecase(Config{
+		UserRepo:    repository.NewMemoryUserRepository(),
+		OutboxRepo:  outboxRepo,
+		FrontendURL: "http://frontend",
+	})
+	w := worker.New(worker.Config{OutboxRepo: outboxRepo, Mailer: mailer, PollInterval: time.Millisecond})
+	w.Start()
+	defer w.Shutdown(ctx)
+
+	u, err := ucase.Signup(ctx, models.UserInput{Login: "john", Password: "Password123", Email: "john@example.com"}, "")
+	require.Equal(t, nil, err)
+	deadline := time.Now().Add(time.Second)
+	for e, _ := outboxRepo.GetByID(ctx, 1); e.Status != models.EmailStatusSent; e, _ = outboxRepo.GetByID(ctx, 1) {
+		require.Equal(t, true, time.Now().Before(deadline), "the email is not sent")
+		time.Sleep(time.Millisecond)
+	}
+
+	messages := transport.Messages()
+	require.Equal(t, 1, len(messages))
+	require.Equal(t, []string{"john@example.com"}, messages[0].Message.GetHeader("To"))
+	require.Equal(t, []string{"Account activation"}, messages[0].Message.GetHeader("Subject"))
+	// the long lines of the quoted-printable body are broken with soft line breaks
+	body := strings.ReplaceAll(string(messages[0].Raw), "=\r\n", "")
+	require.Contains(t, body, fmt.Sprintf("http://frontend/%d/activate/%s", u.ID, u.ActivationToken))
+}
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	require.Equal(t, nil, CheckPasswordPolicy(validation.DefaultPasswordPolicy()))
+
+	policy := validation.DefaultPasswordPolicy()
+	policy.MaxLength = 7
+	require.NotEqual(t, nil, CheckPasswordPolicy(policy))
+
+	// longer than the letters of the generator
+	policy = validation.DefaultPasswordPolicy()
+	policy.MinLength, policy.MaxLength = 100, 128
+	require.Equal(t, nil, CheckPasswordPolicy(policy))
+	require.Equal(t, 100, fitGeneratedPasswordLength(policy))
+
+	policy = validation.DefaultPasswordPolicy()
+	policy.MinScore = validation.MaximumPasswordScore + 1
+	require.NotEqual(t, nil, CheckPasswordPolicy(policy))
+}
@@ -0,0 +1,145 @@
+package avatar
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+)
+
+const (
+	// MaxPixels limits the dimensions of the uploaded images, so decoding cannot exhaust the memory.
+	MaxPixels   = 25000000
+	ContentType = "image/jpeg"
+	Extension   = ".jpg"
+	quality     = 85
+)
+
+// Sizes are the widths of the square avatars, from the smallest.
+var Sizes = []int{32, 64, 128, 256}
+
+var (
+	ErrUnsupportedFormat = errors.New("avatar: unsupported image format")
+	ErrTooManyPixels     = errors.New("avatar: image has too many pixels")
+)
+
+var supportedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Process sniffs the content type, decodes the image, crops it to a square
+// and returns it resized to all Sizes, encoded as JPEG.
+func Process(r io.Reader) (map[int][]byte, error) {
+	br := bufio.NewReaderSize(r, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if !supportedTypes[http.DetectContentType(head)] {
+		return nil, ErrUnsupportedFormat
+	}
+	var buf bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(br, &buf))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+	img, _, err := image.Decode(io.MultiReader(&buf, br))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	square := cropSquare(img)
+	result := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		var out bytes.Buffer
+		if err := jpeg.Encode(&out, resize(square, size), &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		result[size] = out.Bytes()
+	}
+	return result, nil
+}
+
+// Size returns the smallest size which is not smaller than the requested one.
+func Size(requested int) int {
+	for _, size := range Sizes {
+		if size >= requested {
+			return size
+		}
+	}
+	return Sizes[len(Sizes)-1]
+}
+
+// Key returns the storage key of the avatar in the given size, prefix is stored on the user.
+func Key(prefix string, size int) string {
+	return fmt.Sprintf("%s/%d%s", prefix, size, Extension)
+}
+
+// cropSquare returns the centered square of the image drawn on a white background,
+// JPEG has no transparency.
+func cropSquare(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	min := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(square, square.Bounds(), img, min, draw.Over)
+	return square
+}
+
+// resize scales the square image with the box filter, each destination pixel
+// is the average of the source pixels it covers.
+func resize(src *image.RGBA, size int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	srcSize := src.Bounds().Dx()
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, size, srcSize)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, size, srcSize)
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// span returns the source pixels covered by the destination pixel, at least one.
+func span(i, dstSize, srcSize int) (int, int) {
+	start := i * srcSize / dstSize
+	end := (i + 1) * srcSize / dstSize
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
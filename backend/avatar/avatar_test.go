@@ -0,0 +1,50 @@
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcess(t *testing.T) {
+	t.Run("crops and resizes the image", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 600, 300))
+		for x := 0; x < 600; x++ {
+			for y := 0; y < 300; y++ {
+				img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			}
+		}
+		var buf bytes.Buffer
+		require.Equal(t, nil, png.Encode(&buf, img))
+
+		result, err := Process(&buf)
+		require.Equal(t, nil, err)
+		require.Equal(t, len(Sizes), len(result))
+		for _, size := range Sizes {
+			decoded, err := jpeg.Decode(bytes.NewReader(result[size]))
+			require.Equal(t, nil, err)
+			require.Equal(t, image.Rect(0, 0, size, size), decoded.Bounds())
+			r, g, _, _ := decoded.At(size/2, size/2).RGBA()
+			require.Equal(t, true, r > 0xf000 && g < 0x1000)
+		}
+	})
+
+	t.Run("rejects other content", func(t *testing.T) {
+		_, err := Process(strings.NewReader("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"))
+		require.Equal(t, ErrUnsupportedFormat, err)
+		_, err = Process(strings.NewReader("\x89PNG\r\n\x1a\nbroken"))
+		require.Equal(t, ErrUnsupportedFormat, err)
+	})
+
+	t.Run("size", func(t *testing.T) {
+		require.Equal(t, 32, Size(1))
+		require.Equal(t, 128, Size(100))
+		require.Equal(t, 256, Size(1000))
+	})
+}
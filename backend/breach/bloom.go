@@ -0,0 +1,133 @@
+package breach
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+const (
+	// bloomMagic starts the Bloom filter files, it is followed by k, m and the bits
+	bloomMagic      = "PWNBLOOM"
+	bloomHeaderSize = len(bloomMagic) + 4 + 8
+)
+
+// BloomFilter is the compact index of the breached passwords. It never misses a breached password,
+// but other passwords are reported as breached with the false positive rate chosen when it is built.
+type BloomFilter struct {
+	k    uint32
+	m    uint64
+	bits []byte
+}
+
+// NewBloomFilter allocates the filter for n hashes with the false positive rate p.
+func NewBloomFilter(n uint64, p float64) *BloomFilter {
+	if n == 0 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint32(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BloomFilter{k, m, make([]byte, (m+7)/8)}
+}
+
+func (f *BloomFilter) Add(hash [sha1.Size]byte) {
+	for _, position := range positions(hash, f.k, f.m) {
+		f.bits[position/8] |= 1 << (position % 8)
+	}
+}
+
+func (f *BloomFilter) Test(hash [sha1.Size]byte) bool {
+	for _, position := range positions(hash, f.k, f.m) {
+		if f.bits[position/8]&(1<<(position%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, bloomHeaderSize)
+	copy(header, bloomMagic)
+	binary.BigEndian.PutUint32(header[len(bloomMagic):], f.k)
+	binary.BigEndian.PutUint64(header[len(bloomMagic)+4:], f.m)
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	written, err := w.Write(f.bits)
+	return int64(n + written), err
+}
+
+// positions uses the double hashing, the SHA-1 hash is already uniformly distributed.
+func positions(hash [sha1.Size]byte, k uint32, m uint64) []uint64 {
+	h1 := binary.BigEndian.Uint64(hash[0:8])
+	h2 := binary.BigEndian.Uint64(hash[8:16]) | 1
+	result := make([]uint64, k)
+	for i := range result {
+		result[i] = (h1 + uint64(i)*h2) % m
+	}
+	return result
+}
+
+type bloomChecker struct {
+	file *os.File
+	k    uint32
+	m    uint64
+}
+
+// OpenBloomChecker returns the checker of the Bloom filter file, the bits are read from the disk
+// so the filter doesn't have to fit in the memory.
+func OpenBloomChecker(path string) (Checker, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, bloomHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil || string(header[:len(bloomMagic)]) != bloomMagic {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a Bloom filter of breached passwords", path)
+	}
+	c := &bloomChecker{
+		file: f,
+		k:    binary.BigEndian.Uint32(header[len(bloomMagic):]),
+		m:    binary.BigEndian.Uint64(header[len(bloomMagic)+4:]),
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if c.k == 0 || info.Size() != int64(bloomHeaderSize)+int64((c.m+7)/8) {
+		f.Close()
+		return nil, fmt.Errorf("%s is truncated", path)
+	}
+	return c, nil
+}
+
+func (c *bloomChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	hash := sha1.Sum([]byte(password))
+	b := make([]byte, 1)
+	for _, position := range positions(hash, c.k, c.m) {
+		if _, err := c.file.ReadAt(b, int64(bloomHeaderSize)+int64(position/8)); err != nil {
+			return false, err
+		}
+		if b[0]&(1<<(position%8)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (c *bloomChecker) Close() error {
+	return c.file.Close()
+}
@@ -0,0 +1,139 @@
+// Package breach checks passwords against the Have I Been Pwned dataset of breached passwords
+// without the network, either in the k-anonymity range files or in a Bloom filter built from them.
+package breach
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_errors "backend/errors"
+)
+
+const (
+	// PrefixLength is the number of hex characters of the SHA-1 hash which name a range file.
+	PrefixLength = 5
+	hashLength   = sha1.Size * 2
+)
+
+// Checker reports whether passwords appeared in data breaches.
+type Checker interface {
+	IsBreached(ctx context.Context, password string) (bool, error)
+	Close() error
+}
+
+// Open returns the checker of the range directory or the Bloom filter file built by cmd/buildbreachindex.
+func Open(path string) (Checker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return NewRangeChecker(path), nil
+	}
+	return OpenBloomChecker(path)
+}
+
+// Validate returns the error of the password field when the password is breached, the checker is optional.
+func Validate(ctx context.Context, checker Checker, password string) error {
+	if checker == nil || password == "" {
+		return nil
+	}
+	breached, err := checker.IsBreached(ctx, password)
+	if err != nil {
+		return _errors.Wrap(_errors.ErrInternalServerError, err)
+	}
+	if breached {
+		return _errors.WrapField(_errors.ErrPasswordBreached, "password", nil)
+	}
+	return nil
+}
+
+// Hash returns the SHA-1 hash of the password in the uppercase hex like in the dataset.
+func Hash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// ReadHashes calls fn with every hash of the dataset, path is either the file with HASH:COUNT lines
+// or the directory of range files named by the hash prefix with SUFFIX:COUNT lines.
+func ReadHashes(path string, fn func(hash [sha1.Size]byte, count int) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return readFile(path, "", fn)
+	}
+	files, err := filepath.Glob(filepath.Join(path, "*"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		prefix := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if len(prefix) != PrefixLength {
+			continue
+		}
+		if err := readFile(file, strings.ToUpper(prefix), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(path, prefix string, fn func(hash [sha1.Size]byte, count int) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return scan(f, func(line string) error {
+		hash, count, err := parseLine(prefix + line)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err.Error())
+		}
+		return fn(hash, count)
+	})
+}
+
+// scan calls fn with the non-empty lines without the trailing whitespace.
+func scan(r io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseLine parses HASH:COUNT, the count is optional.
+func parseLine(line string) ([sha1.Size]byte, int, error) {
+	var hash [sha1.Size]byte
+	count := 1
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		var err error
+		if count, err = strconv.Atoi(line[i+1:]); err != nil {
+			return hash, 0, fmt.Errorf("invalid count in %q", line)
+		}
+		line = line[:i]
+	}
+	if len(line) != hashLength {
+		return hash, 0, fmt.Errorf("invalid hash %q", line)
+	}
+	if _, err := hex.Decode(hash[:], []byte(line)); err != nil {
+		return hash, 0, fmt.Errorf("invalid hash %q", line)
+	}
+	return hash, count, nil
+}
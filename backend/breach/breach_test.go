@@ -0,0 +1,92 @@
+package breach
+
+import (
+	"context"
+	"crypto/sha1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_errors "backend/errors"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreach(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "breach")
+	require.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	require.Equal(t, "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8", Hash("password"))
+	breached := []string{"password", "123456", "qwerty", "Żółw123"}
+	lines := []string{}
+	for _, password := range breached {
+		lines = append(lines, Hash(password)+":42")
+	}
+
+	t.Run("range files", func(t *testing.T) {
+		rangesDir := filepath.Join(dir, "ranges")
+		require.Equal(t, nil, os.Mkdir(rangesDir, 0755))
+		for _, line := range lines {
+			name := filepath.Join(rangesDir, line[:PrefixLength]+".txt")
+			require.Equal(t, nil, ioutil.WriteFile(name, []byte("0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n"+line[PrefixLength:]+"\r\n"), 0644))
+		}
+		checker, err := Open(rangesDir)
+		require.Equal(t, nil, err)
+		for _, password := range breached {
+			ok, err := checker.IsBreached(ctx, password)
+			require.Equal(t, nil, err)
+			require.Equal(t, true, ok, password)
+		}
+		ok, err := checker.IsBreached(ctx, "kT9vW2qLx7Rm")
+		require.Equal(t, nil, err)
+		require.Equal(t, false, ok)
+
+		count := 0
+		require.Equal(t, nil, ReadHashes(rangesDir, func(hash [sha1.Size]byte, _ int) error {
+			count++
+			return nil
+		}))
+		require.Equal(t, 2*len(breached), count)
+	})
+
+	t.Run("bloom filter", func(t *testing.T) {
+		raw := filepath.Join(dir, "pwned.txt")
+		require.Equal(t, nil, ioutil.WriteFile(raw, []byte(strings.Join(lines, "\n")), 0644))
+		filter := NewBloomFilter(uint64(len(lines)), 0.001)
+		require.Equal(t, nil, ReadHashes(raw, func(hash [sha1.Size]byte, count int) error {
+			require.Equal(t, 42, count)
+			filter.Add(hash)
+			return nil
+		}))
+		index := filepath.Join(dir, "breached.bloom")
+		f, err := os.Create(index)
+		require.Equal(t, nil, err)
+		_, err = filter.WriteTo(f)
+		require.Equal(t, nil, err)
+		require.Equal(t, nil, f.Close())
+
+		checker, err := Open(index)
+		require.Equal(t, nil, err)
+		defer checker.Close()
+		for _, password := range breached {
+			ok, err := checker.IsBreached(ctx, password)
+			require.Equal(t, nil, err)
+			require.Equal(t, true, ok, password)
+		}
+		ok, err := checker.IsBreached(ctx, "kT9vW2qLx7Rm")
+		require.Equal(t, nil, err)
+		require.Equal(t, false, ok)
+
+		err = Validate(ctx, checker, "password")
+		require.Equal(t, _errors.ErrPasswordBreached, _errors.Code(_errors.ToGqlError(err)))
+		require.Equal(t, nil, Validate(ctx, nil, "password"))
+
+		_, err = Open(raw)
+		require.NotEqual(t, nil, err)
+	})
+}
@@ -0,0 +1,56 @@
+package breach
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errFound stops scanning the range file
+var errFound = errors.New("found")
+
+type rangeChecker struct {
+	dir string
+}
+
+// NewRangeChecker returns the checker of the directory with the range files, e.g. 5BAA6 or 5BAA6.txt
+// with SUFFIX:COUNT lines, like the responses of the range API. Missing files mean no breached passwords.
+func NewRangeChecker(dir string) Checker {
+	return &rangeChecker{dir}
+}
+
+func (c *rangeChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	hash := Hash(password)
+	prefix, suffix := hash[:PrefixLength], hash[PrefixLength:]
+	for _, name := range []string{prefix, prefix + ".txt"} {
+		f, err := os.Open(filepath.Join(c.dir, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		found := false
+		err = scan(f, func(line string) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if strings.EqualFold(strings.SplitN(line, ":", 2)[0], suffix) {
+				found = true
+				return errFound
+			}
+			return nil
+		})
+		if err != nil && err != errFound {
+			return false, err
+		}
+		return found, nil
+	}
+	return false, nil
+}
+
+func (c *rangeChecker) Close() error {
+	return nil
+}
@@ -0,0 +1,51 @@
+// Package cache stores encoded values in process and, optionally, in a backend shared by the replicas.
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Cache is safe for concurrent use. The backends which can fail log their errors and behave like
+// an empty cache, so a broken cache slows the application down but doesn't break it.
+type Cache interface {
+	// Get returns a value set before and not expired or evicted since.
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte)
+	Delete(ctx context.Context, keys ...string)
+}
+
+// Stats is a snapshot of the Metrics.
+type Stats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// Invalidations counts the invalidated keys, including the invalidations received from the other replicas.
+	Invalidations int64 `json:"invalidations"`
+}
+
+// Metrics counts the lookups of a cache user, the zero value is ready to use.
+type Metrics struct {
+	hits          int64
+	misses        int64
+	invalidations int64
+}
+
+func (m *Metrics) Hit() {
+	atomic.AddInt64(&m.hits, 1)
+}
+
+func (m *Metrics) Miss() {
+	atomic.AddInt64(&m.misses, 1)
+}
+
+func (m *Metrics) Invalidate(n int) {
+	atomic.AddInt64(&m.invalidations, int64(n))
+}
+
+func (m *Metrics) Stats() Stats {
+	return Stats{
+		Hits:          atomic.LoadInt64(&m.hits),
+		Misses:        atomic.LoadInt64(&m.misses),
+		Invalidations: atomic.LoadInt64(&m.invalidations),
+	}
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newLRU(2, time.Minute, func() time.Time { return now })
+
+	c.Set(ctx, "a", []byte("1"))
+	c.Set(ctx, "b", []byte("2"))
+	// a is used more recently than b, so b is evicted
+	_, ok := c.Get(ctx, "a")
+	require.Equal(t, true, ok)
+	c.Set(ctx, "c", []byte("3"))
+	_, ok = c.Get(ctx, "b")
+	require.Equal(t, false, ok)
+	value, ok := c.Get(ctx, "c")
+	require.Equal(t, true, ok)
+	require.Equal(t, "3", string(value))
+
+	c.Delete(ctx, "a", "missing")
+	_, ok = c.Get(ctx, "a")
+	require.Equal(t, false, ok)
+
+	now = now.Add(time.Minute)
+	_, ok = c.Get(ctx, "c")
+	require.Equal(t, false, ok)
+	require.Equal(t, 0, c.order.Len())
+}
+
+func TestLayered(t *testing.T) {
+	ctx := context.Background()
+	local, shared := NewLRU(10, 0), NewLRU(10, 0)
+	c := NewLayered(local, shared)
+
+	shared.Set(ctx, "a", []byte("1"))
+	value, ok := c.Get(ctx, "a")
+	require.Equal(t, true, ok)
+	require.Equal(t, "1", string(value))
+	_, ok = local.Get(ctx, "a")
+	require.Equal(t, true, ok)
+
+	c.Set(ctx, "b", []byte("2"))
+	c.Delete(ctx, "a")
+	for _, cache := range []Cache{local, shared} {
+		_, ok = cache.Get(ctx, "a")
+		require.Equal(t, false, ok)
+		_, ok = cache.Get(ctx, "b")
+		require.Equal(t, true, ok)
+	}
+}
@@ -0,0 +1,35 @@
+package cache
+
+import "context"
+
+type layered struct {
+	local  Cache
+	shared Cache
+}
+
+// NewLayered returns the cache which reads the local cache first and fills it with the values found
+// in the shared one. The writes and deletes go to both of them.
+func NewLayered(local, shared Cache) Cache {
+	return &layered{local, shared}
+}
+
+func (c *layered) Get(ctx context.Context, key string) ([]byte, bool) {
+	if value, ok := c.local.Get(ctx, key); ok {
+		return value, true
+	}
+	value, ok := c.shared.Get(ctx, key)
+	if ok {
+		c.local.Set(ctx, key, value)
+	}
+	return value, ok
+}
+
+func (c *layered) Set(ctx context.Context, key string, value []byte) {
+	c.shared.Set(ctx, key, value)
+	c.local.Set(ctx, key, value)
+}
+
+func (c *layered) Delete(ctx context.Context, keys ...string) {
+	c.shared.Delete(ctx, keys...)
+	c.local.Delete(ctx, keys...)
+}
@@ -0,0 +1,65 @@
+// Command buildbreachindex builds the Bloom filter of the breached passwords used by password.breached,
+// from the Have I Been Pwned SHA-1 file with HASH:COUNT lines or the directory of the range files.
+//
+// Usage (from the backend directory):
+//
+//	go run ./cmd/buildbreachindex -in pwned-passwords-sha1-ordered-by-hash.txt -out breached.bloom
+package main
+
+import (
+	"backend/breach"
+	"bufio"
+	"crypto/sha1"
+	"flag"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	in := flag.String("in", "", "the file with HASH:COUNT lines or the directory of the range files")
+	out := flag.String("out", "breached.bloom", "path to the Bloom filter")
+	falsePositiveRate := flag.Float64("fp", 0.001, "the rate of passwords wrongly reported as breached")
+	minCount := flag.Int("min-count", 1, "skip the passwords which appeared in breaches fewer times")
+	flag.Parse()
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// the first pass counts the hashes to size the filter
+	var n uint64
+	if err := breach.ReadHashes(*in, func(hash [sha1.Size]byte, count int) error {
+		if count >= *minCount {
+			n++
+		}
+		return nil
+	}); err != nil {
+		logrus.Fatal(err)
+	}
+	filter := breach.NewBloomFilter(n, *falsePositiveRate)
+	if err := breach.ReadHashes(*in, func(hash [sha1.Size]byte, count int) error {
+		if count >= *minCount {
+			filter.Add(hash)
+		}
+		return nil
+	}); err != nil {
+		logrus.Fatal(err)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	if _, err := filter.WriteTo(w); err != nil {
+		logrus.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		logrus.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		logrus.Fatal(err)
+	}
+	logrus.Infof("Indexed %d breached passwords in %s", n, *out)
+}
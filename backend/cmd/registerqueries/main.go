@@ -0,0 +1,136 @@
+// Command registerqueries finds the GraphQL operations used by the frontend and registers
+// their hashes, so they can be executed when the server runs with graphql.persistedQueries.strict.
+//
+// Usage (from the backend directory):
+//
+//	go run ./cmd/registerqueries -frontend ../frontend
+package main
+
+import (
+	"backend/graphql/persistedquery"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+var gqlTemplateLiteral = regexp.MustCompile("gql\\s*`([^`]*)`")
+
+type operation struct {
+	file  string
+	name  string
+	query string
+}
+
+func main() {
+	configFile := flag.String("config", "config.json", "path to the config file")
+	frontendDir := flag.String("frontend", filepath.Join("..", "frontend"), "path to the frontend directory")
+	addTypename := flag.Bool("typename", true, "add __typename to selection sets like the Apollo cache does")
+	dryRun := flag.Bool("dry-run", false, "print the hashes without registering them")
+	flag.Parse()
+
+	operations, err := findOperations(*frontendDir, *addTypename)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	for _, op := range operations {
+		fmt.Printf("%s  %s  %s\n", persistedquery.Hash(op.query), op.name, op.file)
+	}
+	if *dryRun {
+		return
+	}
+
+	viper.SetConfigFile(*configFile)
+	if err := viper.ReadInConfig(); err != nil {
+		logrus.Fatal(err)
+	}
+	dbConn := pg.Connect(&pg.Options{
+		Addr:            viper.GetString("db.addr"),
+		User:            viper.GetString("db.user"),
+		Password:        viper.GetString("db.password"),
+		Database:        viper.GetString("db.name"),
+		ApplicationName: viper.GetString("application.name"),
+	})
+	defer dbConn.Close()
+	allowlist, err := persistedquery.NewPostgreAllowlist(dbConn)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	failed := 0
+	for _, op := range operations {
+		if err := allowlist.Register(context.Background(), persistedquery.Hash(op.query), op.query); err != nil {
+			logrus.Errorf("%s: cannot register %s: %s", op.file, op.name, err.Error())
+			failed++
+		}
+	}
+	if failed > 0 {
+		logrus.Fatalf("Registered %d of %d operations", len(operations)-failed, len(operations))
+	}
+	logrus.Infof("Registered %d operations", len(operations))
+}
+
+func findOperations(root string, addTypename bool) ([]operation, error) {
+	operations := []operation{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == "node_modules" || info.Name() == ".next" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var sources []string
+		switch filepath.Ext(path) {
+		case ".graphql", ".gql":
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			sources = append(sources, string(b))
+		case ".js", ".jsx", ".ts", ".tsx":
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			for _, match := range gqlTemplateLiteral.FindAllStringSubmatch(string(b), -1) {
+				if strings.Contains(match[1], "${") {
+					logrus.Warnf("%s: skipping a query with interpolations", path)
+					continue
+				}
+				sources = append(sources, match[1])
+			}
+		default:
+			return nil
+		}
+
+		for _, source := range sources {
+			doc, gqlErr := parser.ParseQuery(&ast.Source{Name: path, Input: source})
+			if gqlErr != nil {
+				return gqlErr
+			}
+			if len(doc.Operations) == 0 {
+				continue
+			}
+			operations = append(operations, operation{
+				file:  path,
+				name:  doc.Operations[0].Name,
+				query: persistedquery.Print(doc, addTypename),
+			})
+		}
+		return nil
+	})
+	return operations, err
+}
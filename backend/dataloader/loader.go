@@ -0,0 +1,135 @@
+package dataloader
+
+import (
+	_errors "backend/errors"
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWait     = 2 * time.Millisecond
+	defaultMaxBatch = 100
+)
+
+// fetchFunc must return a value and an error for every key, in the order of the keys. The keys without
+// a value and an error get the not found error of the loader.
+type fetchFunc func(ctx context.Context, keys []interface{}) ([]interface{}, []error)
+
+type result struct {
+	value interface{}
+	err   error
+	done  chan struct{}
+}
+
+type batch struct {
+	keys    []interface{}
+	results []*result
+}
+
+// loader collects the keys requested within the wait window and fetches them at once.
+// Results are cached for the lifetime of the loader, which is a single request.
+type loader struct {
+	ctx      context.Context
+	fetch    fetchFunc
+	notFound string
+	wait     time.Duration
+	maxBatch int
+	logrus   *logrus.Entry
+
+	mu    sync.Mutex
+	cache map[interface{}]*result
+	batch *batch
+}
+
+func newLoader(ctx context.Context, fetch fetchFunc, notFound string, wait time.Duration, maxBatch int) *loader {
+	if wait <= 0 {
+		wait = defaultWait
+	}
+	if maxBatch <= 0 {
+		maxBatch = defaultMaxBatch
+	}
+	return &loader{
+		ctx:      ctx,
+		fetch:    fetch,
+		notFound: notFound,
+		wait:     wait,
+		maxBatch: maxBatch,
+		logrus:   logrus.WithField("package", "dataloader"),
+		cache:    make(map[interface{}]*result),
+	}
+}
+
+func (l *loader) load(key interface{}) (interface{}, error) {
+	l.mu.Lock()
+	if r, ok := l.cache[key]; ok {
+		l.mu.Unlock()
+		<-r.done
+		return r.value, r.err
+	}
+
+	r := &result{done: make(chan struct{})}
+	l.cache[key] = r
+	if l.batch == nil {
+		l.batch = &batch{}
+		go l.dispatchAfterWait(l.batch)
+	}
+	l.batch.keys = append(l.batch.keys, key)
+	l.batch.results = append(l.batch.results, r)
+	if len(l.batch.keys) >= l.maxBatch {
+		b := l.batch
+		l.batch = nil
+		go l.dispatch(b)
+	}
+	l.mu.Unlock()
+
+	<-r.done
+	return r.value, r.err
+}
+
+func (l *loader) dispatchAfterWait(b *batch) {
+	time.Sleep(l.wait)
+	l.mu.Lock()
+	if l.batch != b {
+		// the batch has been already dispatched because it was full
+		l.mu.Unlock()
+		return
+	}
+	l.batch = nil
+	l.mu.Unlock()
+	l.dispatch(b)
+}
+
+// dispatch runs in its own goroutine, so it recovers the panics of fetch which the middleware
+// of the request can't, and fails the whole batch.
+func (l *loader) dispatch(b *batch) {
+	defer func() {
+		if r := recover(); r != nil {
+			l.logrus.WithField("keys", b.keys).Errorf("dispatch - Fetch panicked: %v\n%s", r, debug.Stack())
+			err := _errors.Wrap(_errors.ErrInternalServerError, fmt.Errorf("dataloader: fetch panicked: %v", r))
+			for _, r := range b.results {
+				r.value, r.err = nil, err
+				close(r.done)
+			}
+		}
+	}()
+	values, errs := l.fetch(l.ctx, b.keys)
+	for i, r := range b.results {
+		if i < len(values) {
+			r.value = values[i]
+		}
+		if i < len(errs) {
+			r.err = errs[i]
+		}
+		if r.value == nil && r.err == nil {
+			r.err = _errors.Wrap(l.notFound)
+		}
+	}
+	for _, r := range b.results {
+		close(r.done)
+	}
+}
@@ -0,0 +1,103 @@
+package dataloader
+
+import (
+	_errors "backend/errors"
+	"backend/models"
+	"context"
+	"time"
+)
+
+// UserFetcher is implemented by both user.Repository and user.Usecase.
+type UserFetcher interface {
+	GetByIDs(ctx context.Context, ids []int) ([]*models.User, error)
+	GetBySlugs(ctx context.Context, slugs []string) ([]*models.User, error)
+}
+
+type Config struct {
+	Wait     time.Duration
+	MaxBatch int
+}
+
+type Loaders struct {
+	UserByID   *UserLoader
+	UserBySlug *UserSlugLoader
+}
+
+func NewLoaders(ctx context.Context, users UserFetcher, cfg Config) *Loaders {
+	return &Loaders{
+		UserByID:   &UserLoader{newLoader(ctx, fetchUsersByIDs(users), _errors.ErrUserNotFound, cfg.Wait, cfg.MaxBatch)},
+		UserBySlug: &UserSlugLoader{newLoader(ctx, fetchUsersBySlugs(users), _errors.ErrUserNotFound, cfg.Wait, cfg.MaxBatch)},
+	}
+}
+
+type UserLoader struct {
+	l *loader
+}
+
+func (loader *UserLoader) Load(id int) (*models.User, error) {
+	v, err := loader.l.load(id)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*models.User), nil
+}
+
+type UserSlugLoader struct {
+	l *loader
+}
+
+func (loader *UserSlugLoader) Load(slug string) (*models.User, error) {
+	v, err := loader.l.load(slug)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*models.User), nil
+}
+
+func fetchUsersByIDs(users UserFetcher) fetchFunc {
+	return func(ctx context.Context, keys []interface{}) ([]interface{}, []error) {
+		ids := make([]int, len(keys))
+		for i, key := range keys {
+			ids[i] = key.(int)
+		}
+		result, err := users.GetByIDs(ctx, ids)
+		byKey := make(map[interface{}]*models.User, len(result))
+		for _, u := range result {
+			byKey[u.ID] = u
+		}
+		return assign(keys, byKey, err)
+	}
+}
+
+func fetchUsersBySlugs(users UserFetcher) fetchFunc {
+	return func(ctx context.Context, keys []interface{}) ([]interface{}, []error) {
+		slugs := make([]string, len(keys))
+		for i, key := range keys {
+			slugs[i] = key.(string)
+		}
+		result, err := users.GetBySlugs(ctx, slugs)
+		byKey := make(map[interface{}]*models.User, len(result))
+		for _, u := range result {
+			byKey[u.Slug] = u
+		}
+		return assign(keys, byKey, err)
+	}
+}
+
+func assign(keys []interface{}, byKey map[interface{}]*models.User, err error) ([]interface{}, []error) {
+	values := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		u, ok := byKey[key]
+		if !ok {
+			errs[i] = _errors.Wrap(_errors.ErrUserNotFound)
+			continue
+		}
+		values[i] = u
+	}
+	return values, errs
+}
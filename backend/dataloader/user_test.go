@@ -0,0 +1,157 @@
+package dataloader
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_errors "backend/errors"
+	"backend/models"
+	"backend/utils/seed"
+
+	"github.com/stretchr/testify/require"
+)
+
+type countingFetcher struct {
+	users   []models.User
+	queries int32
+}
+
+func (f *countingFetcher) GetByIDs(ctx context.Context, ids []int) ([]*models.User, error) {
+	atomic.AddInt32(&f.queries, 1)
+	users := []*models.User{}
+	for _, id := range ids {
+		for i := range f.users {
+			if f.users[i].ID == id {
+				users = append(users, &f.users[i])
+			}
+		}
+	}
+	return users, nil
+}
+
+func (f *countingFetcher) GetBySlugs(ctx context.Context, slugs []string) ([]*models.User, error) {
+	atomic.AddInt32(&f.queries, 1)
+	users := []*models.User{}
+	for _, slug := range slugs {
+		for i := range f.users {
+			if f.users[i].Slug == slug {
+				users = append(users, &f.users[i])
+			}
+		}
+	}
+	return users, nil
+}
+
+func TestUserLoaders(t *testing.T) {
+	seedUsers := seed.Users(10)
+
+	t.Run("N lookups by id produce one query", func(t *testing.T) {
+		fetcher := &countingFetcher{users: seedUsers}
+		loaders := NewLoaders(context.Background(), fetcher, Config{Wait: 20 * time.Millisecond})
+		var wg sync.WaitGroup
+		for _, u := range seedUsers {
+			wg.Add(1)
+			go func(u models.User) {
+				defer wg.Done()
+				user, err := loaders.UserByID.Load(u.ID)
+				require.Equal(t, nil, err)
+				require.Equal(t, u.Login, user.Login)
+			}(u)
+		}
+		wg.Wait()
+		require.Equal(t, int32(1), atomic.LoadInt32(&fetcher.queries))
+	})
+
+	t.Run("N lookups by slug produce one query", func(t *testing.T) {
+		fetcher := &countingFetcher{users: seedUsers}
+		loaders := NewLoaders(context.Background(), fetcher, Config{Wait: 20 * time.Millisecond})
+		var wg sync.WaitGroup
+		for _, u := range seedUsers {
+			wg.Add(1)
+			go func(u models.User) {
+				defer wg.Done()
+				user, err := loaders.UserBySlug.Load(u.Slug)
+				require.Equal(t, nil, err)
+				require.Equal(t, u.ID, user.ID)
+			}(u)
+		}
+		wg.Wait()
+		require.Equal(t, int32(1), atomic.LoadInt32(&fetcher.queries))
+	})
+
+	t.Run("results are cached within the request", func(t *testing.T) {
+		fetcher := &countingFetcher{users: seedUsers}
+		loaders := NewLoaders(context.Background(), fetcher, Config{Wait: 20 * time.Millisecond})
+		for i := 0; i < 3; i++ {
+			_, err := loaders.UserByID.Load(seedUsers[0].ID)
+			require.Equal(t, nil, err)
+		}
+		require.Equal(t, int32(1), atomic.LoadInt32(&fetcher.queries))
+	})
+
+	t.Run("batches are split by the max batch size", func(t *testing.T) {
+		fetcher := &countingFetcher{users: seedUsers}
+		loaders := NewLoaders(context.Background(), fetcher, Config{Wait: 20 * time.Millisecond, MaxBatch: 5})
+		var wg sync.WaitGroup
+		for _, u := range seedUsers {
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				loaders.UserByID.Load(id)
+			}(u.ID)
+		}
+		wg.Wait()
+		require.Equal(t, int32(2), atomic.LoadInt32(&fetcher.queries))
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		fetcher := &countingFetcher{users: seedUsers}
+		loaders := NewLoaders(context.Background(), fetcher, Config{Wait: 20 * time.Millisecond})
+		_, err := loaders.UserByID.Load(seedUsers[len(seedUsers)-1].ID + 1)
+		require.Equal(t, true, strings.Contains(err.Error(), _errors.ErrUserNotFound))
+	})
+
+	t.Run("fetch panics", func(t *testing.T) {
+		l := newLoader(context.Background(), func(ctx context.Context, keys []interface{}) ([]interface{}, []error) {
+			panic("broken fetch")
+		}, _errors.ErrUserNotFound, time.Millisecond, 0)
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func(key int) {
+				defer wg.Done()
+				v, err := l.load(key)
+				require.Equal(t, nil, v)
+				require.Equal(t, _errors.ErrInternalServerError, _errors.Code(_errors.ToGqlError(err)))
+			}(i)
+		}
+		wg.Wait()
+	})
+
+	t.Run("short result", func(t *testing.T) {
+		l := newLoader(context.Background(), func(ctx context.Context, keys []interface{}) ([]interface{}, []error) {
+			return []interface{}{"first"}, nil
+		}, _errors.ErrUserNotFound, 20*time.Millisecond, 0)
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = l.load(i)
+			}(i)
+		}
+		wg.Wait()
+		codes := []string{}
+		for _, err := range errs {
+			if err != nil {
+				codes = append(codes, _errors.Code(_errors.ToGqlError(err)))
+			}
+		}
+		require.Equal(t, []string{_errors.ErrUserNotFound}, codes)
+	})
+}
@@ -0,0 +1,192 @@
+package http
+
+import (
+	"backend/email"
+	"backend/outbox"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/language"
+)
+
+type Config struct {
+	Mailer email.Email
+	// Captured is shown on the index page, it is optional.
+	Captured *email.MemoryTransport
+	// FrontendURL and URL, the public URL of the backend, are used in the sample links.
+	FrontendURL string
+	URL         string
+}
+
+type handler struct {
+	mailer      email.Email
+	captured    *email.MemoryTransport
+	frontendURL string
+	url         string
+}
+
+// NewEmailPreviewHandler registers the routes which render the email templates with sample data,
+// they are available only in the development mode.
+func NewEmailPreviewHandler(g *echo.Group, cfg Config) error {
+	if cfg.Mailer == nil {
+		return fmt.Errorf("Mailer cannot be nil")
+	}
+	if os.Getenv("MODE") != "development" {
+		return nil
+	}
+	h := &handler{cfg.Mailer, cfg.Captured, cfg.FrontendURL, cfg.URL}
+	g.GET("/emails", h.index)
+	g.GET("/emails/templates/:name", h.preview)
+	g.GET("/emails/templates/:name/html", h.html)
+	g.GET("/emails/captured/:index", h.capturedMessage)
+	return nil
+}
+
+const (
+	sampleToken      = "7b1f9c64-2d4e-4a8b-9f3c-5e6d7a8b9c0d"
+	sampleInviteCode = "MFRGGZDFMZTWQ2LK"
+)
+
+type previewLink struct {
+	Template string
+	Language language.Tag
+}
+
+type capturedItem struct {
+	Index int
+	email.SentMessage
+}
+
+func (h *handler) index(c echo.Context) error {
+	links := []previewLink{}
+	for _, name := range h.mailer.Templates() {
+		for _, tag := range h.mailer.Languages() {
+			links = append(links, previewLink{name, tag})
+		}
+	}
+	captured := []capturedItem{}
+	if h.captured != nil {
+		messages := h.captured.Messages()
+		// the newest first
+		for i := len(messages) - 1; i >= 0; i-- {
+			captured = append(captured, capturedItem{i, messages[i]})
+		}
+	}
+	return render(c, indexTemplate, map[string]interface{}{
+		"Links":          links,
+		"CaptureEnabled": h.captured != nil,
+		"Captured":       captured,
+	})
+}
+
+func (h *handler) preview(c echo.Context) error {
+	msg, err := h.mailer.Render(c.Param("name"), c.QueryParam("lang"), h.sampleData(c.Param("name")))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return render(c, previewTemplate, map[string]interface{}{
+		"Template": c.Param("name"),
+		"Message":  msg,
+		"Lang":     c.QueryParam("lang"),
+	})
+}
+
+func (h *handler) html(c echo.Context) error {
+	msg, err := h.mailer.Render(c.Param("name"), c.QueryParam("lang"), h.sampleData(c.Param("name")))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return c.HTML(http.StatusOK, msg.HTML)
+}
+
+func (h *handler) capturedMessage(c echo.Context) error {
+	if h.captured == nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	messages := h.captured.Messages()
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= len(messages) {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	return c.Blob(http.StatusOK, "text/plain; charset=utf-8", messages[index].Raw)
+}
+
+// sampleData contains the values used by all templates in the format of the outbox.
+func (h *handler) sampleData(name string) map[string]interface{} {
+	href := fmt.Sprintf("%s/1/activate/%s", h.frontendURL, sampleToken)
+	switch name {
+	case outbox.ResetPasswordTemplate:
+		href = fmt.Sprintf("%s/1/reset-password/%s", h.frontendURL, sampleToken)
+	case outbox.DataExportTemplate:
+		href = fmt.Sprintf("%s/exports/%s", h.url, sampleToken)
+	case outbox.AccountDeletionTemplate:
+		href = fmt.Sprintf("%s/1/cancel-deletion/%s", h.frontendURL, sampleToken)
+	case outbox.InvitationTemplate:
+		href = fmt.Sprintf("%s/signup?inviteCode=%s", h.frontendURL, sampleInviteCode)
+	}
+	return map[string]interface{}{
+		"Login":       "johndoe",
+		"Href":        href,
+		"Password":    "aB3dE5fG7hJ9kL1m",
+		"ExpiresAt":   "2020-05-02 12:00 UTC",
+		"ScheduledAt": "2020-06-01 12:00 UTC",
+		"InvitedBy":   "janedoe",
+	}
+}
+
+func render(c echo.Context, tpl *template.Template, data interface{}) error {
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	c.Response().WriteHeader(http.StatusOK)
+	return tpl.Execute(c.Response(), data)
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8" />
+    <title>Emails</title>
+  </head>
+  <body style="font-family: sans-serif;">
+    <h1>Templates</h1>
+    <ul>
+      {{range .Links}}
+      <li><a href="emails/templates/{{.Template}}?lang={{.Language}}">{{.Template}} ({{.Language}})</a></li>
+      {{end}}
+    </ul>
+    <h1>Captured</h1>
+    {{if .CaptureEnabled}}
+    <ul>
+      {{range .Captured}}
+      <li>
+        <a href="emails/captured/{{.Index}}">{{.SentAt.Format "2006-01-02 15:04:05"}} {{.Message.GetHeader "To"}} - {{.Message.GetHeader "Subject"}}</a>
+      </li>
+      {{else}}
+      <li>No emails have been sent yet.</li>
+      {{end}}
+    </ul>
+    {{else}}
+    <p>Set "email.transport" to "memory" to capture the sent emails.</p>
+    {{end}}
+  </body>
+</html>`))
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="utf-8" />
+    <title>{{.Message.Subject}}</title>
+  </head>
+  <body style="font-family: sans-serif;">
+    <p><a href="../../emails">Emails</a></p>
+    <h1>{{.Template}} ({{.Message.Language}})</h1>
+    <p><strong>Subject:</strong> {{.Message.Subject}}</p>
+    <h2>HTML</h2>
+    <iframe src="{{.Template}}/html?lang={{.Lang}}" style="width: 100%; height: 600px; border: 1px solid #cccccc;"></iframe>
+    <h2>Text</h2>
+    <pre style="white-space: pre-wrap; border: 1px solid #cccccc; padding: 16px;">{{.Message.Text}}</pre>
+  </body>
+</html>`))
@@ -1,76 +1,64 @@
 package email
 
 import (
-	"bytes"
-	"path/filepath"
-	"text/template"
-
+	"golang.org/x/text/language"
 	gomail "gopkg.in/gomail.v2"
 )
 
 type Email interface {
 	Send(msg *gomail.Message) error
-	GetTemplate(name string, data interface{}) (string, error)
+	// Render renders the template in the locale which matches language (in the Accept-Language format) best.
+	Render(name, language string, data map[string]interface{}) (*Message, error)
 	LoadTemplates(dir string) error
+	// Templates returns the names of the loaded templates.
+	Templates() []string
+	// Languages returns the locales of the loaded templates, the default one first.
+	Languages() []language.Tag
 	GetAddress() string
 }
 
-type email struct {
-	address string
-	dialer  *gomail.Dialer
-	tpl     *template.Template
+type Config struct {
+	Transport Transport
+	// Address is used in the From header.
+	Address string
+	// DefaultLanguage is used when no locale matches the language of the recipient.
+	DefaultLanguage language.Tag
 }
 
-var DefaultInstance = &email{}
+type email struct {
+	address   string
+	transport Transport
+	templates *templates
+}
 
 func (e *email) Send(msg *gomail.Message) error {
-	return e.dialer.DialAndSend(msg)
+	return e.transport.Send(msg)
 }
 
-func (e *email) GetTemplate(name string, data interface{}) (string, error) {
-	var tpl bytes.Buffer
-	if err := e.tpl.ExecuteTemplate(&tpl, name, data); err != nil {
-		return "", err
-	}
-	return tpl.String(), nil
+func (e *email) Render(name, language string, data map[string]interface{}) (*Message, error) {
+	return e.templates.render(name, language, data)
 }
 
 func (e *email) LoadTemplates(dir string) error {
-	tpl, err := template.ParseGlob(filepath.Join(dir, "*.gohtml"))
-	if err != nil {
-		return err
-	}
-	e.tpl = tpl
-	return nil
-}
-
-func (e *email) GetAddress() string {
-	return e.address
+	return e.templates.load(dir)
 }
 
-func New(host string, port int, username, password, address string) Email {
-	return &email{dialer: gomail.NewDialer(host, port, username, password), address: address}
+func (e *email) Templates() []string {
+	return e.templates.names()
 }
 
-func NewDialer(host string, port int, username, password string) {
-	DefaultInstance.dialer = gomail.NewDialer(host, port, username, password)
-	if DefaultInstance.address == "" {
-		DefaultInstance.address = username
-	}
+func (e *email) Languages() []language.Tag {
+	return e.templates.languages()
 }
 
-func Send(msg *gomail.Message) error {
-	return DefaultInstance.Send(msg)
-}
-
-func GetTemplate(template string, data interface{}) (string, error) {
-	return DefaultInstance.GetTemplate(template, data)
-}
-
-func LoadTemplates(dir string) error {
-	return DefaultInstance.LoadTemplates(dir)
+func (e *email) GetAddress() string {
+	return e.address
 }
 
-func GetAddress() string {
-	return DefaultInstance.GetAddress()
+func New(cfg Config) Email {
+	return &email{
+		address:   cfg.Address,
+		transport: cfg.Transport,
+		templates: newTemplates(cfg.DefaultLanguage),
+	}
 }
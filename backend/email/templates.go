@@ -0,0 +1,244 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"golang.org/x/text/language"
+)
+
+// The templates directory contains:
+//
+//	layouts/  - shared layouts, e.g. {{define "layout"}}...{{template "content" .}}...{{end}}
+//	partials/ - shared partials
+//	<locale>/ - the templates of the locale, e.g. en/activation.html.gohtml and en/activation.txt.gohtml,
+//	            <locale>/partials/ contains the partials of the locale
+//
+// Every template has an HTML and a plain-text part, the text part defines the "subject".
+const (
+	htmlExt     = ".html.gohtml"
+	textExt     = ".txt.gohtml"
+	layoutsDir  = "layouts"
+	partialsDir = "partials"
+	subject     = "subject"
+)
+
+// Message is a rendered template.
+type Message struct {
+	Language language.Tag
+	Subject  string
+	HTML     string
+	Text     string
+}
+
+type templateSet struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+type templates struct {
+	defaultLanguage language.Tag
+	sets            map[string]map[language.Tag]*templateSet
+	tags            []language.Tag
+	matcher         language.Matcher
+}
+
+var funcs = map[string]interface{}{
+	"dict": dict,
+}
+
+// dict builds a map from key-value pairs, so partials can get more than one argument.
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict expects key-value pairs")
+	}
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict keys must be strings")
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+func newTemplates(defaultLanguage language.Tag) *templates {
+	return &templates{
+		defaultLanguage: defaultLanguage,
+		sets:            map[string]map[language.Tag]*templateSet{},
+	}
+}
+
+func (t *templates) load(dir string) error {
+	shared, err := parseShared(&templateSet{
+		htmltemplate.New("").Funcs(funcs),
+		texttemplate.New("").Funcs(funcs),
+	}, filepath.Join(dir, layoutsDir), filepath.Join(dir, partialsDir))
+	if err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	sets := map[string]map[language.Tag]*templateSet{}
+	tags := []language.Tag{}
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == layoutsDir || entry.Name() == partialsDir {
+			continue
+		}
+		tag, err := language.Parse(entry.Name())
+		if err != nil {
+			return fmt.Errorf("%s is not a locale: %s", entry.Name(), err.Error())
+		}
+		localeDir := filepath.Join(dir, entry.Name())
+		locale, err := parseShared(shared, filepath.Join(localeDir, partialsDir))
+		if err != nil {
+			return err
+		}
+		files, err := filepath.Glob(filepath.Join(localeDir, "*"+htmlExt))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), htmlExt)
+			set, err := parseTemplate(locale, localeDir, name)
+			if err != nil {
+				return err
+			}
+			if sets[name] == nil {
+				sets[name] = map[language.Tag]*templateSet{}
+			}
+			sets[name][tag] = set
+		}
+		tags = append(tags, tag)
+	}
+	// the first tag is the fallback of the matcher
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i] == t.defaultLanguage && tags[j] != t.defaultLanguage
+	})
+	t.sets = sets
+	t.tags = tags
+	t.matcher = language.NewMatcher(tags)
+	return nil
+}
+
+// parseShared returns a copy of base with the templates from dirs added.
+func parseShared(base *templateSet, dirs ...string) (*templateSet, error) {
+	html, err := base.html.Clone()
+	if err != nil {
+		return nil, err
+	}
+	text, err := base.text.Clone()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		htmlFiles, _ := filepath.Glob(filepath.Join(dir, "*"+htmlExt))
+		if len(htmlFiles) > 0 {
+			if _, err := html.ParseFiles(htmlFiles...); err != nil {
+				return nil, err
+			}
+		}
+		textFiles, _ := filepath.Glob(filepath.Join(dir, "*"+textExt))
+		if len(textFiles) > 0 {
+			if _, err := text.ParseFiles(textFiles...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &templateSet{html, text}, nil
+}
+
+func parseTemplate(shared *templateSet, dir, name string) (*templateSet, error) {
+	textFile := filepath.Join(dir, name+textExt)
+	if _, err := os.Stat(textFile); err != nil {
+		return nil, fmt.Errorf("template %s has no plain-text part: %s", name, err.Error())
+	}
+	set, err := parseShared(shared)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := set.html.ParseFiles(filepath.Join(dir, name+htmlExt)); err != nil {
+		return nil, err
+	}
+	if _, err := set.text.ParseFiles(textFile); err != nil {
+		return nil, err
+	}
+	if set.text.Lookup(subject) == nil {
+		return nil, fmt.Errorf("template %s does not define the %s", textFile, subject)
+	}
+	return set, nil
+}
+
+func (t *templates) names() []string {
+	names := make([]string, 0, len(t.sets))
+	for name := range t.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *templates) languages() []language.Tag {
+	tags := make([]language.Tag, len(t.tags))
+	copy(tags, t.tags)
+	return tags
+}
+
+func (t *templates) render(name, lang string, data map[string]interface{}) (*Message, error) {
+	locales, ok := t.sets[name]
+	if !ok {
+		return nil, fmt.Errorf("email template %s not found", name)
+	}
+	tag, set := t.match(locales, lang)
+
+	d := make(map[string]interface{}, len(data)+2)
+	for k, v := range data {
+		d[k] = v
+	}
+	d["Language"] = tag.String()
+	msg := &Message{Language: tag}
+	var buf bytes.Buffer
+	if err := set.text.ExecuteTemplate(&buf, subject, d); err != nil {
+		return nil, err
+	}
+	msg.Subject = strings.TrimSpace(buf.String())
+	d["Subject"] = msg.Subject
+
+	buf.Reset()
+	if err := set.text.ExecuteTemplate(&buf, name+textExt, d); err != nil {
+		return nil, err
+	}
+	msg.Text = strings.TrimSpace(buf.String())
+	buf.Reset()
+	if err := set.html.ExecuteTemplate(&buf, name+htmlExt, d); err != nil {
+		return nil, err
+	}
+	msg.HTML = strings.TrimSpace(buf.String())
+	return msg, nil
+}
+
+// match returns the locale of the template which matches lang best,
+// falling back to the default language and then to any locale.
+func (t *templates) match(locales map[language.Tag]*templateSet, lang string) (language.Tag, *templateSet) {
+	desired, _, _ := language.ParseAcceptLanguage(lang)
+	_, index, _ := t.matcher.Match(desired...)
+	if set, ok := locales[t.tags[index]]; ok {
+		return t.tags[index], set
+	}
+	for _, tag := range t.tags {
+		if set, ok := locales[tag]; ok {
+			return tag, set
+		}
+	}
+	return language.Und, nil
+}
@@ -0,0 +1,85 @@
+package email
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func TestTemplates(t *testing.T) {
+	e := New(Config{DefaultLanguage: language.English})
+	require.Equal(t, nil, e.LoadTemplates("templates"))
+	require.Equal(t, []string{"account_deletion", "activation", "data_export", "invitation", "password_changed", "reset_password"}, e.Templates())
+
+	t.Run("renders the HTML and the plain-text part", func(t *testing.T) {
+		for _, name := range e.Templates() {
+			msg, err := e.Render(name, "en", map[string]interface{}{
+				"Login":     "<b>john</b>",
+				"Href":      "http://localhost:3000/1/activate/token",
+				"Password":  "secret",
+				"InvitedBy": "<b>john</b>",
+			})
+			require.Equal(t, nil, err)
+			require.NotEqual(t, "", msg.Subject)
+			require.Equal(t, true, strings.Contains(msg.HTML, "&lt;b&gt;john&lt;/b&gt;"))
+			require.Equal(t, true, strings.Contains(msg.HTML, "<title>"+msg.Subject+"</title>"))
+			require.Equal(t, true, strings.Contains(msg.Text, "<b>john</b>"))
+			require.Equal(t, false, strings.Contains(msg.Text, "<table"))
+		}
+	})
+
+	t.Run("falls back to the default language", func(t *testing.T) {
+		msg, err := e.Render("activation", "de-DE,de;q=0.9", nil)
+		require.Equal(t, nil, err)
+		require.Equal(t, language.English, msg.Language)
+	})
+
+	t.Run("unknown template", func(t *testing.T) {
+		_, err := e.Render("unknown", "en", nil)
+		require.NotEqual(t, nil, err)
+	})
+}
+
+func TestTemplatesLocales(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	require.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"layouts/layout.html.gohtml":   `{{define "layout"}}<div>{{template "content" .}}</div>{{end}}`,
+		"layouts/layout.txt.gohtml":    `{{define "layout"}}{{template "content" .}}{{end}}`,
+		"en/welcome.html.gohtml":       `{{template "layout" .}}{{define "content"}}Welcome{{end}}`,
+		"en/welcome.txt.gohtml":        `{{define "subject"}}Welcome{{end}}{{template "layout" .}}{{define "content"}}Welcome{{end}}`,
+		"pl/partials/name.html.gohtml": `{{define "name"}}Witaj{{end}}`,
+		"pl/partials/name.txt.gohtml":  `{{define "name"}}Witaj{{end}}`,
+		"pl/welcome.html.gohtml":       `{{template "layout" .}}{{define "content"}}{{template "name"}}{{end}}`,
+		"pl/welcome.txt.gohtml":        `{{define "subject"}}Witaj{{end}}{{template "layout" .}}{{define "content"}}{{template "name"}}{{end}}`,
+		"en/only_english.html.gohtml":  `{{template "layout" .}}{{define "content"}}English{{end}}`,
+		"en/only_english.txt.gohtml":   `{{define "subject"}}English{{end}}{{template "layout" .}}{{define "content"}}English{{end}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.Equal(t, nil, os.MkdirAll(filepath.Dir(path), 0755))
+		require.Equal(t, nil, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	e := New(Config{DefaultLanguage: language.English})
+	require.Equal(t, nil, e.LoadTemplates(dir))
+	require.Equal(t, []language.Tag{language.English, language.Polish}, e.Languages())
+
+	msg, err := e.Render("welcome", "pl-PL,en;q=0.5", nil)
+	require.Equal(t, nil, err)
+	require.Equal(t, language.Polish, msg.Language)
+	require.Equal(t, "Witaj", msg.Subject)
+	require.Equal(t, "<div>Witaj</div>", msg.HTML)
+
+	msg, err = e.Render("only_english", "pl", nil)
+	require.Equal(t, nil, err)
+	require.Equal(t, language.English, msg.Language)
+
+	require.Equal(t, nil, os.Remove(filepath.Join(dir, "pl/welcome.txt.gohtml")))
+	require.NotEqual(t, nil, e.LoadTemplates(dir))
+}
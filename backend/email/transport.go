@@ -0,0 +1,136 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	gomail "gopkg.in/gomail.v2"
+)
+
+// Transport delivers the composed messages.
+type Transport interface {
+	Send(msg *gomail.Message) error
+}
+
+type smtpTransport struct {
+	dialer *gomail.Dialer
+}
+
+func NewSMTPTransport(host string, port int, username, password string) Transport {
+	return &smtpTransport{gomail.NewDialer(host, port, username, password)}
+}
+
+func (t *smtpTransport) Send(msg *gomail.Message) error {
+	return t.dialer.DialAndSend(msg)
+}
+
+type fileTransport struct {
+	dir     string
+	counter uint64
+}
+
+// NewFileTransport returns the transport which writes the messages as .eml files to the maildir,
+// so they can be opened by a mail client during the development.
+func NewFileTransport(dir string) (Transport, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &fileTransport{dir: dir}, nil
+}
+
+func (t *fileTransport) Send(msg *gomail.Message) error {
+	name := fmt.Sprintf("%d.%d_%d.eml", time.Now().UnixNano(), os.Getpid(), atomic.AddUint64(&t.counter, 1))
+	tmp := filepath.Join(t.dir, "tmp", name)
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// the message appears in new only when it is complete
+	return os.Rename(tmp, filepath.Join(t.dir, "new", name))
+}
+
+type logTransport struct {
+	logrus *logrus.Entry
+}
+
+// NewLogTransport returns the transport which only logs the messages. The body contains tokens and passwords,
+// so it is logged at the debug level only.
+func NewLogTransport() Transport {
+	return &logTransport{logrus.WithField("package", "email")}
+}
+
+func (t *logTransport) Send(msg *gomail.Message) error {
+	entry := t.logrus.
+		WithField("to", msg.GetHeader("To")).
+		WithField("subject", msg.GetHeader("Subject"))
+	if !entry.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		entry.Info("Email")
+		return nil
+	}
+	var raw bytes.Buffer
+	if _, err := msg.WriteTo(&raw); err != nil {
+		return err
+	}
+	entry.Debugf("Email:\n%s", raw.String())
+	return nil
+}
+
+type SentMessage struct {
+	Message *gomail.Message
+	// Raw is the message in the MIME format
+	Raw    []byte
+	SentAt time.Time
+}
+
+// MemoryTransport records the messages, so tests can assert on them.
+type MemoryTransport struct {
+	mu       sync.Mutex
+	messages []SentMessage
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{}
+}
+
+func (t *MemoryTransport) Send(msg *gomail.Message) error {
+	var raw bytes.Buffer
+	if _, err := msg.WriteTo(&raw); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, SentMessage{msg, raw.Bytes(), time.Now()})
+	return nil
+}
+
+// Messages returns the recorded messages, the oldest first.
+func (t *MemoryTransport) Messages() []SentMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	messages := make([]SentMessage, len(t.messages))
+	copy(messages, t.messages)
+	return messages
+}
+
+func (t *MemoryTransport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = nil
+}
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+	gomail "gopkg.in/gomail.v2"
+)
+
+func newMessage(to string) *gomail.Message {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", "noreply@example.com")
+	msg.SetHeader("To", to)
+	msg.SetHeader("Subject", "Account activation")
+	msg.SetBody("text/html", "Hello!")
+	return msg
+}
+
+func TestFileTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maildir")
+	require.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+	transport, err := NewFileTransport(dir)
+	require.Equal(t, nil, err)
+
+	require.Equal(t, nil, transport.Send(newMessage("first@example.com")))
+	require.Equal(t, nil, transport.Send(newMessage("second@example.com")))
+
+	files, err := filepath.Glob(filepath.Join(dir, "new", "*.eml"))
+	require.Equal(t, nil, err)
+	require.Equal(t, 2, len(files))
+	tmp, _ := filepath.Glob(filepath.Join(dir, "tmp", "*"))
+	require.Equal(t, 0, len(tmp))
+	content, err := ioutil.ReadFile(files[0])
+	require.Equal(t, nil, err)
+	require.Equal(t, true, strings.Contains(string(content), "Subject: Account activation"))
+}
+
+func TestMemoryTransport(t *testing.T) {
+	transport := NewMemoryTransport()
+	e := New(Config{Transport: transport, Address: "noreply@example.com"})
+
+	require.Equal(t, nil, e.Send(newMessage("first@example.com")))
+	require.Equal(t, nil, e.Send(newMessage("second@example.com")))
+
+	messages := transport.Messages()
+	require.Equal(t, 2, len(messages))
+	require.Equal(t, []string{"second@example.com"}, messages[1].Message.GetHeader("To"))
+	require.Equal(t, true, strings.Contains(string(messages[0].Raw), "To: first@example.com"))
+
+	transport.Reset()
+	require.Equal(t, 0, len(transport.Messages()))
+}
+
+func TestLogTransport(t *testing.T) {
+	hook := test.NewGlobal()
+	defer logrus.SetLevel(logrus.GetLevel())
+	msg := newMessage("first@example.com")
+	msg.SetBody("text/plain", "token")
+
+	logrus.SetLevel(logrus.InfoLevel)
+	require.Equal(t, nil, NewLogTransport().Send(msg))
+	require.Equal(t, logrus.InfoLevel, hook.LastEntry().Level)
+	require.Equal(t, false, strings.Contains(hook.LastEntry().Message, "token"))
+
+	logrus.SetLevel(logrus.DebugLevel)
+	require.Equal(t, nil, NewLogTransport().Send(msg))
+	require.Equal(t, logrus.DebugLevel, hook.LastEntry().Level)
+	require.Equal(t, true, strings.Contains(hook.LastEntry().Message, "token"))
+}
@@ -0,0 +1,7 @@
+package errors
+
+const (
+	ErrEmailNotFound    = "email.notFoundError"
+	ErrEmailAlreadySent = "email.alreadySentError"
+	ErrEmailDataDeleted = "email.dataDeletedError"
+)
@@ -4,21 +4,97 @@ import (
 	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
-func Wrap(msg string, errors ...error) error {
+const (
+	codeExtension     = "code"
+	fieldExtension    = "field"
+	paramsExtension   = "params"
+	internalExtension = "internal"
+)
+
+// Debug exposes the internal errors in the extensions of formatted errors.
+var Debug = false
+
+// Params are passed to the translation of the error and exposed in its extensions.
+type Params map[string]interface{}
+
+// Wrap returns an error with the stable code (the i18n message id), the wrapped errors are internal.
+func Wrap(code string, errors ...error) error {
+	return newError(code, "", nil, errors)
+}
+
+func WrapWithParams(code string, params Params, errors ...error) error {
+	return newError(code, "", params, errors)
+}
+
+// WrapField returns an error caused by the value of the given input field.
+func WrapField(code, field string, params Params, errors ...error) error {
+	return newError(code, field, params, errors)
+}
+
+func newError(code, field string, params Params, errors []error) *gqlerror.Error {
+	extensions := map[string]interface{}{
+		codeExtension:     code,
+		internalExtension: errors,
+	}
+	if field != "" {
+		extensions[fieldExtension] = field
+	}
+	if len(params) > 0 {
+		extensions[paramsExtension] = params
+	}
 	return &gqlerror.Error{
-		Message: msg,
-		Extensions: map[string]interface{}{
-			"detailed": errors,
-		},
+		Message:    code,
+		Extensions: extensions,
 	}
-
 }
 
+// ToGqlError converts err to *gqlerror.Error, errors not created by this package are treated as internal.
 func ToGqlError(err error) *gqlerror.Error {
 	if gqlError, ok := err.(*gqlerror.Error); ok {
 		return gqlError
 	}
-	return &gqlerror.Error{
-		Message: err.Error(),
+	return newError(ErrInternalServerError, "", nil, []error{err})
+}
+
+// HasCode reports whether the error was created by this package, unlike e.g. the errors of the parser.
+func HasCode(err *gqlerror.Error) bool {
+	_, ok := err.Extensions[codeExtension].(string)
+	return ok
+}
+
+func Code(err *gqlerror.Error) string {
+	if code, ok := err.Extensions[codeExtension].(string); ok {
+		return code
+	}
+	return err.Message
+}
+
+// Internal returns the wrapped errors.
+func Internal(err *gqlerror.Error) []error {
+	internal, _ := err.Extensions[internalExtension].([]error)
+	return internal
+}
+
+func GetParams(err *gqlerror.Error) Params {
+	params, _ := err.Extensions[paramsExtension].(Params)
+	return params
+}
+
+// HideInternal removes the internal errors from the extensions or, in the debug mode,
+// replaces them with their messages.
+func HideInternal(err *gqlerror.Error) {
+	if err.Extensions == nil {
+		return
+	}
+	if !Debug {
+		delete(err.Extensions, internalExtension)
+		return
+	}
+	if internal, ok := err.Extensions[internalExtension].([]error); ok {
+		messages := make([]string, len(internal))
+		for i, e := range internal {
+			messages[i] = e.Error()
+		}
+		err.Extensions[internalExtension] = messages
 	}
 }
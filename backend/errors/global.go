@@ -6,4 +6,8 @@ const (
 	ErrTokenExpired         = "global.tokenExpiredError"
 	ErrRegistrationDisabled = "global.registrationDisabledError"
 	ErrInvalidPayload       = "global.invalidPayloadError"
+	ErrQueryTooComplex      = "global.queryTooComplexError"
+	ErrQueryTooDeep         = "global.queryTooDeepError"
+	ErrOperationNotAllowed  = "global.operationNotAllowedError"
+	ErrTimeout              = "global.timeoutError"
 )
@@ -0,0 +1,12 @@
+package errors
+
+const (
+	ErrInvitationNotFound        = "invitation.notFoundError"
+	ErrInvalidInviteCode         = "invitation.invalidCodeError"
+	ErrInviteCodeExpired         = "invitation.expiredError"
+	ErrInviteCodeUsedUp          = "invitation.usedUpError"
+	ErrInviteCodeEmailMismatch   = "invitation.emailMismatchError"
+	ErrInvitationMaxUsesPolicy   = "invitation.maxUsesPolicyError"
+	ErrInvitationExpiresAtPolicy = "invitation.expiresAtPolicyError"
+	ErrInviteCodeTaken           = "invitation.codeTakenError"
+)
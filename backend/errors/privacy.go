@@ -0,0 +1,11 @@
+package errors
+
+const (
+	ErrDataExportNotFound          = "privacy.dataExportNotFoundError"
+	ErrDataExportTokenTaken        = "privacy.dataExportTokenTakenError"
+	ErrAccountDeletionScheduled    = "privacy.accountDeletionScheduledError"
+	ErrAccountDeletionNotScheduled = "privacy.accountDeletionNotScheduledError"
+	ErrWrongDeletionToken          = "privacy.wrongDeletionTokenError"
+	// ErrAccountDeletionGracePeriodOver is returned when the account waits for the purge.
+	ErrAccountDeletionGracePeriodOver = "privacy.accountDeletionGracePeriodOverError"
+)
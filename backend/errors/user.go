@@ -6,8 +6,22 @@ const (
 	ErrLoginMustBeUnique  = "user.loginMustBeUniqueError"
 	ErrEmailMustBeUnique  = "user.emailMustBeUniqueError"
 	ErrLoginPolicy        = "user.loginPolicyError"
+	ErrLoginContainsAt    = "user.loginContainsAtError"
 	ErrDisplayNamePolicy  = "user.displayNamePolicyError"
 	ErrPasswordPolicy     = "user.passwordPolicyError"
+	ErrPasswordTooCommon  = "user.passwordTooCommonError"
+	ErrPasswordTooWeak    = "user.passwordTooWeakError"
+	ErrPasswordUserData   = "user.passwordContainsUserDataError"
+	ErrPasswordBreached   = "user.passwordBreachedError"
 	ErrEmailPolicy        = "user.emailPolicyError"
 	ErrInvalidUserRole    = "user.invalidUserRoleError"
+	ErrUnsupportedLocale  = "user.unsupportedLocaleError"
+	ErrBioPolicy          = "user.bioPolicyError"
+	ErrInvalidTimezone    = "user.invalidTimezoneError"
+	ErrAvatarTooLarge     = "user.avatarTooLargeError"
+	ErrUnsupportedImage   = "user.unsupportedImageError"
+	ErrAvatarDimensions   = "user.avatarDimensionsError"
+	ErrImportFile         = "user.importFileError"
+	ErrUnknownImportField = "user.unknownImportFieldError"
+	ErrPasswordHash       = "user.passwordHashError"
 )
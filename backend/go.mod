@@ -9,12 +9,13 @@ require (
 	github.com/golang/protobuf v1.3.5 // indirect
 	github.com/google/uuid v1.1.1
 	github.com/gorilla/sessions v1.2.0
-	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/gorilla/websocket v1.4.2
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
 	github.com/konsorten/go-windows-terminal-sequences v1.0.2 // indirect
 	github.com/labstack/echo-contrib v0.8.0
 	github.com/labstack/echo/v4 v4.1.16
-	github.com/mitchellh/mapstructure v1.3.0 // indirect
+	github.com/labstack/gommon v0.3.0
+	github.com/mitchellh/mapstructure v1.3.0
 	github.com/nicksnyder/go-i18n/v2 v2.0.3
 	github.com/sethvargo/go-password v0.1.3
 	github.com/sirupsen/logrus v1.5.0
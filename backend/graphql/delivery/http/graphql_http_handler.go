@@ -3,20 +3,47 @@ package http
 import (
 	"backend/graphql/directives"
 	"backend/graphql/generated"
+	"backend/graphql/limits"
+	"backend/graphql/persistedquery"
 	"backend/graphql/resolvers"
+	"backend/utils"
 	"fmt"
+	"net/http"
 	"os"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 )
 
-func NewGraphqlHandler(g *echo.Group, r *resolvers.Resolver) error {
-	if r == nil {
+type Config struct {
+	Resolver *resolvers.Resolver
+	// AllowOrigins is checked against the Origin header of websocket upgrade requests.
+	AllowOrigins     []string
+	Limits           limits.Config
+	DefaultListLimit int
+	MaxListLimit     int
+	// PersistedQueries caches the queries sent with the automatic persisted queries protocol.
+	PersistedQueries graphql.Cache
+	// Allowlist enables the strict mode, only the operations it contains can be executed.
+	Allowlist graphql.Cache
+	// MaxUploadSize limits the multipart requests with files, 0 means the default of gqlgen.
+	MaxUploadSize int64
+}
+
+func NewGraphqlHandler(g *echo.Group, cfg Config) error {
+	if cfg.Resolver == nil {
 		return fmt.Errorf("Graphql resolver cannot be nil")
 	}
-	g.POST("/graphql", graphqlHandler(r))
+	h := graphqlHandler(cfg)
+	g.POST("/graphql", h)
+	g.GET("/graphql", h)
 	if os.Getenv("MODE") == "development" {
 		g.GET("/playground", playgroundHandler())
 	}
@@ -24,15 +51,49 @@ func NewGraphqlHandler(g *echo.Group, r *resolvers.Resolver) error {
 }
 
 // Defining the Graphql handler
-func graphqlHandler(r *resolvers.Resolver) echo.HandlerFunc {
+func graphqlHandler(cfg Config) echo.HandlerFunc {
 	// NewExecutableSchema and Config are in the generated.go file
 	// Resolver is in the resolver.go file
 	directivesHandler := &directives.Handler{}
-	cfg := generated.Config{Resolvers: r}
-	cfg.Directives.Activated = directivesHandler.Activated
-	cfg.Directives.HasRole = directivesHandler.HasRole
-	cfg.Directives.Authenticated = directivesHandler.Authenticated
-	h := handler.NewDefaultServer(generated.NewExecutableSchema(cfg))
+	gqlCfg := generated.Config{Resolvers: cfg.Resolver}
+	gqlCfg.Directives.Activated = directivesHandler.Activated
+	gqlCfg.Directives.HasRole = directivesHandler.HasRole
+	gqlCfg.Directives.Authenticated = directivesHandler.Authenticated
+	limits.SetCosts(&gqlCfg.Complexity, cfg.DefaultListLimit, cfg.MaxListLimit)
+	h := handler.New(generated.NewExecutableSchema(gqlCfg))
+
+	// the session cookie is sent with the upgrade request, so subscriptions
+	// are authenticated by the same middleware as queries and mutations
+	h.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: checkOrigin(cfg.AllowOrigins),
+		},
+	})
+	h.AddTransport(transport.Options{})
+	h.AddTransport(transport.GET{})
+	h.AddTransport(transport.POST{})
+	h.AddTransport(transport.MultipartForm{
+		MaxUploadSize: cfg.MaxUploadSize,
+	})
+
+	h.SetQueryCache(lru.New(1000))
+	h.SetErrorPresenter(utils.PresentError)
+
+	h.Use(extension.Introspection{})
+	h.Use(limits.New(cfg.Limits))
+	if cfg.Allowlist != nil {
+		h.Use(persistedquery.Allowlist{
+			Operations: cfg.Allowlist,
+		})
+	} else {
+		if cfg.PersistedQueries == nil {
+			cfg.PersistedQueries = lru.New(100)
+		}
+		h.Use(extension.AutomaticPersistedQuery{
+			Cache: cfg.PersistedQueries,
+		})
+	}
 
 	return func(c echo.Context) error {
 		h.ServeHTTP(c.Response(), c.Request())
@@ -49,3 +110,18 @@ func playgroundHandler() echo.HandlerFunc {
 		return nil
 	}
 }
+
+func checkOrigin(allowOrigins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range allowOrigins {
+			if allowed == "*" || allowed == origin {
+				return true
+			}
+		}
+		return false
+	}
+}
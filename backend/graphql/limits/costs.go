@@ -0,0 +1,63 @@
+package limits
+
+import (
+	"backend/graphql/generated"
+	"backend/models"
+	"backend/utils"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+const (
+	// passwordHashingCost is added to the fields which hash or compare passwords
+	passwordHashingCost = 10
+	// imageProcessingCost is added to the fields which decode and resize images
+	imageProcessingCost = 50
+)
+
+// SetCosts assigns costs to the fields which are more expensive than reading a column,
+// lists are multiplied by the number of items they can return.
+func SetCosts(c *generated.ComplexityRoot, defaultListLimit, maxListLimit int) {
+	c.Query.Users = func(childComplexity int, filter *models.UserFilter) int {
+		limit := 0
+		if filter != nil {
+			limit = filter.Limit
+		}
+		return 1 + utils.NormalizeLimit(limit, defaultListLimit, maxListLimit)*childComplexity
+	}
+	c.Query.Emails = func(childComplexity int, filter *models.EmailFilter) int {
+		limit := 0
+		if filter != nil {
+			limit = filter.Limit
+		}
+		return 1 + utils.NormalizeLimit(limit, defaultListLimit, maxListLimit)*childComplexity
+	}
+	c.Query.Invitations = func(childComplexity int, filter *models.InvitationFilter) int {
+		limit := 0
+		if filter != nil {
+			limit = filter.Limit
+		}
+		return 1 + utils.NormalizeLimit(limit, defaultListLimit, maxListLimit)*childComplexity
+	}
+	c.Mutation.DeleteUser = func(childComplexity int, ids []int) int {
+		return 1 + len(ids)*childComplexity
+	}
+	c.Mutation.Signup = func(childComplexity int, user models.UserInput, inviteCode *string) int {
+		return passwordHashingCost + childComplexity
+	}
+	c.Mutation.Signin = func(childComplexity int, login string, password string) int {
+		return passwordHashingCost + childComplexity
+	}
+	c.Mutation.CreateUser = func(childComplexity int, input models.UserInput) int {
+		return passwordHashingCost + childComplexity
+	}
+	c.Mutation.UpdateUser = func(childComplexity int, id int, input models.UserInput) int {
+		return passwordHashingCost + childComplexity
+	}
+	c.Mutation.DeleteMyAccount = func(childComplexity int, password string) int {
+		return passwordHashingCost + childComplexity
+	}
+	c.Mutation.UploadAvatar = func(childComplexity int, file graphql.Upload) int {
+		return imageProcessingCost + childComplexity
+	}
+}
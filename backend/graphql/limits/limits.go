@@ -0,0 +1,138 @@
+package limits
+
+import (
+	"backend/errors"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const extensionName = "Limits"
+
+type Budgets struct {
+	Anonymous     int
+	Authenticated int
+	Admin         int
+}
+
+type Config struct {
+	// MaxDepth limits the nesting of fields, 0 means no limit.
+	MaxDepth int
+	// Complexity is the maximum complexity of an operation for each kind of caller, 0 means no limit.
+	Complexity Budgets
+}
+
+type Stats struct {
+	Depth         int
+	Complexity    int
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// Limiter rejects operations that are nested too deeply or whose complexity exceeds the budget of the caller.
+type Limiter struct {
+	cfg Config
+	es  graphql.ExecutableSchema
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = &Limiter{}
+
+func New(cfg Config) *Limiter {
+	return &Limiter{cfg: cfg}
+}
+
+func (l *Limiter) ExtensionName() string {
+	return extensionName
+}
+
+func (l *Limiter) Validate(schema graphql.ExecutableSchema) error {
+	l.es = schema
+	return nil
+}
+
+func (l *Limiter) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	op := rc.Doc.Operations.ForName(rc.OperationName)
+	if op == nil {
+		return nil
+	}
+	stats := &Stats{
+		Depth:         selectionSetDepth(op.SelectionSet, map[string]bool{}),
+		Complexity:    complexity.Calculate(l.es, op, rc.Variables),
+		MaxDepth:      l.cfg.MaxDepth,
+		MaxComplexity: l.budget(ctx),
+	}
+	rc.Stats.SetExtension(extensionName, stats)
+
+	if stats.MaxDepth > 0 && stats.Depth > stats.MaxDepth {
+		return errors.ToGqlError(utils.FormatErrorMsg(ctx, errors.WrapWithParams(errors.ErrQueryTooDeep, errors.Params{
+			"depth":    stats.Depth,
+			"maxDepth": stats.MaxDepth,
+		})))
+	}
+	if stats.MaxComplexity > 0 && stats.Complexity > stats.MaxComplexity {
+		return errors.ToGqlError(utils.FormatErrorMsg(ctx, errors.WrapWithParams(errors.ErrQueryTooComplex, errors.Params{
+			"complexity":    stats.Complexity,
+			"maxComplexity": stats.MaxComplexity,
+		})))
+	}
+	return nil
+}
+
+func (l *Limiter) budget(ctx context.Context) int {
+	user, err := middleware.UserFromContext(ctx)
+	if err != nil {
+		return l.cfg.Complexity.Anonymous
+	}
+	if user.Role == models.UserAdminRole {
+		return l.cfg.Complexity.Admin
+	}
+	return l.cfg.Complexity.Authenticated
+}
+
+func GetStats(ctx context.Context) *Stats {
+	rc := graphql.GetOperationContext(ctx)
+	if rc == nil {
+		return nil
+	}
+
+	s, _ := rc.Stats.GetExtension(extensionName).(*Stats)
+	return s
+}
+
+// selectionSetDepth returns the depth of the deepest field, introspection fields are not counted.
+func selectionSetDepth(set ast.SelectionSet, visited map[string]bool) int {
+	max := 0
+	for _, selection := range set {
+		depth := 0
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			depth = 1 + selectionSetDepth(s.SelectionSet, visited)
+		case *ast.InlineFragment:
+			depth = selectionSetDepth(s.SelectionSet, visited)
+		case *ast.FragmentSpread:
+			if s.Definition == nil || visited[s.Name] {
+				continue
+			}
+			visited[s.Name] = true
+			depth = selectionSetDepth(s.Definition.SelectionSet, visited)
+			delete(visited, s.Name)
+		}
+		if depth > max {
+			max = depth
+		}
+	}
+	return max
+}
@@ -0,0 +1,126 @@
+package limits
+
+import (
+	"context"
+	"testing"
+
+	_errors "backend/errors"
+	"backend/graphql/generated"
+	_i18n "backend/i18n"
+	"backend/middleware"
+	"backend/models"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func TestLimiter(t *testing.T) {
+	require.Equal(t, nil, _i18n.LoadMessageFiles("../../i18n/locales"))
+	c := generated.ComplexityRoot{}
+	SetCosts(&c, 10, 50)
+	es := generated.NewExecutableSchema(generated.Config{Complexity: c})
+
+	newLimiter := func(cfg Config) *Limiter {
+		l := New(cfg)
+		require.Equal(t, nil, l.Validate(es))
+		return l
+	}
+	load := func(query string) *ast.QueryDocument {
+		doc, errs := gqlparser.LoadQuery(es.Schema(), query)
+		require.Equal(t, 0, len(errs), errs.Error())
+		return doc
+	}
+	localizer := i18n.NewLocalizer(_i18n.Bundle, "en")
+	// LocalizerToContext runs before every operation
+	run := func(ctx context.Context, l *Limiter, doc *ast.QueryDocument) (*Stats, *gqlerror.Error) {
+		rc := &graphql.OperationContext{Doc: doc, Variables: map[string]interface{}{}}
+		err := l.MutateOperationContext(middleware.StoreLocalizerInContext(ctx, localizer), rc)
+		stats, _ := rc.Stats.GetExtension(extensionName).(*Stats)
+		return stats, err
+	}
+	code := func(err *gqlerror.Error) string {
+		if err == nil {
+			return ""
+		}
+		return _errors.Code(err)
+	}
+	withUser := func(role int) context.Context {
+		return middleware.StoreUserInContext(context.Background(), &models.User{ID: 1, Role: role})
+	}
+
+	t.Run("Depth", func(t *testing.T) {
+		l := newLimiter(Config{MaxDepth: 3})
+		stats, err := run(context.Background(), l, load(`{ users { items { login } } __schema { types { fields { name } } } }`))
+		require.Equal(t, "", code(err))
+		// the introspection fields aren't counted
+		require.Equal(t, 3, stats.Depth)
+
+		_, err = run(context.Background(), l, load(`query { users { items { login } } ...Deep } fragment Deep on Query { user(id: 1) { login } me { id } users { items { id } total } }`))
+		require.Equal(t, "", code(err))
+
+		l = newLimiter(Config{MaxDepth: 2})
+		stats, err = run(context.Background(), l, load(`{ ...List } fragment List on Query { users { ...Items } } fragment Items on UserList { items { login } }`))
+		require.Equal(t, _errors.ErrQueryTooDeep, code(err))
+		require.Equal(t, 3, stats.Depth)
+	})
+
+	t.Run("Fragment cycles", func(t *testing.T) {
+		// the validation rejects the cycles, the depth must terminate anyway
+		fragment := &ast.FragmentDefinition{Name: "Cycle"}
+		fragment.SelectionSet = ast.SelectionSet{
+			&ast.Field{Name: "login"},
+			&ast.Field{Name: "friend", SelectionSet: ast.SelectionSet{&ast.FragmentSpread{Name: "Cycle", Definition: fragment}}},
+		}
+		set := ast.SelectionSet{&ast.Field{Name: "me", SelectionSet: ast.SelectionSet{&ast.FragmentSpread{Name: "Cycle", Definition: fragment}}}}
+		require.Equal(t, 2, selectionSetDepth(set, map[string]bool{}))
+	})
+
+	t.Run("List costs", func(t *testing.T) {
+		l := newLimiter(Config{})
+		stats, err := run(context.Background(), l, load(`{ users { items { id } } }`))
+		require.Equal(t, "", code(err))
+		// 1 + the default limit * (items + id)
+		require.Equal(t, 21, stats.Complexity)
+
+		stats, _ = run(context.Background(), l, load(`{ users(filter: {limit: 5}) { items { id } } }`))
+		require.Equal(t, 11, stats.Complexity)
+		// the limit is capped by the max limit
+		stats, _ = run(context.Background(), l, load(`{ users(filter: {limit: 1000}) { items { id } } }`))
+		require.Equal(t, 101, stats.Complexity)
+
+		stats, _ = run(context.Background(), l, load(`mutation { signin(login: "john", password: "Password123") { id } }`))
+		require.Equal(t, passwordHashingCost+1, stats.Complexity)
+	})
+
+	t.Run("Budgets", func(t *testing.T) {
+		l := newLimiter(Config{Complexity: Budgets{Anonymous: 20, Authenticated: 50, Admin: 200}})
+		list := load(`{ users(filter: {limit: 30}) { items { id } } }`)
+
+		stats, err := run(context.Background(), l, list)
+		require.Equal(t, _errors.ErrQueryTooComplex, code(err))
+		require.Equal(t, 20, stats.MaxComplexity)
+		stats, err = run(withUser(models.UserDefaultRole), l, list)
+		require.Equal(t, _errors.ErrQueryTooComplex, code(err))
+		require.Equal(t, 50, stats.MaxComplexity)
+		stats, err = run(withUser(models.UserAdminRole), l, list)
+		require.Equal(t, "", code(err))
+		require.Equal(t, 200, stats.MaxComplexity)
+
+		_, err = run(withUser(models.UserDefaultRole), l, load(`{ users(filter: {limit: 10}) { items { id } } }`))
+		require.Equal(t, "", code(err))
+	})
+
+	t.Run("Localized errors", func(t *testing.T) {
+		l := newLimiter(Config{MaxDepth: 1, Complexity: Budgets{Anonymous: 5}})
+		_, err := run(context.Background(), l, load(`{ users { total } }`))
+		require.Equal(t, "The query is nested too deeply (2), the maximum allowed depth is 1.", err.Message)
+
+		l = newLimiter(Config{Complexity: Budgets{Anonymous: 5}})
+		_, err = run(context.Background(), l, load(`{ users { total } }`))
+		require.Equal(t, "The query is too complex (11), the maximum allowed complexity is 5.", err.Message)
+	})
+}
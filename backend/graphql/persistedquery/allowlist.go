@@ -0,0 +1,75 @@
+package persistedquery
+
+import (
+	"backend/errors"
+	"backend/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/mitchellh/mapstructure"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Allowlist executes only the operations registered ahead of time. It understands the automatic persisted
+// queries protocol, so it replaces extension.AutomaticPersistedQuery in the strict mode.
+type Allowlist struct {
+	Operations graphql.Cache
+}
+
+var _ interface {
+	graphql.OperationParameterMutator
+	graphql.HandlerExtension
+} = Allowlist{}
+
+func (a Allowlist) ExtensionName() string {
+	return "OperationAllowlist"
+}
+
+func (a Allowlist) Validate(schema graphql.ExecutableSchema) error {
+	if a.Operations == nil {
+		return fmt.Errorf("Allowlist.Operations can not be nil")
+	}
+	return nil
+}
+
+func (a Allowlist) MutateOperationParameters(ctx context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	var extension struct {
+		Sha256 string `mapstructure:"sha256Hash"`
+	}
+	if rawParams.Extensions["persistedQuery"] != nil {
+		if err := mapstructure.Decode(rawParams.Extensions["persistedQuery"], &extension); err != nil {
+			return gqlerror.Errorf("invalid APQ extension data")
+		}
+	}
+
+	if rawParams.Query == "" {
+		query, ok := a.Operations.Get(ctx, extension.Sha256)
+		if extension.Sha256 == "" || !ok {
+			return notAllowed(ctx)
+		}
+		rawParams.Query = query.(string)
+		return nil
+	}
+
+	hash := Hash(rawParams.Query)
+	if extension.Sha256 != "" && extension.Sha256 != hash {
+		return gqlerror.Errorf("provided APQ hash does not match query")
+	}
+	if _, ok := a.Operations.Get(ctx, hash); !ok {
+		return notAllowed(ctx)
+	}
+	return nil
+}
+
+// Hash computes the hash of the query in the same way as the automatic persisted queries clients.
+func Hash(query string) string {
+	b := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(b[:])
+}
+
+func notAllowed(ctx context.Context) *gqlerror.Error {
+	return errors.ToGqlError(utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrOperationNotAllowed)))
+}
@@ -0,0 +1,114 @@
+package persistedquery
+
+import (
+	"backend/models"
+	"backend/postgres"
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/sirupsen/logrus"
+)
+
+const localCacheSize = 1000
+
+// postgreCache keeps the queries in the persisted_queries table, so they are shared between replicas.
+// The hot queries are additionally kept in a local LRU cache.
+type postgreCache struct {
+	conn           postgres.DB
+	local          *lru.LRU
+	registeredOnly bool
+	logrus         *logrus.Entry
+}
+
+// NewPostgreCache returns the cache for automatic persisted queries,
+// queries added by clients are stored as not registered.
+func NewPostgreCache(conn postgres.DB) (graphql.Cache, error) {
+	return newPostgreCache(conn, false)
+}
+
+// Registry is the cache of the registered operations for Allowlist.
+type Registry interface {
+	graphql.Cache
+	// Register stores the operation as registered, unlike Add it reports the failures.
+	Register(ctx context.Context, hash, query string) error
+}
+
+// NewPostgreAllowlist returns the cache which contains only registered operations,
+// adding a query registers it.
+func NewPostgreAllowlist(conn postgres.DB) (Registry, error) {
+	return newPostgreCache(conn, true)
+}
+
+func newPostgreCache(conn postgres.DB, registeredOnly bool) (*postgreCache, error) {
+	log := logrus.WithField("package", "graphql/persistedquery")
+	if err := conn.CreateTable((*models.PersistedQuery)(nil), &orm.CreateTableOptions{
+		IfNotExists: true,
+	}); err != nil {
+		log.Debugf("Cannot create persisted query table: %s", err.Error())
+		return nil, err
+	}
+	return &postgreCache{
+		conn,
+		lru.New(localCacheSize),
+		registeredOnly,
+		log,
+	}, nil
+}
+
+func (c *postgreCache) Get(ctx context.Context, hash string) (interface{}, bool) {
+	if query, ok := c.local.Get(ctx, hash); ok {
+		return query, true
+	}
+	pq := &models.PersistedQuery{}
+	query := c.conn.
+		ModelContext(ctx, pq).
+		Where("hash = ?", hash)
+	if c.registeredOnly {
+		query = query.Where("registered = true")
+	}
+	if err := query.Limit(1).Select(); err != nil {
+		c.logrus.WithField("hash", hash).Debugf("Get err: %s", err.Error())
+		return nil, false
+	}
+	c.local.Add(ctx, hash, pq.Query)
+	return pq.Query, true
+}
+
+func (c *postgreCache) Add(ctx context.Context, hash string, value interface{}) {
+	query, ok := value.(string)
+	if !ok {
+		return
+	}
+	if err := c.insert(ctx, hash, query, c.registeredOnly); err != nil {
+		c.logrus.WithField("hash", hash).Debugf("Add err: %s", err.Error())
+	}
+}
+
+func (c *postgreCache) Register(ctx context.Context, hash, query string) error {
+	if err := c.insert(ctx, hash, query, true); err != nil {
+		c.logrus.WithField("hash", hash).Debugf("Register err: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (c *postgreCache) insert(ctx context.Context, hash, query string, registered bool) error {
+	pq := &models.PersistedQuery{
+		Hash:       hash,
+		Query:      query,
+		Registered: registered,
+	}
+	insert := c.conn.ModelContext(ctx, pq)
+	if registered {
+		insert = insert.OnConflict("(hash) DO UPDATE").Set("registered = true")
+	} else {
+		insert = insert.OnConflict("DO NOTHING")
+	}
+	if _, err := insert.Insert(); err != nil {
+		return err
+	}
+	c.local.Add(ctx, hash, query)
+	return nil
+}
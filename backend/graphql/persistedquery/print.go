@@ -0,0 +1,211 @@
+package persistedquery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Print formats the document exactly like the print function from graphql-js, the clients
+// hash its output. When addTypename is true, __typename is added to every selection set
+// except the operation roots, just like the Apollo cache does before sending a query.
+func Print(doc *ast.QueryDocument, addTypename bool) string {
+	p := printer{addTypename}
+	definitions := []string{}
+	for _, op := range doc.Operations {
+		definitions = append(definitions, p.operation(op))
+	}
+	for _, fragment := range doc.Fragments {
+		definitions = append(definitions, p.fragment(fragment))
+	}
+	return join(definitions, "\n\n") + "\n"
+}
+
+type printer struct {
+	addTypename bool
+}
+
+func (p printer) operation(op *ast.OperationDefinition) string {
+	varDefs := wrap("(", p.variableDefinitions(op.VariableDefinitions), ")")
+	directives := p.directives(op.Directives)
+	selectionSet := p.selectionSet(op.SelectionSet, false)
+	if op.Name == "" && directives == "" && varDefs == "" && op.Operation == ast.Query {
+		return selectionSet
+	}
+	return join([]string{string(op.Operation), op.Name + varDefs, directives, selectionSet}, " ")
+}
+
+func (p printer) fragment(f *ast.FragmentDefinition) string {
+	return "fragment " + f.Name + wrap("(", p.variableDefinitions(f.VariableDefinition), ")") + " " +
+		"on " + f.TypeCondition + " " + wrap("", p.directives(f.Directives), " ") +
+		p.selectionSet(f.SelectionSet, true)
+}
+
+func (p printer) variableDefinitions(defs ast.VariableDefinitionList) string {
+	printed := []string{}
+	for _, def := range defs {
+		s := "$" + def.Variable + ": " + def.Type.String()
+		if def.DefaultValue != nil {
+			s += " = " + p.value(def.DefaultValue)
+		}
+		printed = append(printed, s)
+	}
+	return join(printed, ", ")
+}
+
+func (p printer) selectionSet(set ast.SelectionSet, withTypename bool) string {
+	selections := []string{}
+	hasTypename := false
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				hasTypename = true
+			}
+			name := s.Name
+			if s.Alias != "" && s.Alias != s.Name {
+				name = s.Alias + ": " + s.Name
+			}
+			selections = append(selections, join([]string{
+				name + wrap("(", p.arguments(s.Arguments), ")"),
+				p.directives(s.Directives),
+				p.selectionSet(s.SelectionSet, true),
+			}, " "))
+		case *ast.FragmentSpread:
+			selections = append(selections, "..."+s.Name+wrap(" ", p.directives(s.Directives), ""))
+		case *ast.InlineFragment:
+			selections = append(selections, join([]string{
+				"...",
+				wrap("on ", s.TypeCondition, ""),
+				p.directives(s.Directives),
+				p.selectionSet(s.SelectionSet, true),
+			}, " "))
+		}
+	}
+	if len(selections) == 0 {
+		return ""
+	}
+	if p.addTypename && withTypename && !hasTypename {
+		selections = append(selections, "__typename")
+	}
+	return "{\n" + indent(join(selections, "\n")) + "\n}"
+}
+
+func (p printer) arguments(args ast.ArgumentList) string {
+	printed := []string{}
+	for _, arg := range args {
+		printed = append(printed, arg.Name+": "+p.value(arg.Value))
+	}
+	return join(printed, ", ")
+}
+
+func (p printer) directives(directives ast.DirectiveList) string {
+	printed := []string{}
+	for _, d := range directives {
+		printed = append(printed, "@"+d.Name+wrap("(", p.arguments(d.Arguments), ")"))
+	}
+	return join(printed, " ")
+}
+
+func (p printer) value(v *ast.Value) string {
+	switch v.Kind {
+	case ast.Variable:
+		return "$" + v.Raw
+	case ast.StringValue:
+		return quote(v.Raw)
+	case ast.BlockValue:
+		return blockString(v.Raw, "  ")
+	case ast.ListValue:
+		values := []string{}
+		for _, child := range v.Children {
+			values = append(values, p.value(child.Value))
+		}
+		return "[" + join(values, ", ") + "]"
+	case ast.ObjectValue:
+		fields := []string{}
+		for _, child := range v.Children {
+			fields = append(fields, child.Name+": "+p.value(child.Value))
+		}
+		return "{" + join(fields, ", ") + "}"
+	default:
+		return v.Raw
+	}
+}
+
+// quote escapes the string like JSON.stringify, which graphql-js uses for the string values.
+func quote(s string) string {
+	b := strings.Builder{}
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// blockString prints the block string like printBlockString from graphql-js.
+func blockString(value, indentation string) string {
+	isSingleLine := !strings.Contains(value, "\n")
+	hasLeadingSpace := strings.HasPrefix(value, " ") || strings.HasPrefix(value, "\t")
+	hasTrailingQuote := strings.HasSuffix(value, `"`)
+	printAsMultipleLines := !isSingleLine || hasTrailingQuote
+	result := ""
+	if printAsMultipleLines && !(isSingleLine && hasLeadingSpace) {
+		result += "\n" + indentation
+	}
+	if indentation != "" {
+		result += strings.ReplaceAll(value, "\n", "\n"+indentation)
+	} else {
+		result += value
+	}
+	if printAsMultipleLines {
+		result += "\n"
+	}
+	return `"""` + strings.ReplaceAll(result, `"""`, `\"""`) + `"""`
+}
+
+func join(parts []string, separator string) string {
+	nonEmpty := []string{}
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, separator)
+}
+
+func wrap(start, s, end string) string {
+	if s == "" {
+		return ""
+	}
+	return start + s + end
+}
+
+func indent(s string) string {
+	if s == "" {
+		return s
+	}
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
@@ -0,0 +1,92 @@
+package persistedquery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+func TestPrint(t *testing.T) {
+	t.Run("anonymous query uses the short form", func(t *testing.T) {
+		doc, err := parser.ParseQuery(&ast.Source{Input: `{ me { id } }`})
+		require.Nil(t, err)
+		require.Equal(t, "{\n  me {\n    id\n  }\n}\n", Print(doc, false))
+	})
+
+	t.Run("__typename is added like the Apollo cache does", func(t *testing.T) {
+		doc, err := parser.ParseQuery(&ast.Source{Input: `
+			mutation signin($login: String!, $password: String!) {
+				signin(login: $login, password: $password) { id ...F }
+			}
+			fragment F on User { slug }
+		`})
+		require.Nil(t, err)
+		require.Equal(t, `mutation signin($login: String!, $password: String!) {
+  signin(login: $login, password: $password) {
+    id
+    ...F
+    __typename
+  }
+}
+
+fragment F on User {
+  slug
+  __typename
+}
+`, Print(doc, true))
+	})
+
+	t.Run("arguments, aliases and directives", func(t *testing.T) {
+		doc, err := parser.ParseQuery(&ast.Source{Input: `query q {
+			a: users(filter: {limit: 5, order: ["id DESC"]}) @include(if: true) { total }
+		}`})
+		require.Nil(t, err)
+		require.Equal(t, `query q {
+  a: users(filter: {limit: 5, order: ["id DESC"]}) @include(if: true) {
+    total
+  }
+}
+`, Print(doc, false))
+	})
+
+	t.Run("strings are escaped like JSON.stringify", func(t *testing.T) {
+		doc, err := parser.ParseQuery(&ast.Source{Input: `{ users(filter: {login: ["a\"b\\c\n\t\u0001é\u2028/"]}) { total } }`})
+		require.Nil(t, err)
+		require.Equal(t, "{\n  users(filter: {login: [\"a\\\"b\\\\c\\n\\t\\u0001é\u2028/\"]}) {\n    total\n  }\n}\n", Print(doc, false))
+	})
+
+	t.Run("block strings", func(t *testing.T) {
+		doc, err := parser.ParseQuery(&ast.Source{Input: `{
+			a: users(filter: {login: ["""
+				hello
+				  world
+			"""]}) { total }
+			b: users(filter: {login: ["""single"""]}) { total }
+			c: users(filter: {login: ["""say "hi" \""""""]}) { total }
+			d: users(filter: {login: ["""  indented \""" quotes"""]}) { total }
+		}`})
+		require.Nil(t, err)
+		require.Equal(t, `{
+  a: users(filter: {login: ["""
+    hello
+      world
+  """]}) {
+    total
+  }
+  b: users(filter: {login: ["""single"""]}) {
+    total
+  }
+  c: users(filter: {login: ["""
+    say "hi" \"""
+  """]}) {
+    total
+  }
+  d: users(filter: {login: ["""  indented \""" quotes"""]}) {
+    total
+  }
+}
+`, Print(doc, false))
+	})
+}
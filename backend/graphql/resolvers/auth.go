@@ -7,22 +7,16 @@ import (
 	"backend/models"
 	"backend/utils"
 	"context"
-	"fmt"
 
 	"github.com/labstack/echo-contrib/session"
 )
 
-const (
-	activateAccountEmailTitle   = "activateAccountEmailTitle"
-	activateAccountEmailContent = "activateAccountEmailContent"
-	resetPasswordEmailTitle     = "resetPasswordEmailTitle"
-	resetPasswordEmailContent   = "resetPasswordEmailContent"
-	passwordChangedEmailTitle   = "passwordChangedEmailTitle"
-	passwordChangedEmailContent = "passwordChangedEmailContent"
-)
-
-func (r *mutationResolver) Signup(ctx context.Context, input models.UserInput) (*models.User, error) {
-	user, err := r.AuthUcase.Signup(ctx, input)
+func (r *mutationResolver) Signup(ctx context.Context, input models.UserInput, inviteCode *string) (*models.User, error) {
+	code := ""
+	if inviteCode != nil {
+		code = *inviteCode
+	}
+	user, err := r.AuthUcase.Signup(ctx, input, code)
 	if err != nil {
 		return nil, utils.FormatErrorMsg(ctx, err)
 	}
@@ -35,19 +29,8 @@ func (r *mutationResolver) Signup(ctx context.Context, input models.UserInput) (
 	if err != nil {
 		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrInternalServerError, err))
 	}
-	sess.Values["login"] = user.Login
-	sess.Values["password"] = user.Password
+	auth.StoreUserInSession(sess, user)
 	sess.Save(echoCtx.Request(), echoCtx.Response())
-	go func() {
-		sendEmail(ctx,
-			activateAccountEmailTitle,
-			activateAccountEmailContent,
-			user.Email,
-			map[string]interface{}{
-				"Login": user.Login,
-				"Href":  fmt.Sprintf("%s/%d/activate/%s", r.FrontendURL, user.ID, user.ActivationToken),
-			})
-	}()
 
 	return user, nil
 }
@@ -66,8 +49,7 @@ func (r *mutationResolver) Signin(ctx context.Context, login string, password st
 	if err != nil {
 		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrInternalServerError, err))
 	}
-	sess.Values["login"] = user.Login
-	sess.Values["password"] = user.Password
+	auth.StoreUserInSession(sess, user)
 	sess.Save(echoCtx.Request(), echoCtx.Response())
 	return user, nil
 }
@@ -81,8 +63,7 @@ func (r *mutationResolver) Signout(ctx context.Context) (*string, error) {
 	if err != nil {
 		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrInternalServerError, err))
 	}
-	delete(sess.Values, "login")
-	delete(sess.Values, "password")
+	auth.ClearSession(sess)
 	sess.Save(echoCtx.Request(), echoCtx.Response())
 	msg := "Success"
 	return &msg, nil
@@ -93,39 +74,17 @@ func (r *mutationResolver) GenerateNewActivationTokenForMe(ctx context.Context)
 	if err != nil {
 		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrMustBeLoggedIn, err))
 	}
-	user, err = r.AuthUcase.GenerateNewActivationToken(ctx, user.ID)
-	if err != nil {
+	if _, err := r.AuthUcase.GenerateNewActivationToken(ctx, user.ID); err != nil {
 		return nil, utils.FormatErrorMsg(ctx, err)
 	}
-	go func() {
-		sendEmail(ctx,
-			activateAccountEmailTitle,
-			activateAccountEmailContent,
-			user.Email,
-			map[string]interface{}{
-				"Login": user.Login,
-				"Href":  fmt.Sprintf("%s/%d/activate/%s", r.FrontendURL, user.ID, user.ActivationToken),
-			})
-	}()
 	msg := "Success"
 	return &msg, nil
 }
 
 func (r *mutationResolver) GenerateNewResetPasswordToken(ctx context.Context, email string) (*string, error) {
-	user, err := r.AuthUcase.GenerateNewResetPasswordToken(ctx, email)
-	if err != nil {
+	if _, err := r.AuthUcase.GenerateNewResetPasswordToken(ctx, email); err != nil {
 		return nil, utils.FormatErrorMsg(ctx, err)
 	}
-	go func() {
-		sendEmail(ctx,
-			resetPasswordEmailTitle,
-			resetPasswordEmailContent,
-			user.Email,
-			map[string]interface{}{
-				"Login": user.Login,
-				"Href":  fmt.Sprintf("%s/%d/reset-password/%s", r.FrontendURL, user.ID, user.ResetPasswordToken),
-			})
-	}()
 	msg := "Success"
 	return &msg, nil
 }
@@ -144,20 +103,10 @@ func (r *queryResolver) ActivateUserAccount(ctx context.Context, id int, token s
 }
 
 func (r *queryResolver) ResetUserPassword(ctx context.Context, id int, token string) (*string, error) {
-	user, password, err := r.AuthUcase.ResetPassword(ctx, id, token)
+	_, _, err := r.AuthUcase.ResetPassword(ctx, id, token)
 	if err != nil {
 		return nil, utils.FormatErrorMsg(ctx, err)
 	}
-	go func() {
-		sendEmail(ctx,
-			passwordChangedEmailTitle,
-			passwordChangedEmailContent,
-			user.Email,
-			map[string]interface{}{
-				"Login":    user.Login,
-				"Password": password,
-			})
-	}()
 	msg := "Success"
 	return &msg, nil
 }
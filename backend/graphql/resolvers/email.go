@@ -1,54 +1,23 @@
 package resolvers
 
 import (
-	"backend/email"
-	"backend/middleware"
+	"backend/models"
+	"backend/utils"
 	"context"
-
-	"github.com/nicksnyder/go-i18n/v2/i18n"
-	"gopkg.in/gomail.v2"
 )
 
-func sendEmail(ctx context.Context, title, content, to string, data map[string]interface{}) error {
-	localizer, err := middleware.LocalizerFromContext(ctx)
-	if err != nil {
-		return err
-	}
-	msg := gomail.NewMessage()
-	msg.SetHeader("From", email.GetAddress())
-	msg.SetHeader("To", to)
-	t, err := localizer.Localize(&i18n.LocalizeConfig{
-		MessageID:    title,
-		TemplateData: data,
-		DefaultMessage: &i18n.Message{
-			ID:    title,
-			One:   title,
-			Other: title,
-		},
-	})
+func (r *mutationResolver) RetryEmail(ctx context.Context, id int) (*models.Email, error) {
+	e, err := r.OutboxUcase.Retry(ctx, id)
 	if err != nil {
-		return err
+		return nil, utils.FormatErrorMsg(ctx, err)
 	}
-	c, err := localizer.Localize(&i18n.LocalizeConfig{
-		MessageID:    content,
-		TemplateData: data,
-		DefaultMessage: &i18n.Message{
-			ID:    content,
-			One:   content,
-			Other: content,
-		},
-	})
-	if err != nil {
-		return err
-	}
-	msg.SetHeader("Subject", t)
-	body, err := email.GetTemplate("default.gohtml", map[string]interface{}{
-		"Title":   t,
-		"Content": c,
-	})
+	return e, nil
+}
+
+func (r *queryResolver) Emails(ctx context.Context, filter *models.EmailFilter) (*models.EmailList, error) {
+	list, err := r.OutboxUcase.Fetch(ctx, filter)
 	if err != nil {
-		return err
+		return nil, utils.FormatErrorMsg(ctx, err)
 	}
-	msg.SetBody("text/html", body)
-	return email.Send(msg)
+	return &list, nil
 }
@@ -0,0 +1,71 @@
+package resolvers
+
+import (
+	"backend/errors"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+	"context"
+)
+
+func (r *queryResolver) Invitations(ctx context.Context, filter *models.InvitationFilter) (*models.InvitationList, error) {
+	list, err := r.InvitationUcase.Fetch(ctx, filter)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, err)
+	}
+	return &list, nil
+}
+
+func (r *mutationResolver) CreateInvitation(ctx context.Context, input models.InvitationInput) (*models.Invitation, error) {
+	me, err := middleware.UserFromContext(ctx)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrMustBeLoggedIn, err))
+	}
+	inv, err := r.InvitationUcase.Create(ctx, me, input)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, err)
+	}
+	return inv, nil
+}
+
+func (r *mutationResolver) DeleteInvitation(ctx context.Context, ids []int) ([]*models.Invitation, error) {
+	invitations, err := r.InvitationUcase.Delete(ctx, ids...)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, err)
+	}
+	return invitations, nil
+}
+
+func (r *invitationResolver) CreatedBy(ctx context.Context, obj *models.Invitation) (*models.User, error) {
+	return r.loadUser(ctx, obj.CreatedByID)
+}
+
+func (r *invitationResolver) Redemptions(ctx context.Context, obj *models.Invitation) ([]*models.InvitationRedemption, error) {
+	redemptions, err := r.InvitationUcase.GetRedemptions(ctx, obj.ID)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, err)
+	}
+	return redemptions, nil
+}
+
+func (r *invitationRedemptionResolver) User(ctx context.Context, obj *models.InvitationRedemption) (*models.User, error) {
+	return r.loadUser(ctx, obj.UserID)
+}
+
+// loadUser returns nil when the user has been deleted since.
+func (r *Resolver) loadUser(ctx context.Context, id int) (*models.User, error) {
+	var user *models.User
+	var err error
+	if loaders, loadersErr := middleware.DataloadersFromContext(ctx); loadersErr == nil {
+		user, err = loaders.UserByID.Load(id)
+	} else {
+		user, err = r.UserUcase.GetByID(ctx, id)
+	}
+	if err != nil {
+		if errors.Code(errors.ToGqlError(err)) == errors.ErrUserNotFound {
+			return nil, nil
+		}
+		return nil, utils.FormatErrorMsg(ctx, err)
+	}
+	return user, nil
+}
@@ -0,0 +1,55 @@
+package resolvers
+
+import (
+	"backend/auth"
+	"backend/errors"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+	"context"
+
+	"github.com/labstack/echo-contrib/session"
+)
+
+func (r *mutationResolver) RequestMyDataExport(ctx context.Context) (*models.DataExport, error) {
+	me, err := middleware.UserFromContext(ctx)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrMustBeLoggedIn, err))
+	}
+	export, err := r.PrivacyUcase.RequestDataExport(ctx, me.ID)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, err)
+	}
+	return export, nil
+}
+
+func (r *mutationResolver) DeleteMyAccount(ctx context.Context, password string) (*models.User, error) {
+	me, err := middleware.UserFromContext(ctx)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrMustBeLoggedIn, err))
+	}
+	user, err := r.PrivacyUcase.RequestAccountDeletion(ctx, me.ID, password)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, err)
+	}
+
+	echoCtx, err := middleware.EchoContextFromContext(ctx)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrInternalServerError, err))
+	}
+	sess, err := session.Get(auth.SessionName, echoCtx)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrInternalServerError, err))
+	}
+	auth.ClearSession(sess)
+	sess.Save(echoCtx.Request(), echoCtx.Response())
+	return user, nil
+}
+
+func (r *mutationResolver) CancelAccountDeletion(ctx context.Context, id int, token string) (*models.User, error) {
+	user, err := r.PrivacyUcase.CancelAccountDeletion(ctx, id, token)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, err)
+	}
+	return user, nil
+}
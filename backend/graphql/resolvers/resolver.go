@@ -5,13 +5,21 @@ package resolvers
 import (
 	"backend/auth"
 	"backend/graphql/generated"
+	"backend/invitation"
+	"backend/outbox"
+	"backend/privacy"
 	"backend/user"
 )
 
 type Resolver struct {
-	FrontendURL string
-	AuthUcase   auth.Usecase
-	UserUcase   user.Usecase
+	AuthUcase       auth.Usecase
+	UserUcase       user.Usecase
+	UserEvents      user.Events
+	OutboxUcase     outbox.Usecase
+	PrivacyUcase    privacy.Usecase
+	InvitationUcase invitation.Usecase
+	// StorageURL is the public URL of the storage, the avatar URLs start with it.
+	StorageURL string
 }
 
 // Mutation returns generated.MutationResolver implementation.
@@ -20,5 +28,23 @@ func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResol
 // Query returns generated.QueryResolver implementation.
 func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 
+// User returns generated.UserResolver implementation.
+func (r *Resolver) User() generated.UserResolver { return &userResolver{r} }
+
+// Invitation returns generated.InvitationResolver implementation.
+func (r *Resolver) Invitation() generated.InvitationResolver { return &invitationResolver{r} }
+
+// InvitationRedemption returns generated.InvitationRedemptionResolver implementation.
+func (r *Resolver) InvitationRedemption() generated.InvitationRedemptionResolver {
+	return &invitationRedemptionResolver{r}
+}
+
+// Subscription returns generated.SubscriptionResolver implementation.
+func (r *Resolver) Subscription() generated.SubscriptionResolver { return &subscriptionResolver{r} }
+
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
+type invitationResolver struct{ *Resolver }
+type invitationRedemptionResolver struct{ *Resolver }
@@ -0,0 +1,55 @@
+package resolvers
+
+import (
+	"backend/errors"
+	"backend/middleware"
+	"backend/models"
+	"backend/utils"
+	"context"
+)
+
+func (r *subscriptionResolver) UserUpdated(ctx context.Context, id int) (<-chan *models.User, error) {
+	ch, err := r.UserEvents.UserUpdated(ctx, id)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrInternalServerError, err))
+	}
+	return ch, nil
+}
+
+func (r *subscriptionResolver) MyAccountChanged(ctx context.Context) (<-chan *models.AccountEvent, error) {
+	user, err := middleware.UserFromContext(ctx)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrMustBeLoggedIn, err))
+	}
+	subCtx, cancel := context.WithCancel(ctx)
+	events, err := r.UserEvents.AccountChanged(subCtx, user.ID)
+	if err != nil {
+		cancel()
+		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrInternalServerError, err))
+	}
+	ch := make(chan *models.AccountEvent, 1)
+	go func() {
+		defer close(ch)
+		defer cancel()
+		for event := range events {
+			select {
+			case ch <- event:
+			case <-ctx.Done():
+				return
+			}
+			// the session is no longer valid, so the subscription ends together with it
+			if event.Type == models.AccountEventTypeLoggedOut {
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (r *subscriptionResolver) UserCreated(ctx context.Context) (<-chan *models.User, error) {
+	ch, err := r.UserEvents.UserCreated(ctx)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrInternalServerError, err))
+	}
+	return ch, nil
+}
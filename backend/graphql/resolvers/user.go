@@ -1,10 +1,15 @@
 package resolvers
 
 import (
+	"backend/avatar"
 	"backend/errors"
+	"backend/middleware"
 	"backend/models"
 	"backend/utils"
 	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
 )
 
 func (r *mutationResolver) CreateUser(ctx context.Context, input models.UserInput) (*models.User, error) {
@@ -31,6 +36,60 @@ func (r *mutationResolver) DeleteUser(ctx context.Context, ids []int) ([]*models
 	return users, nil
 }
 
+func (r *mutationResolver) UpdateMe(ctx context.Context, input models.ProfileInput) (*models.User, error) {
+	me, err := middleware.UserFromContext(ctx)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrMustBeLoggedIn, err))
+	}
+	user, err := r.UserUcase.UpdateProfile(ctx, me.ID, input)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, err)
+	}
+	return user, nil
+}
+
+func (r *mutationResolver) UploadAvatar(ctx context.Context, file graphql.Upload) (*models.User, error) {
+	me, err := middleware.UserFromContext(ctx)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, errors.Wrap(errors.ErrMustBeLoggedIn, err))
+	}
+	user, err := r.UserUcase.UploadAvatar(ctx, me.ID, file.File, file.Size)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, err)
+	}
+	return user, nil
+}
+
+// ImportUsers reads the format from the extension of the file when it isn't given.
+func (r *mutationResolver) ImportUsers(ctx context.Context, file graphql.Upload, format *models.UserFileFormat, dryRun *bool) (*models.UserImport, error) {
+	f := models.UserFileFormatCSV
+	if format != nil {
+		f = *format
+	} else if strings.HasSuffix(strings.ToLower(file.Filename), ".json") || strings.HasSuffix(strings.ToLower(file.Filename), ".jsonl") {
+		f = models.UserFileFormatJSON
+	}
+	result, err := r.UserUcase.Import(ctx, file.File, f, dryRun != nil && *dryRun)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, err)
+	}
+	for _, e := range result.Errors {
+		e.Message = utils.Localize(ctx, e.Code, e.Params)
+	}
+	return result, nil
+}
+
+func (r *userResolver) AvatarURL(ctx context.Context, obj *models.User, size *int) (*string, error) {
+	if obj.Avatar == "" {
+		return nil, nil
+	}
+	requested := 0
+	if size != nil {
+		requested = *size
+	}
+	url := strings.TrimSuffix(r.StorageURL, "/") + "/" + avatar.Key(obj.Avatar, avatar.Size(requested))
+	return &url, nil
+}
+
 func (r *queryResolver) Users(ctx context.Context, filter *models.UserFilter) (*models.UserList, error) {
 	list, err := r.UserUcase.Fetch(ctx, filter)
 	if err != nil {
@@ -42,10 +101,19 @@ func (r *queryResolver) Users(ctx context.Context, filter *models.UserFilter) (*
 func (r *queryResolver) User(ctx context.Context, id *int, slug *string) (*models.User, error) {
 	var user *models.User
 	var err error
+	loaders, loadersErr := middleware.DataloadersFromContext(ctx)
 	if id != nil {
-		user, err = r.UserUcase.GetByID(ctx, *id)
+		if loadersErr == nil {
+			user, err = loaders.UserByID.Load(*id)
+		} else {
+			user, err = r.UserUcase.GetByID(ctx, *id)
+		}
 	} else if slug != nil {
-		user, err = r.UserUcase.GetBySlug(ctx, *slug)
+		if loadersErr == nil {
+			user, err = loaders.UserBySlug.Load(*slug)
+		} else {
+			user, err = r.UserUcase.GetBySlug(ctx, *slug)
+		}
 	} else {
 		err = errors.Wrap(errors.ErrInvalidPayload)
 	}
@@ -54,3 +122,21 @@ func (r *queryResolver) User(ctx context.Context, id *int, slug *string) (*model
 	}
 	return user, nil
 }
+
+func (r *queryResolver) CheckPasswordStrength(ctx context.Context, password string, login *string, email *string) (*models.PasswordStrength, error) {
+	var l, e string
+	if login != nil {
+		l = *login
+	}
+	if email != nil {
+		e = *email
+	}
+	strength, err := r.UserUcase.CheckPasswordStrength(ctx, password, l, e)
+	if err != nil {
+		return nil, utils.FormatErrorMsg(ctx, err)
+	}
+	for _, violation := range strength.Violations {
+		violation.Message = utils.Localize(ctx, violation.Code, violation.Params)
+	}
+	return strength, nil
+}
@@ -0,0 +1,87 @@
+// Package hasher hashes passwords into encoded hashes which contain the algorithm and its parameters,
+// so the hashes created with the previous settings can still be verified and upgraded.
+package hasher
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrMismatch is returned when the password doesn't match the hash.
+var ErrMismatch = errors.New("hasher: the password doesn't match the hash")
+
+// ErrUnknownFormat is returned for hashes which no hasher recognizes.
+var ErrUnknownFormat = errors.New("hasher: unknown hash format")
+
+type Hasher interface {
+	// Hash returns the encoded hash of the password.
+	Hash(password string) (string, error)
+	// Compare returns ErrMismatch when the password doesn't match the encoded hash.
+	Compare(encoded, password string) error
+	// Recognizes reports whether the encoded hash has the format of the hasher.
+	Recognizes(encoded string) bool
+	// NeedsRehash reports whether the encoded hash was created with other parameters.
+	NeedsRehash(encoded string) bool
+}
+
+type upgrading struct {
+	current Hasher
+	legacy  []Hasher
+}
+
+// NewUpgrading returns the hasher which hashes the passwords with current and verifies the hashes
+// of all hashers. The hashes of the legacy hashers need a rehash.
+func NewUpgrading(current Hasher, legacy ...Hasher) Hasher {
+	return &upgrading{current, legacy}
+}
+
+func (h *upgrading) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+func (h *upgrading) Compare(encoded, password string) error {
+	if h.current.Recognizes(encoded) {
+		return h.current.Compare(encoded, password)
+	}
+	for _, legacy := range h.legacy {
+		if legacy.Recognizes(encoded) {
+			return legacy.Compare(encoded, password)
+		}
+	}
+	return ErrUnknownFormat
+}
+
+func (h *upgrading) Recognizes(encoded string) bool {
+	if h.current.Recognizes(encoded) {
+		return true
+	}
+	for _, legacy := range h.legacy {
+		if legacy.Recognizes(encoded) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *upgrading) NeedsRehash(encoded string) bool {
+	return !h.current.Recognizes(encoded) || h.current.NeedsRehash(encoded)
+}
+
+var (
+	mutex         sync.RWMutex
+	defaultHasher Hasher = NewUpgrading(NewArgon2id(DefaultArgon2Params()), NewBcrypt(DefaultBcryptCost))
+)
+
+// Default returns the hasher of the user passwords.
+func Default() Hasher {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return defaultHasher
+}
+
+// SetDefault replaces the hasher of the user passwords, it is called when the config is loaded.
+func SetDefault(h Hasher) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	defaultHasher = h
+}
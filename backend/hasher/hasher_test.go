@@ -0,0 +1,54 @@
+package hasher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHasher(t *testing.T) {
+	fastArgon2id := NewArgon2id(Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1})
+	fastBcrypt := NewBcrypt(bcrypt.MinCost)
+	password := "Żółw123!"
+
+	for name, h := range map[string]Hasher{"argon2id": fastArgon2id, "bcrypt": fastBcrypt} {
+		t.Run(name, func(t *testing.T) {
+			encoded, err := h.Hash(password)
+			require.Equal(t, nil, err)
+			require.Equal(t, true, h.Recognizes(encoded))
+			require.Equal(t, false, h.NeedsRehash(encoded))
+			require.Equal(t, nil, h.Compare(encoded, password))
+			require.Equal(t, ErrMismatch, h.Compare(encoded, password+"1"))
+			// the hash itself is not the password
+			require.NotEqual(t, nil, h.Compare(encoded, encoded))
+			require.Equal(t, false, h.Recognizes(password))
+		})
+	}
+
+	t.Run("argon2id encoding", func(t *testing.T) {
+		encoded, err := fastArgon2id.Hash(password)
+		require.Equal(t, nil, err)
+		require.Equal(t, true, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+		require.Equal(t, true, NewArgon2id(Argon2Params{Memory: 2048, Iterations: 1, Parallelism: 1}).NeedsRehash(encoded))
+		require.Equal(t, false, fastArgon2id.Recognizes(strings.Replace(encoded, "v=19", "v=16", 1)))
+	})
+
+	t.Run("upgrading", func(t *testing.T) {
+		legacy, err := fastBcrypt.Hash(password)
+		require.Equal(t, nil, err)
+		h := NewUpgrading(fastArgon2id, fastBcrypt)
+		require.Equal(t, true, h.Recognizes(legacy))
+		require.Equal(t, nil, h.Compare(legacy, password))
+		require.Equal(t, true, h.NeedsRehash(legacy))
+
+		upgraded, err := h.Hash(password)
+		require.Equal(t, nil, err)
+		require.Equal(t, false, h.NeedsRehash(upgraded))
+		require.Equal(t, nil, h.Compare(upgraded, password))
+
+		require.Equal(t, ErrUnknownFormat, h.Compare("plaintext", "plaintext"))
+		require.Equal(t, true, NewUpgrading(NewBcrypt(bcrypt.MinCost+1)).NeedsRehash(legacy))
+	})
+}
@@ -22,3 +22,26 @@ func LoadMessageFiles(root string) error {
 		return nil
 	})
 }
+
+// IsSupported checks if messages in the locale are loaded into Bundle.
+func IsSupported(locale string) bool {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return false
+	}
+	for _, t := range Bundle.LanguageTags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchLanguage returns the locale loaded into Bundle which matches the Accept-Language header best,
+// or the default language.
+func MatchLanguage(accept string) string {
+	tags := Bundle.LanguageTags()
+	desired, _, _ := language.ParseAcceptLanguage(accept)
+	_, index, _ := language.NewMatcher(tags).Match(desired...)
+	return tags[index].String()
+}
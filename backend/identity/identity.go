@@ -0,0 +1,45 @@
+// Package identity canonicalizes the logins and emails, so the identifiers which look the same
+// to the users are the same for the sign in and the uniqueness checks.
+package identity
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps the lowercase letters of the other scripts to the Latin letters which look the same,
+// it is a small subset of the Unicode confusables (UTS #39) which covers the usual spoofing attempts.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j', 'к': 'k', 'ӏ': 'l',
+	'о': 'o', 'р': 'p', 'ԛ': 'q', 'ѕ': 's', 'у': 'y', 'ү': 'y', 'ԝ': 'w', 'х': 'x',
+	// Greek
+	'α': 'a', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'υ': 'u', 'χ': 'x',
+	// Latin
+	'ɡ': 'g', 'ı': 'i', 'ȷ': 'j',
+}
+
+// Canonical returns the canonical form of the login or the email: NFKC normalized, case folded,
+// without the invisible format characters and with the confusable letters replaced by the Latin ones.
+func Canonical(s string) string {
+	s = cases.Fold().String(norm.NFKC.String(strings.TrimSpace(s)))
+	s = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		if latin, ok := confusables[r]; ok {
+			return latin
+		}
+		return r
+	}, s)
+	// the case folding can produce the not normalized strings
+	return norm.NFKC.String(s)
+}
+
+// IsEmail reports whether the identifier is an email, the logins cannot contain @ in the canonical form.
+func IsEmail(s string) bool {
+	return strings.Contains(Canonical(s), "@")
+}
@@ -0,0 +1,25 @@
+package identity
+
+import "testing"
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"johndoe", "johndoe"},
+		{" JohnDoe ", "johndoe"},
+		{"John.Doe@Example.COM", "john.doe@example.com"},
+		{"ｊｏｈｎｄｏｅ", "johndoe"},
+		{"jоhndоe", "johndoe"},
+		{"john​doe", "johndoe"},
+		{"Straße", "strasse"},
+		{"ΑΡΗΣ", "apησ"},
+		{"josé", "josé"},
+		{"josé", "josé"},
+	}
+	for _, test := range tests {
+		if out := Canonical(test.in); out != test.out {
+			t.Errorf("Canonical(%q) = %q, want %q", test.in, out, test.out)
+		}
+	}
+}
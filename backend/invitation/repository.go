@@ -0,0 +1,18 @@
+package invitation
+
+import (
+	"context"
+
+	"backend/models"
+)
+
+type Repository interface {
+	Store(ctx context.Context, inv *models.Invitation) error
+	Fetch(ctx context.Context, f *models.InvitationFilter) (models.InvitationList, error)
+	// GetByCode locks the invitation until the transaction carried by ctx ends, so concurrent signups cannot exceed the limit.
+	GetByCode(ctx context.Context, code string) (*models.Invitation, error)
+	Update(ctx context.Context, inv *models.Invitation) error
+	Delete(ctx context.Context, ids []int) ([]*models.Invitation, error)
+	StoreRedemption(ctx context.Context, r *models.InvitationRedemption) error
+	GetRedemptions(ctx context.Context, invitationID int) ([]*models.InvitationRedemption, error)
+}
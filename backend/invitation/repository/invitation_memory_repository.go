@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"backend/invitation"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	_errors "backend/errors"
+	"backend/models"
+)
+
+type memoryRepository struct {
+	mutex       sync.Mutex
+	invitations map[int]*models.Invitation
+	redemptions []*models.InvitationRedemption
+	nextID      int
+	logrus      *logrus.Entry
+}
+
+// NewMemoryInvitationRepository returns the repository which keeps the invitations in memory, it is meant for the unit tests.
+// GetByCode takes no lock, the concurrent signups are serialized by the transactor of the test.
+func NewMemoryInvitationRepository() invitation.Repository {
+	return &memoryRepository{
+		invitations: map[int]*models.Invitation{},
+		logrus:      logrus.WithField("package", "invitation/repository"),
+	}
+}
+
+func (repo *memoryRepository) Store(ctx context.Context, inv *models.Invitation) error {
+	repo.logrus.WithField("invitation", inv).Debug("Store")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for _, stored := range repo.invitations {
+		if stored.Code == inv.Code {
+			return _errors.Wrap(_errors.ErrInviteCodeTaken)
+		}
+	}
+	repo.nextID++
+	inv.ID = repo.nextID
+	inv.CreatedAt = time.Now()
+	stored := *inv
+	repo.invitations[inv.ID] = &stored
+	return nil
+}
+
+func (repo *memoryRepository) Fetch(ctx context.Context, f *models.InvitationFilter) (models.InvitationList, error) {
+	repo.logrus.WithField("filter", f).Debug("Fetch")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	invitations := []*models.Invitation{}
+	for _, inv := range repo.invitations {
+		copy := *inv
+		invitations = append(invitations, &copy)
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		return invitations[i].ID > invitations[j].ID
+	})
+	list := models.InvitationList{Total: len(invitations)}
+	if f != nil {
+		if f.Offset >= len(invitations) {
+			invitations = invitations[:0]
+		} else {
+			invitations = invitations[f.Offset:]
+		}
+		if f.Limit > 0 && f.Limit < len(invitations) {
+			invitations = invitations[:f.Limit]
+		}
+	}
+	list.Items = invitations
+	return list, nil
+}
+
+func (repo *memoryRepository) GetByCode(ctx context.Context, code string) (*models.Invitation, error) {
+	repo.logrus.WithField("code", code).Debug("GetByCode")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	code = strings.ToUpper(strings.TrimSpace(code))
+	for _, inv := range repo.invitations {
+		if inv.Code == code {
+			copy := *inv
+			return &copy, nil
+		}
+	}
+	return nil, _errors.Wrap(_errors.ErrInvitationNotFound)
+}
+
+func (repo *memoryRepository) Update(ctx context.Context, inv *models.Invitation) error {
+	repo.logrus.WithField("id", inv.ID).Debug("Update")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if _, ok := repo.invitations[inv.ID]; !ok {
+		return _errors.Wrap(_errors.ErrInvitationNotFound)
+	}
+	stored := *inv
+	repo.invitations[inv.ID] = &stored
+	return nil
+}
+
+func (repo *memoryRepository) Delete(ctx context.Context, ids []int) ([]*models.Invitation, error) {
+	repo.logrus.WithField("ids", ids).Debug("Delete")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	invitations := []*models.Invitation{}
+	for _, id := range ids {
+		if inv, ok := repo.invitations[id]; ok {
+			invitations = append(invitations, inv)
+			delete(repo.invitations, id)
+		}
+	}
+	return invitations, nil
+}
+
+func (repo *memoryRepository) StoreRedemption(ctx context.Context, r *models.InvitationRedemption) error {
+	repo.logrus.WithField("invitationID", r.InvitationID).WithField("userID", r.UserID).Debug("StoreRedemption")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if r.UserID == 0 {
+		return _errors.Wrap(_errors.ErrUserNotFound)
+	}
+	r.ID = len(repo.redemptions) + 1
+	r.CreatedAt = time.Now()
+	stored := *r
+	repo.redemptions = append(repo.redemptions, &stored)
+	return nil
+}
+
+func (repo *memoryRepository) GetRedemptions(ctx context.Context, invitationID int) ([]*models.InvitationRedemption, error) {
+	repo.logrus.WithField("invitationID", invitationID).Debug("GetRedemptions")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	redemptions := []*models.InvitationRedemption{}
+	for _, r := range repo.redemptions {
+		if r.InvitationID == invitationID {
+			copy := *r
+			redemptions = append(redemptions, &copy)
+		}
+	}
+	return redemptions, nil
+}
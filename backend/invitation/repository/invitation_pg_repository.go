@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"backend/invitation"
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	_errors "backend/errors"
+	"backend/models"
+	"backend/postgres"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+)
+
+const (
+	createIndex = `
+		CREATE INDEX IF NOT EXISTS invitation_redemptions_invitation_id_idx
+		ON invitation_redemptions (invitation_id);
+	`
+)
+
+var constraintErrors = postgres.NewErrorRegistry().
+	Unique("invitations_code_key", _errors.ErrInviteCodeTaken, "").
+	NotNull("invitations", "role", _errors.ErrInvalidUserRole, "role").
+	NotNull("invitation_redemptions", "user_id", _errors.ErrUserNotFound, "")
+
+type postgreRepository struct {
+	postgres.DB
+	timeouts postgres.Timeouts
+	logrus   *logrus.Entry
+}
+
+func NewPostgreInvitationRepository(conn postgres.DB, timeouts postgres.Timeouts) (invitation.Repository, error) {
+	log := logrus.WithField("package", "invitation/repository")
+	for _, model := range []interface{}{(*models.Invitation)(nil), (*models.InvitationRedemption)(nil)} {
+		if err := conn.CreateTable(model, &orm.CreateTableOptions{
+			IfNotExists: true,
+		}); err != nil {
+			log.Debugf("Cannot create invitations tables: %s", err.Error())
+			return nil, err
+		}
+	}
+	if _, err := conn.Exec(createIndex); err != nil {
+		log.Debugf("Cannot create invitation redemptions index: %s", err.Error())
+		return nil, err
+	}
+	return &postgreRepository{conn,
+		timeouts,
+		log,
+	}, nil
+}
+
+func (repo *postgreRepository) Store(ctx context.Context, inv *models.Invitation) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "invitation.Store")
+	defer cancel()
+	log := repo.logrus.WithField("invitation", inv)
+	log.Debug("Store")
+	if _, err := postgres.Conn(ctx, repo.DB).
+		ModelContext(ctx, inv).
+		Returning("*").
+		Insert(); err != nil {
+		log.Debugf("Store err: %s", err.Error())
+		return constraintErrors.Translate(ctx, err, _errors.ErrInternalServerError)
+	}
+	return nil
+}
+
+func (repo *postgreRepository) Fetch(ctx context.Context, f *models.InvitationFilter) (models.InvitationList, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "invitation.Fetch")
+	defer cancel()
+	var err error
+	invitations := []*models.Invitation{}
+	pagination := models.InvitationList{}
+	query := postgres.ReadConn(ctx, repo.DB).ModelContext(ctx, &invitations).Order("created_at DESC")
+	log := repo.logrus.WithField("filter", f)
+	log.Debug("Fetch")
+
+	if f != nil {
+		query = query.
+			Limit(f.Limit).
+			Offset(f.Offset)
+	}
+
+	if pagination.Total, err = query.
+		SelectAndCount(); err != nil && err != pg.ErrNoRows {
+		log.Debugf("Fetch err: %s", err.Error())
+		return pagination, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	pagination.Items = invitations
+
+	return pagination, nil
+}
+
+func (repo *postgreRepository) GetByCode(ctx context.Context, code string) (*models.Invitation, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "invitation.GetByCode")
+	defer cancel()
+	inv := &models.Invitation{}
+	log := repo.logrus.WithField("code", code)
+	log.Debug("GetByCode")
+	// the locking read joins the transaction but isn't a write of the session
+	if err := postgres.TxConn(ctx, repo.DB).
+		ModelContext(ctx, inv).
+		Where("code = ?", strings.ToUpper(strings.TrimSpace(code))).
+		For("UPDATE").
+		Select(); err != nil {
+		log.Debugf("GetByCode err: %s", err.Error())
+		if err == pg.ErrNoRows {
+			return nil, _errors.Wrap(_errors.ErrInvitationNotFound, err)
+		}
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return inv, nil
+}
+
+func (repo *postgreRepository) Update(ctx context.Context, inv *models.Invitation) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "invitation.Update")
+	defer cancel()
+	log := repo.logrus.WithField("id", inv.ID)
+	log.Debug("Update")
+	if _, err := postgres.Conn(ctx, repo.DB).
+		ModelContext(ctx, inv).
+		WherePK().
+		Returning("*").
+		Update(); err != nil {
+		log.Debugf("Update err: %s", err.Error())
+		if err == pg.ErrNoRows {
+			return _errors.Wrap(_errors.ErrInvitationNotFound, err)
+		}
+		return constraintErrors.Translate(ctx, err, _errors.ErrInternalServerError)
+	}
+	return nil
+}
+
+func (repo *postgreRepository) Delete(ctx context.Context, ids []int) ([]*models.Invitation, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "invitation.Delete")
+	defer cancel()
+	invitations := []*models.Invitation{}
+	log := repo.logrus.WithField("ids", ids)
+	log.Debug("Delete")
+	if _, err := postgres.Conn(ctx, repo.DB).ModelContext(ctx, &invitations).
+		Where("id IN (?)", pg.In(ids)).
+		Returning("*").
+		Delete(); err != nil && err != pg.ErrNoRows {
+		log.Debugf("Delete err: %s", err.Error())
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return invitations, nil
+}
+
+func (repo *postgreRepository) StoreRedemption(ctx context.Context, r *models.InvitationRedemption) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "invitation.StoreRedemption")
+	defer cancel()
+	log := repo.logrus.WithField("invitationID", r.InvitationID).WithField("userID", r.UserID)
+	log.Debug("StoreRedemption")
+	if _, err := postgres.Conn(ctx, repo.DB).
+		ModelContext(ctx, r).
+		Returning("*").
+		Insert(); err != nil {
+		log.Debugf("StoreRedemption err: %s", err.Error())
+		return constraintErrors.Translate(ctx, err, _errors.ErrInternalServerError)
+	}
+	return nil
+}
+
+func (repo *postgreRepository) GetRedemptions(ctx context.Context, invitationID int) ([]*models.InvitationRedemption, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "invitation.GetRedemptions")
+	defer cancel()
+	redemptions := []*models.InvitationRedemption{}
+	log := repo.logrus.WithField("invitationID", invitationID)
+	log.Debug("GetRedemptions")
+	if err := postgres.ReadConn(ctx, repo.DB).ModelContext(ctx, &redemptions).
+		Where("invitation_id = ?", invitationID).
+		Order("created_at").
+		Select(); err != nil && err != pg.ErrNoRows {
+		log.Debugf("GetRedemptions err: %s", err.Error())
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return redemptions, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	_errors "backend/errors"
+	"backend/invitation"
+	"backend/models"
+	"backend/postgres"
+	"backend/utils"
+	"backend/utils/testutil"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPgRepositoryConstraints raises every registered violation, so the names of the constraints
+// are checked against the schema.
+func TestPgRepositoryConstraints(t *testing.T) {
+	conn := utils.ConnectToPostgreTestDB(false)
+	defer conn.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		violate    func(repo invitation.Repository) error
+		constraint string
+		code       string
+		field      string
+	}{
+		{
+			name: "code",
+			violate: func(repo invitation.Repository) error {
+				return repo.Store(ctx, &models.Invitation{Code: "CONSTRAINT", Role: models.UserDefaultRole, MaxUses: 1})
+			},
+			constraint: "invitations_code_key",
+			code:       _errors.ErrInviteCodeTaken,
+		},
+		{
+			name: "role",
+			violate: func(repo invitation.Repository) error {
+				return repo.Store(ctx, &models.Invitation{Code: "OTHER", MaxUses: 1})
+			},
+			constraint: "invitations.role",
+			code:       _errors.ErrInvalidUserRole,
+			field:      "role",
+		},
+		{
+			name: "redemption user",
+			violate: func(repo invitation.Repository) error {
+				inv, err := repo.GetByCode(ctx, "CONSTRAINT")
+				require.Equal(t, nil, err)
+				return repo.StoreRedemption(ctx, &models.InvitationRedemption{InvitationID: inv.ID})
+			},
+			constraint: "invitation_redemptions.user_id",
+			code:       _errors.ErrUserNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the failed statement aborts the transaction, every violation has its own
+			tx, err := conn.Begin()
+			require.Equal(t, nil, err)
+			defer tx.Rollback()
+			repo, err := NewPostgreInvitationRepository(tx, postgres.Timeouts{})
+			require.Equal(t, nil, err)
+			require.Equal(t, nil, repo.Store(ctx, &models.Invitation{Code: "CONSTRAINT", Role: models.UserDefaultRole, MaxUses: 1}))
+
+			err = test.violate(repo)
+			require.Equal(t, test.constraint, testutil.Violation(err))
+			gqlErr := _errors.ToGqlError(err)
+			require.Equal(t, test.code, _errors.Code(gqlErr))
+			field, _ := gqlErr.Extensions["field"].(string)
+			require.Equal(t, test.field, field)
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package invitation
+
+import (
+	"context"
+
+	"backend/models"
+)
+
+type Usecase interface {
+	Fetch(ctx context.Context, f *models.InvitationFilter) (models.InvitationList, error)
+	GetRedemptions(ctx context.Context, invitationID int) ([]*models.InvitationRedemption, error)
+	// Create generates the code, the invitation is emailed if it has an address.
+	Create(ctx context.Context, createdBy *models.User, input models.InvitationInput) (*models.Invitation, error)
+	Delete(ctx context.Context, ids ...int) ([]*models.Invitation, error)
+}
@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	_errors "backend/errors"
+	"backend/invitation"
+	"backend/models"
+	"backend/outbox"
+	"backend/postgres"
+	"backend/user/validation"
+	"backend/utils"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	DefaultLimit = 100
+	// codeBytes is the number of random bytes of the code, it has 16 characters in base32
+	codeBytes = 10
+)
+
+var codeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type Config struct {
+	InvitationRepo invitation.Repository
+	OutboxRepo     outbox.Repository
+	// Transactor makes the invitation and the enqueued email atomic, it is optional.
+	Transactor  postgres.Transactor
+	FrontendURL string
+	// MaxLimit caps InvitationFilter.Limit, 0 means no cap.
+	MaxLimit int
+}
+
+type usecase struct {
+	invitationRepo invitation.Repository
+	outboxRepo     outbox.Repository
+	transactor     postgres.Transactor
+	frontendURL    string
+	maxLimit       int
+	logrus         *logrus.Entry
+}
+
+func NewInvitationUsecase(cfg Config) invitation.Usecase {
+	return &usecase{
+		cfg.InvitationRepo,
+		cfg.OutboxRepo,
+		cfg.Transactor,
+		cfg.FrontendURL,
+		cfg.MaxLimit,
+		logrus.WithField("package", "invitation/usecase"),
+	}
+}
+
+func (ucase *usecase) Fetch(ctx context.Context, f *models.InvitationFilter) (models.InvitationList, error) {
+	ucase.logrus.WithField("filter", f).Debug("Fetch")
+	if f == nil {
+		f = &models.InvitationFilter{}
+	}
+	f.Limit = utils.NormalizeLimit(f.Limit, DefaultLimit, ucase.maxLimit)
+	return ucase.invitationRepo.Fetch(ctx, f)
+}
+
+func (ucase *usecase) GetRedemptions(ctx context.Context, invitationID int) ([]*models.InvitationRedemption, error) {
+	ucase.logrus.WithField("invitationID", invitationID).Debug("GetRedemptions")
+	return ucase.invitationRepo.GetRedemptions(ctx, invitationID)
+}
+
+func (ucase *usecase) Create(ctx context.Context, createdBy *models.User, input models.InvitationInput) (*models.Invitation, error) {
+	entry := ucase.logrus.WithField("input", input)
+	entry.Debug("Create")
+	inv := input.ToInvitation()
+	if err := validate(inv, time.Now()); err != nil {
+		entry.Debugf("Create - Validation error: %s", err.Error())
+		return nil, err
+	}
+	code, err := generateCode()
+	if err != nil {
+		return nil, _errors.Wrap(_errors.ErrInternalServerError, err)
+	}
+	inv.Code = code
+	inv.CreatedByID = createdBy.ID
+	if err := ucase.withinTx(ctx, func(ctx context.Context) error {
+		if err := ucase.invitationRepo.Store(ctx, &inv); err != nil {
+			return err
+		}
+		if inv.Email == "" || ucase.outboxRepo == nil {
+			return nil
+		}
+		data := map[string]interface{}{
+			"InvitedBy": createdBy.Login,
+			"Href":      fmt.Sprintf("%s/signup?inviteCode=%s", ucase.frontendURL, url.QueryEscape(inv.Code)),
+		}
+		if inv.ExpiresAt != nil {
+			data["ExpiresAt"] = inv.ExpiresAt.UTC().Format("2006-01-02 15:04 MST")
+		}
+		// the recipient has no account, the email is in the default language
+		return ucase.outboxRepo.Enqueue(ctx, &models.Email{
+			To:       inv.Email,
+			Template: outbox.InvitationTemplate,
+			Data:     data,
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (ucase *usecase) Delete(ctx context.Context, ids ...int) ([]*models.Invitation, error) {
+	ucase.logrus.WithField("ids", ids).Debug("Delete")
+	return ucase.invitationRepo.Delete(ctx, ids)
+}
+
+func (ucase *usecase) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ucase.transactor == nil {
+		return fn(ctx)
+	}
+	return ucase.transactor.WithinTx(ctx, fn)
+}
+
+// validate returns gqlerror.List with all violations.
+func validate(inv models.Invitation, now time.Time) error {
+	var errs gqlerror.List
+	if err := (validation.Config{
+		Email: inv.Email != "",
+		Role:  true,
+	}).Validate(models.User{Email: inv.Email, Role: inv.Role}); err != nil {
+		errs = append(errs, err.(gqlerror.List)...)
+	}
+	if inv.MaxUses < 1 {
+		errs = append(errs, _errors.ToGqlError(_errors.WrapField(_errors.ErrInvitationMaxUsesPolicy, "maxUses", _errors.Params{
+			"min": 1,
+		})))
+	}
+	if inv.ExpiresAt != nil && !inv.ExpiresAt.After(now) {
+		errs = append(errs, _errors.ToGqlError(_errors.WrapField(_errors.ErrInvitationExpiresAtPolicy, "expiresAt", nil)))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func generateCode() (string, error) {
+	b := make([]byte, codeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return codeEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	_errors "backend/errors"
+	"backend/models"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func TestInvitation(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	t.Run("validate", func(t *testing.T) {
+		require.Equal(t, nil, validate(models.InvitationInput{}.ToInvitation(), now))
+
+		maxUses := 0
+		email := "invalid"
+		err := validate(models.InvitationInput{Email: &email, MaxUses: &maxUses, ExpiresAt: &past}.ToInvitation(), now)
+		require.NotEqual(t, nil, err)
+		codes := []string{}
+		for _, e := range err.(gqlerror.List) {
+			codes = append(codes, _errors.Code(e))
+		}
+		require.Contains(t, codes, _errors.ErrEmailPolicy)
+		require.Contains(t, codes, _errors.ErrInvitationMaxUsesPolicy)
+		require.Contains(t, codes, _errors.ErrInvitationExpiresAtPolicy)
+	})
+
+	t.Run("check", func(t *testing.T) {
+		code := func(err error) string {
+			if err == nil {
+				return ""
+			}
+			return _errors.Code(_errors.ToGqlError(err))
+		}
+		inv := models.Invitation{MaxUses: 2, Uses: 1, ExpiresAt: &future}
+		require.Equal(t, "", code(inv.Check("john@example.com", now)))
+		inv.Email = "John@Example.com"
+		require.Equal(t, "", code(inv.Check("john@example.com", now)))
+		require.Equal(t, _errors.ErrInviteCodeEmailMismatch, code(inv.Check("jane@example.com", now)))
+		inv.Uses = 2
+		require.Equal(t, _errors.ErrInviteCodeUsedUp, code(inv.Check("john@example.com", now)))
+		inv.ExpiresAt = &past
+		require.Equal(t, _errors.ErrInviteCodeExpired, code(inv.Check("john@example.com", now)))
+	})
+}
@@ -3,19 +3,45 @@ package main
 import (
 	"backend/auth"
 	_authUsecase "backend/auth/usecase"
+	"backend/breach"
+	"backend/cache"
+	"backend/dataloader"
 	"backend/email"
+	_emailHTTPDelivery "backend/email/delivery/http"
+	_errors "backend/errors"
 	_graphqlHTTPDelivery "backend/graphql/delivery/http"
+	"backend/graphql/limits"
+	"backend/graphql/persistedquery"
 	"backend/graphql/resolvers"
+	"backend/hasher"
 	"backend/i18n"
+	_invitationRepository "backend/invitation/repository"
+	_invitationUsecase "backend/invitation/usecase"
 	_middleware "backend/middleware"
+	"backend/models"
+	_outboxRepository "backend/outbox/repository"
+	_outboxUsecase "backend/outbox/usecase"
+	_outboxWorker "backend/outbox/worker"
 	"backend/postgres"
+	_privacyHTTPDelivery "backend/privacy/delivery/http"
+	_privacyRepository "backend/privacy/repository"
+	_privacyUsecase "backend/privacy/usecase"
+	_privacyWorker "backend/privacy/worker"
+	"backend/pubsub"
+	"backend/storage"
+	_storageHTTPDelivery "backend/storage/delivery/http"
+	_userHTTPDelivery "backend/user/delivery/http"
+	_userEvents "backend/user/events"
 	_userRepository "backend/user/repository"
 	_userUsecase "backend/user/usecase"
+	"backend/user/validation"
 	"context"
+	"expvar"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -27,7 +53,10 @@ import (
 	"github.com/labstack/echo/v4"
 
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/bytes"
 
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/go-pg/pg/v9"
 
 	"github.com/sirupsen/logrus"
@@ -45,6 +74,7 @@ func init() {
 	}
 	if viper.GetBool("application.debug") {
 		logrus.SetLevel(logrus.DebugLevel)
+		_errors.Debug = true
 	}
 }
 
@@ -54,23 +84,48 @@ func main() {
 		logrus.Fatal(err)
 	}
 
+	lang, err := language.Parse(viper.GetString("application.defaultLanguage"))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
 	templatesDir, err := filepath.Abs(filepath.Join(dir, "email", "templates"))
 	if err != nil {
 		logrus.Fatal(err)
 	}
-	email.NewDialer(viper.GetString("email.host"),
-		viper.GetInt("email.port"),
-		viper.GetString("email.username"),
-		viper.GetString("email.password"))
-	if err := email.LoadTemplates(templatesDir); err != nil {
+	var transport email.Transport
+	var captured *email.MemoryTransport
+	switch viper.GetString("email.transport") {
+	case "file":
+		transport, err = email.NewFileTransport(viper.GetString("email.dir"))
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	case "log":
+		transport = email.NewLogTransport()
+	case "memory":
+		captured = email.NewMemoryTransport()
+		transport = captured
+	default:
+		transport = email.NewSMTPTransport(viper.GetString("email.host"),
+			viper.GetInt("email.port"),
+			viper.GetString("email.username"),
+			viper.GetString("email.password"))
+	}
+	address := viper.GetString("email.address")
+	if address == "" {
+		address = viper.GetString("email.username")
+	}
+	mailer := email.New(email.Config{
+		Transport:       transport,
+		Address:         address,
+		DefaultLanguage: lang,
+	})
+	if err := mailer.LoadTemplates(templatesDir); err != nil {
 		logrus.Fatal(err)
 	}
 
 	//i18n
-	lang, err := language.Parse(viper.GetString("application.defaultLanguage"))
-	if err != nil {
-		logrus.Fatal(err)
-	}
 	i18n.SetDefaultLanguage(lang)
 	localesDir, err := filepath.Abs(filepath.Join(dir, "i18n", "locales"))
 	if err != nil {
@@ -88,14 +143,32 @@ func main() {
 		ApplicationName: viper.GetString("application.name"),
 	}
 	dbConn := pg.Connect(dbConnConfig)
+	// the replicas are read with the credentials of the primary
+	replicas := []postgres.DB{}
+	for _, addr := range viper.GetStringSlice("db.replicas") {
+		replicaConfig := *dbConnConfig
+		replicaConfig.Addr = addr
+		replicas = append(replicas, pg.Connect(&replicaConfig))
+	}
+	replicaPolicy := postgres.NewRoundRobinPolicy()
+	if viper.GetString("db.replicaPolicy") == "random" {
+		replicaPolicy = postgres.NewRandomPolicy()
+	}
+	db := postgres.NewCluster(postgres.ClusterConfig{
+		Primary:        dbConn,
+		Replicas:       replicas,
+		Policy:         replicaPolicy,
+		ReadYourWrites: viper.GetDuration("db.readYourWrites"),
+	})
 	defer func() {
-		err := dbConn.Close()
+		err := db.Close()
 		if err != nil {
 			logrus.Fatal(err)
 		}
 	}()
 
-	userRepo, err := _userRepository.NewPostgreUserRepository(dbConn)
+	dbTimeouts := loadDBTimeouts()
+	userRepo, err := _userRepository.NewPostgreUserRepository(db, dbTimeouts)
 	if err != nil {
 		logrus.Fatal(err)
 
@@ -103,22 +176,205 @@ func main() {
 	if err := postgres.LoadFunctionsAndTriggers(dbConn); err != nil {
 		logrus.Fatal(err)
 	}
+	// the emails contain tokens and passwords, their data is encrypted in the outbox
+	outboxSecret := viper.GetString("email.outbox.secret")
+	if outboxSecret == "" {
+		outboxSecret = viper.GetString("session.secret")
+	}
+	if err := models.SetEmailDataKey(outboxSecret); err != nil {
+		logrus.Fatal(err)
+	}
+	outboxRepo, err := _outboxRepository.NewPostgreOutboxRepository(db, dbTimeouts)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	privacyRepo, err := _privacyRepository.NewPostgrePrivacyRepository(db, dbTimeouts)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	invitationRepo, err := _invitationRepository.NewPostgreInvitationRepository(db, dbTimeouts)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	var ps pubsub.PubSub
+	switch viper.GetString("pubsub.backend") {
+	case "postgres":
+		ps, err = pubsub.NewPostgrePubSub(dbConn, viper.GetString("pubsub.channel"))
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	default:
+		ps = pubsub.NewInMemoryPubSub()
+	}
+	defer ps.Close()
+	userEvents := _userEvents.NewUserEvents(ps)
+
+	if size := viper.GetInt("cache.user.size"); size > 0 {
+		userCacheMetrics := &cache.Metrics{}
+		userRepo, err = _userRepository.NewCachedUserRepository(_userRepository.CacheConfig{
+			Repo:    userRepo,
+			Cache:   cache.NewLRU(size, viper.GetDuration("cache.user.ttl")),
+			PubSub:  ps,
+			Metrics: userCacheMetrics,
+		})
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		expvar.Publish("userCache", expvar.Func(func() interface{} {
+			return userCacheMetrics.Stats()
+		}))
+	}
+
+	// the configs without the password section keep the previous policy
+	passwordPolicy := validation.DefaultPasswordPolicy()
+	if viper.IsSet("password") {
+		passwordPolicy = validation.PasswordPolicy{
+			MinLength:        viper.GetInt("password.minLength"),
+			MaxLength:        viper.GetInt("password.maxLength"),
+			RequireUppercase: viper.GetBool("password.requireUppercase"),
+			RequireLowercase: viper.GetBool("password.requireLowercase"),
+			RequireDigit:     viper.GetBool("password.requireDigit"),
+			RequireSymbol:    viper.GetBool("password.requireSymbol"),
+			DisallowUserData: viper.GetBool("password.disallowUserData"),
+			MinScore:         viper.GetInt("password.minScore"),
+		}
+	}
+	if denylist := viper.GetString("password.denylist"); denylist != "" {
+		if passwordPolicy.Denylist, err = validation.LoadDenylist(denylist); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	if err := _authUsecase.CheckPasswordPolicy(passwordPolicy); err != nil {
+		logrus.Fatalf("Invalid password policy: %s", err.Error())
+	}
+
+	bcryptHasher := hasher.NewBcrypt(viper.GetInt("password.hashing.bcryptCost"))
+	argon2idHasher := hasher.NewArgon2id(hasher.Argon2Params{
+		Memory:      viper.GetUint32("password.hashing.argon2id.memory"),
+		Iterations:  viper.GetUint32("password.hashing.argon2id.iterations"),
+		Parallelism: uint8(viper.GetUint("password.hashing.argon2id.parallelism")),
+	})
+	// the hashes of the other algorithm are upgraded when users sign in
+	switch viper.GetString("password.hashing.algorithm") {
+	case "bcrypt":
+		hasher.SetDefault(hasher.NewUpgrading(bcryptHasher, argon2idHasher))
+	default:
+		hasher.SetDefault(hasher.NewUpgrading(argon2idHasher, bcryptHasher))
+	}
+
+	var breachChecker breach.Checker
+	if path := viper.GetString("password.breached"); path != "" {
+		if breachChecker, err = breach.Open(path); err != nil {
+			logrus.Fatal(err)
+		}
+	}
 
 	authUcase := _authUsecase.NewAuthUsecase(_authUsecase.Config{
 		UserRepo:                        userRepo,
+		UserEvents:                      userEvents,
+		OutboxRepo:                      outboxRepo,
+		InvitationRepo:                  invitationRepo,
+		Transactor:                      postgres.NewTransactor(dbConn),
+		FrontendURL:                     viper.GetString("application.frontend"),
 		IntervalBetweenTokensGeneration: viper.GetInt("application.intervalBetweenTokensGeneration"),
 		ResetPasswordTokenExpiresIn:     viper.GetInt("application.resetPasswordTokenExpiresIn"),
 		RegistrationDisabled:            viper.GetBool("application.registrationDisabled"),
+		PasswordPolicy:                  &passwordPolicy,
+		BreachChecker:                   breachChecker,
 	})
 
+	bodyLimit, err := bytes.Parse(viper.GetString("application.bodyLimit"))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	var fileStorage storage.Storage
+	switch viper.GetString("storage.backend") {
+	case "s3":
+		fileStorage, err = storage.NewS3Storage(storage.S3Config{
+			Endpoint:  viper.GetString("storage.s3.endpoint"),
+			Region:    viper.GetString("storage.s3.region"),
+			Bucket:    viper.GetString("storage.s3.bucket"),
+			AccessKey: viper.GetString("storage.s3.accessKey"),
+			SecretKey: viper.GetString("storage.s3.secretKey"),
+		})
+	default:
+		fileStorage, err = storage.NewLocalStorage(viper.GetString("storage.dir"))
+	}
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
 	userUcase := _userUsecase.NewUserUsecase(_userUsecase.Config{
-		UserRepo: userRepo,
+		UserRepo:       userRepo,
+		UserEvents:     userEvents,
+		MaxLimit:       viper.GetInt("application.maxFetchLimit"),
+		Storage:        fileStorage,
+		MaxAvatarSize:  bodyLimit,
+		PasswordPolicy: &passwordPolicy,
+		BreachChecker:  breachChecker,
+		Transactor:     postgres.NewTransactor(dbConn),
+	})
+
+	outboxUcase := _outboxUsecase.NewOutboxUsecase(_outboxUsecase.Config{
+		OutboxRepo: outboxRepo,
+		MaxLimit:   viper.GetInt("application.maxFetchLimit"),
+	})
+	outboxWorker := _outboxWorker.New(_outboxWorker.Config{
+		OutboxRepo:      outboxRepo,
+		Mailer:          mailer,
+		Concurrency:     viper.GetInt("email.outbox.concurrency"),
+		MaxAttempts:     viper.GetInt("email.outbox.maxAttempts"),
+		MinBackoff:      viper.GetDuration("email.outbox.minBackoff"),
+		MaxBackoff:      viper.GetDuration("email.outbox.maxBackoff"),
+		FailedRetention: viper.GetDuration("email.outbox.failedRetention"),
+	})
+	outboxWorker.Start()
+
+	privacyUcase := _privacyUsecase.NewPrivacyUsecase(_privacyUsecase.Config{
+		UserRepo:            userRepo,
+		UserEvents:          userEvents,
+		PrivacyRepo:         privacyRepo,
+		OutboxRepo:          outboxRepo,
+		Transactor:          postgres.NewTransactor(dbConn),
+		Storage:             fileStorage,
+		URL:                 viper.GetString("application.url"),
+		FrontendURL:         viper.GetString("application.frontend"),
+		ExportExpiresIn:     viper.GetDuration("privacy.exportExpiresIn"),
+		DeletionGracePeriod: viper.GetDuration("privacy.deletionGracePeriod"),
+	})
+	privacyWorker := _privacyWorker.New(_privacyWorker.Config{
+		PrivacyUcase: privacyUcase,
+	})
+	privacyWorker.Start()
+
+	invitationUcase := _invitationUsecase.NewInvitationUsecase(_invitationUsecase.Config{
+		InvitationRepo: invitationRepo,
+		OutboxRepo:     outboxRepo,
+		Transactor:     postgres.NewTransactor(dbConn),
+		FrontendURL:    viper.GetString("application.frontend"),
+		MaxLimit:       viper.GetInt("application.maxFetchLimit"),
 	})
 
+	var persistedQueries, allowlist graphql.Cache
+	if viper.GetBool("graphql.persistedQueries.strict") {
+		allowlist, err = persistedquery.NewPostgreAllowlist(dbConn)
+	} else if viper.GetString("graphql.persistedQueries.cache") == "postgres" {
+		persistedQueries, err = persistedquery.NewPostgreCache(dbConn)
+	} else if size := viper.GetInt("graphql.persistedQueries.cacheSize"); size > 0 {
+		persistedQueries = lru.New(size)
+	}
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
 	e.Use(middleware.Recover())
+	if viper.GetBool("application.debug") {
+		e.GET("/debug/vars", echo.WrapHandler(expvar.Handler()))
+	}
 
 	//CORS
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
@@ -130,6 +386,10 @@ func main() {
 	//Gzip compression
 	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{
 		Level: 5,
+		Skipper: func(c echo.Context) bool {
+			// the images are already compressed
+			return c.IsWebSocket() || strings.HasPrefix(c.Request().URL.Path, storagePath+"/")
+		},
 	}))
 
 	//Session
@@ -155,12 +415,51 @@ func main() {
 	g.Use(middleware.Secure())
 	g.Use(middleware.BodyLimit(viper.GetString("application.bodyLimit")))
 	g.Use(_middleware.EchoContextToContext())
-	g.Use(_middleware.LocalizerToContext())
+	g.Use(_middleware.ReadYourWrites())
+	g.Use(_middleware.DataloadersToContext(userUcase, dataloader.Config{}))
 	g.Use(_middleware.Authenticate(userRepo))
-	_graphqlHTTPDelivery.NewGraphqlHandler(g, &resolvers.Resolver{
+	g.Use(_middleware.LocalizerToContext())
+	_graphqlHTTPDelivery.NewGraphqlHandler(g, _graphqlHTTPDelivery.Config{
+		Resolver: &resolvers.Resolver{
+			AuthUcase:       authUcase,
+			UserUcase:       userUcase,
+			UserEvents:      userEvents,
+			OutboxUcase:     outboxUcase,
+			PrivacyUcase:    privacyUcase,
+			InvitationUcase: invitationUcase,
+			StorageURL:      viper.GetString("storage.url"),
+		},
+		AllowOrigins: viper.GetStringSlice("application.cors.allowOrigins"),
+		Limits: limits.Config{
+			MaxDepth: viper.GetInt("graphql.maxDepth"),
+			Complexity: limits.Budgets{
+				Anonymous:     viper.GetInt("graphql.complexity.anonymous"),
+				Authenticated: viper.GetInt("graphql.complexity.authenticated"),
+				Admin:         viper.GetInt("graphql.complexity.admin"),
+			},
+		},
+		DefaultListLimit: _userUsecase.DefaultLimit,
+		MaxListLimit:     viper.GetInt("application.maxFetchLimit"),
+		PersistedQueries: persistedQueries,
+		Allowlist:        allowlist,
+		MaxUploadSize:    bodyLimit,
+	})
+	_storageHTTPDelivery.NewStorageHandler(g, _storageHTTPDelivery.Config{
+		Storage:  fileStorage,
+		Path:     storagePath,
+		Prefixes: []string{"avatars/"},
+	})
+	_privacyHTTPDelivery.NewDataExportHandler(g, _privacyHTTPDelivery.Config{
+		PrivacyUcase: privacyUcase,
+	})
+	_userHTTPDelivery.NewUserExportHandler(g, _userHTTPDelivery.Config{
+		UserUcase: userUcase,
+	})
+	_emailHTTPDelivery.NewEmailPreviewHandler(g, _emailHTTPDelivery.Config{
+		Mailer:      mailer,
+		Captured:    captured,
 		FrontendURL: viper.GetString("application.frontend"),
-		AuthUcase:   authUcase,
-		UserUcase:   userUcase,
+		URL:         viper.GetString("application.url"),
 	})
 	go func() {
 		e.Start(viper.GetString("application.address"))
@@ -174,10 +473,43 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 	e.Shutdown(ctx)
+	if err := outboxWorker.Shutdown(ctx); err != nil {
+		logrus.Errorf("Not all emails have been sent: %s", err.Error())
+	}
+	if err := privacyWorker.Shutdown(ctx); err != nil {
+		logrus.Errorf("Privacy worker hasn't stopped: %s", err.Error())
+	}
+	// the deferred calls don't run after os.Exit
+	if breachChecker != nil {
+		if err := breachChecker.Close(); err != nil {
+			logrus.Errorf("Cannot close the breached passwords: %s", err.Error())
+		}
+	}
 	logrus.Info("shutting down")
 	os.Exit(0)
 }
 
+// storagePath is the route which serves the files from the storage.
+const storagePath = "/uploads"
+
+// loadDBTimeouts reads "db.timeouts.default" and the overrides of the repository operations,
+// e.g. "db.timeouts.user.fetch".
+func loadDBTimeouts() postgres.Timeouts {
+	timeouts := postgres.Timeouts{
+		Default:    viper.GetDuration("db.timeouts.default"),
+		Operations: map[string]time.Duration{},
+	}
+	for repository, operations := range viper.GetStringMap("db.timeouts") {
+		if _, ok := operations.(map[string]interface{}); !ok {
+			continue
+		}
+		for operation := range viper.GetStringMap("db.timeouts." + repository) {
+			timeouts.Operations[repository+"."+operation] = viper.GetDuration("db.timeouts." + repository + "." + operation)
+		}
+	}
+	return timeouts
+}
+
 func convertToHTTPSameSite(sameSite string) http.SameSite {
 	switch sameSite {
 	case "lax":
@@ -17,12 +17,10 @@ func Authenticate(repo user.Repository) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			sess, _ := session.Get(auth.SessionName, c)
-			login, ok1 := sess.Values["login"].(string)
-			password, ok2 := sess.Values["password"].(string)
 			req := c.Request()
-			if ok1 && ok2 {
-				user, err := repo.GetByCredentials(req.Context(), login, password)
-				if err == nil {
+			if id, fingerprint, ok := auth.UserIDFromSession(sess); ok {
+				user, err := repo.GetByID(req.Context(), id)
+				if err == nil && auth.MatchesSession(user, fingerprint) {
 					c.SetRequest(req.WithContext(StoreUserInContext(req.Context(), user)))
 				}
 			}
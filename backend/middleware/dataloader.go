@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"backend/dataloader"
+	"context"
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+)
+
+var dataloadersContextKey contextKey = "dataloaders_context_key"
+
+func DataloadersToContext(users dataloader.UserFetcher, cfg dataloader.Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			loaders := dataloader.NewLoaders(req.Context(), users, cfg)
+			ctx := StoreDataloadersInContext(req.Context(), loaders)
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+func StoreDataloadersInContext(ctx context.Context, loaders *dataloader.Loaders) context.Context {
+	return context.WithValue(ctx, dataloadersContextKey, loaders)
+}
+
+func DataloadersFromContext(ctx context.Context) (*dataloader.Loaders, error) {
+	loaders := ctx.Value(dataloadersContextKey)
+	if loaders == nil {
+		err := fmt.Errorf("Could not retrieve *dataloader.Loaders")
+		return nil, err
+	}
+
+	gc, ok := loaders.(*dataloader.Loaders)
+	if !ok {
+		err := fmt.Errorf("*dataloader.Loaders has wrong type")
+		return nil, err
+	}
+	return gc, nil
+}
@@ -10,14 +10,21 @@ import (
 )
 
 var localizerContextKey contextKey = "localizer_context_key"
+var languageContextKey contextKey = "language_context_key"
 
 func LocalizerToContext() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			req := c.Request()
 			accept := req.Header.Get("Accept-Language")
-			localizer := i18n.NewLocalizer(_i18n.Bundle, accept)
+			langs := []string{accept}
+			// the locale chosen by the user takes precedence, Authenticate must be called before
+			if user, err := UserFromContext(req.Context()); err == nil && user.Locale != "" {
+				langs = []string{user.Locale, accept}
+			}
+			localizer := i18n.NewLocalizer(_i18n.Bundle, langs...)
 			ctx := StoreLocalizerInContext(req.Context(), localizer)
+			ctx = StoreLanguageInContext(ctx, accept)
 			c.SetRequest(req.WithContext(ctx))
 			return next(c)
 		}
@@ -42,3 +49,14 @@ func LocalizerFromContext(ctx context.Context) (*i18n.Localizer, error) {
 	}
 	return gc, nil
 }
+
+// StoreLanguageInContext keeps the preferred languages of the request (in the Accept-Language format),
+// so the work done after the request can be localized.
+func StoreLanguageInContext(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, languageContextKey, language)
+}
+
+func LanguageFromContext(ctx context.Context) string {
+	language, _ := ctx.Value(languageContextKey).(string)
+	return language
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"backend/auth"
+	"backend/postgres"
+	"time"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+const sessionLastWriteKey = "lastWriteAt"
+
+// ReadYourWrites remembers in the session when its requests wrote to the database,
+// so the reads of its next requests go to the primary until the replicas catch up.
+func ReadYourWrites() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			sess, err := session.Get(auth.SessionName, c)
+			if err != nil {
+				return next(c)
+			}
+			last, _ := sess.Values[sessionLastWriteKey].(int64)
+			var previous time.Time
+			if last != 0 {
+				previous = time.Unix(0, last)
+			}
+			writes := postgres.NewWrites(previous)
+			req := c.Request()
+			c.SetRequest(req.WithContext(postgres.WithWrites(req.Context(), writes)))
+			// the cookie has to be set before the response is written
+			c.Response().Before(func() {
+				if writes.Last().After(previous) {
+					sess.Values[sessionLastWriteKey] = writes.Last().UnixNano()
+					sess.Save(c.Request(), c.Response())
+				}
+			})
+			return next(c)
+		}
+	}
+}
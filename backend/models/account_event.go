@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type AccountEventType string
+
+const (
+	AccountEventTypeActivated   AccountEventType = "ACTIVATED"
+	AccountEventTypeRoleChanged AccountEventType = "ROLE_CHANGED"
+	AccountEventTypeLoggedOut   AccountEventType = "LOGGED_OUT"
+)
+
+func (t AccountEventType) IsValid() bool {
+	switch t {
+	case AccountEventTypeActivated, AccountEventTypeRoleChanged, AccountEventTypeLoggedOut:
+		return true
+	}
+	return false
+}
+
+func (t AccountEventType) String() string {
+	return string(t)
+}
+
+func (t *AccountEventType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*t = AccountEventType(str)
+	if !t.IsValid() {
+		return fmt.Errorf("%s is not a valid AccountEventType", str)
+	}
+	return nil
+}
+
+func (t AccountEventType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(t.String()))
+}
+
+type AccountEvent struct {
+	Type AccountEventType `json:"type"`
+	User *User            `json:"user"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type DataExportStatus string
+
+const (
+	DataExportStatusPending DataExportStatus = "PENDING"
+	DataExportStatusReady   DataExportStatus = "READY"
+	DataExportStatusFailed  DataExportStatus = "FAILED"
+)
+
+func (s DataExportStatus) IsValid() bool {
+	switch s {
+	case DataExportStatusPending, DataExportStatusReady, DataExportStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (s DataExportStatus) String() string {
+	return string(s)
+}
+
+func (s *DataExportStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*s = DataExportStatus(str)
+	if !s.IsValid() {
+		return fmt.Errorf("%s is not a valid DataExportStatus", str)
+	}
+	return nil
+}
+
+func (s DataExportStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(s.String()))
+}
+
+// DataExport is an archive with the personal data of the user, it is built in the background.
+type DataExport struct {
+	tableName struct{} `pg:"data_exports,alias:data_export"`
+
+	ID     int              `json:"id" pg:",pk"`
+	UserID int              `json:"userId" pg:",notnull"`
+	Status DataExportStatus `json:"status" pg:"default:'PENDING'"`
+	// Token is a part of the download link.
+	Token    string `json:"-" gqlgen:"-" pg:",unique,notnull"`
+	Attempts int    `json:"-" gqlgen:"-" pg:",use_zero"`
+	// ClaimedAt hides the export from other workers while it is built.
+	ClaimedAt time.Time  `json:"-" gqlgen:"-" pg:",use_zero"`
+	CreatedAt time.Time  `json:"createdAt" pg:"default:now()"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
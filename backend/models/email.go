@@ -0,0 +1,76 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type EmailStatus string
+
+const (
+	EmailStatusPending EmailStatus = "PENDING"
+	EmailStatusSent    EmailStatus = "SENT"
+	// EmailStatusFailed is the dead-letter state, the email is not retried until an admin does it.
+	EmailStatusFailed EmailStatus = "FAILED"
+)
+
+func (s EmailStatus) IsValid() bool {
+	switch s {
+	case EmailStatusPending, EmailStatusSent, EmailStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (s EmailStatus) String() string {
+	return string(s)
+}
+
+func (s *EmailStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*s = EmailStatus(str)
+	if !s.IsValid() {
+		return fmt.Errorf("%s is not a valid EmailStatus", str)
+	}
+	return nil
+}
+
+func (s EmailStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(s.String()))
+}
+
+// Email is a message waiting in the outbox, it is rendered from the template when it is sent.
+type Email struct {
+	tableName struct{} `pg:"email_outbox,alias:email"`
+
+	ID            int         `json:"id" pg:",pk"`
+	To            string      `json:"to" pg:",notnull"`
+	Template      string      `json:"template" pg:",notnull"`
+	Language      string      `json:"language" pg:",use_zero"`
+	Data          EmailData   `json:"-" gqlgen:"-"`
+	Status        EmailStatus `json:"status" pg:"default:'PENDING'"`
+	Attempts      int         `json:"attempts" pg:",use_zero"`
+	LastError     string      `json:"lastError" pg:",use_zero"`
+	NextAttemptAt time.Time   `json:"nextAttemptAt" pg:"default:now()"`
+	CreatedAt     time.Time   `json:"createdAt" pg:"default:now()"`
+	SentAt        *time.Time  `json:"sentAt"`
+}
+
+type EmailFilter struct {
+	Status EmailStatus `json:"status"`
+	// To filters by the recipient, it isn't exposed.
+	To     string `json:"to" gqlgen:"-"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+}
+
+type EmailList struct {
+	Total int      `json:"total"`
+	Items []*Email `json:"items"`
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// EmailData is the data of the email template. It contains tokens and passwords, so it is stored encrypted
+// with AES-GCM under the key set by SetEmailDataKey, as a JSON string in the data column.
+type EmailData map[string]interface{}
+
+var (
+	emailDataMutex sync.RWMutex
+	emailDataAEAD  cipher.AEAD
+)
+
+// SetEmailDataKey derives the key which encrypts the email data from secret.
+func SetEmailDataKey(secret string) error {
+	if secret == "" {
+		return fmt.Errorf("the email data key cannot be empty")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	emailDataMutex.Lock()
+	defer emailDataMutex.Unlock()
+	emailDataAEAD = aead
+	return nil
+}
+
+func emailDataKey() (cipher.AEAD, error) {
+	emailDataMutex.RLock()
+	defer emailDataMutex.RUnlock()
+	if emailDataAEAD == nil {
+		return nil, fmt.Errorf("the email data key isn't set")
+	}
+	return emailDataAEAD, nil
+}
+
+func (d EmailData) Value() (driver.Value, error) {
+	if d == nil {
+		return nil, nil
+	}
+	aead, err := emailDataKey()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(map[string]interface{}(d))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	// a string, go-pg would send []byte as bytea
+	b, err := json.Marshal(base64.StdEncoding.EncodeToString(sealed))
+	return string(b), err
+}
+
+// Scan reads the encrypted data, the plain objects stored before the encryption are read as they are
+// and encrypted when the email is updated.
+func (d *EmailData) Scan(src interface{}) error {
+	var b []byte
+	switch src := src.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case []byte:
+		b = src
+	case string:
+		b = []byte(src)
+	default:
+		return fmt.Errorf("cannot scan %T into EmailData", src)
+	}
+	data, err := DecodeEmailData(b)
+	if err != nil {
+		return err
+	}
+	*d = data
+	return nil
+}
+
+// DecodeEmailData decrypts the data as it is stored in the data column, nil is read as no data.
+func DecodeEmailData(b []byte) (EmailData, error) {
+	if b == nil {
+		return nil, nil
+	}
+	var encoded string
+	if err := json.Unmarshal(b, &encoded); err != nil {
+		plain := map[string]interface{}{}
+		if err := json.Unmarshal(b, &plain); err != nil {
+			return nil, err
+		}
+		return plain, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := emailDataKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("the email data is too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+	plain := map[string]interface{}{}
+	if err := json.Unmarshal(plaintext, &plain); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailData(t *testing.T) {
+	require.Equal(t, nil, SetEmailDataKey("secret"))
+	data := EmailData{"Password": "Password123", "Attempt": 1.0}
+
+	value, err := data.Value()
+	require.Equal(t, nil, err)
+	require.NotContains(t, value.(string), "Password123")
+
+	scanned := EmailData{}
+	require.Equal(t, nil, scanned.Scan([]byte(value.(string))))
+	require.Equal(t, data, scanned)
+
+	// the data stored before the encryption
+	require.Equal(t, nil, scanned.Scan([]byte(`{"Href": "link"}`)))
+	require.Equal(t, EmailData{"Href": "link"}, scanned)
+
+	require.Equal(t, nil, scanned.Scan(nil))
+	require.Equal(t, true, scanned == nil)
+
+	require.Equal(t, nil, SetEmailDataKey("other secret"))
+	require.NotEqual(t, nil, scanned.Scan([]byte(value.(string))))
+}
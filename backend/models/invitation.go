@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	_errors "backend/errors"
+)
+
+// Invitation lets people sign up even when the registration is disabled.
+type Invitation struct {
+	tableName struct{} `pg:"invitations,alias:invitation"`
+
+	ID   int    `json:"id" pg:",pk"`
+	Code string `json:"code" pg:",unique,notnull"`
+	// Email limits the invitation to the address, it is empty for codes which can be shared.
+	Email       string     `json:"email" pg:",use_zero"`
+	Role        int        `json:"role" pg:",notnull"`
+	MaxUses     int        `json:"maxUses" pg:",notnull"`
+	Uses        int        `json:"uses" pg:",use_zero"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	CreatedByID int        `json:"createdById"`
+	CreatedAt   time.Time  `json:"createdAt" pg:"default:now()"`
+}
+
+// Check returns the reason why the invitation cannot be used to sign up with the email.
+func (inv *Invitation) Check(email string, now time.Time) error {
+	if inv.ExpiresAt != nil && inv.ExpiresAt.Before(now) {
+		return _errors.WrapField(_errors.ErrInviteCodeExpired, "inviteCode", nil)
+	}
+	if inv.Uses >= inv.MaxUses {
+		return _errors.WrapField(_errors.ErrInviteCodeUsedUp, "inviteCode", nil)
+	}
+	if inv.Email != "" && !strings.EqualFold(inv.Email, email) {
+		return _errors.WrapField(_errors.ErrInviteCodeEmailMismatch, "inviteCode", nil)
+	}
+	return nil
+}
+
+// InvitationRedemption records who signed up with the invitation.
+type InvitationRedemption struct {
+	tableName struct{} `pg:"invitation_redemptions,alias:redemption"`
+
+	ID           int       `json:"id" pg:",pk"`
+	InvitationID int       `json:"invitationId" pg:",notnull"`
+	UserID       int       `json:"userId" pg:",notnull"`
+	CreatedAt    time.Time `json:"createdAt" pg:"default:now()"`
+}
+
+type InvitationInput struct {
+	Email     *string    `json:"email"`
+	Role      *int       `json:"role"`
+	MaxUses   *int       `json:"maxUses"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+func (input InvitationInput) ToInvitation() Invitation {
+	inv := Invitation{
+		Role:    UserDefaultRole,
+		MaxUses: 1,
+	}
+	if input.Email != nil {
+		inv.Email = strings.TrimSpace(*input.Email)
+	}
+	if input.Role != nil {
+		inv.Role = *input.Role
+	}
+	if input.MaxUses != nil {
+		inv.MaxUses = *input.MaxUses
+	}
+	inv.ExpiresAt = input.ExpiresAt
+	return inv
+}
+
+type InvitationFilter struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+type InvitationList struct {
+	Total int           `json:"total"`
+	Items []*Invitation `json:"items"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// PasswordStrength lets the frontend show the feedback before the password is submitted.
+type PasswordStrength struct {
+	// Score ranges from 0 (too guessable) to 4 (very unguessable).
+	Score   int     `json:"score"`
+	Entropy float64 `json:"entropy"`
+	// Valid is true when the password satisfies the password policy.
+	Valid      bool                 `json:"valid"`
+	Violations []*PasswordViolation `json:"violations"`
+}
+
+// PasswordViolation is the error which would be returned for the password.
+type PasswordViolation struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"-"`
+}
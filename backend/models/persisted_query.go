@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type PersistedQuery struct {
+	tableName struct{} `pg:"alias:persisted_query"`
+
+	Hash       string    `json:"hash" pg:",pk"`
+	Query      string    `json:"query" pg:",notnull"`
+	Registered bool      `json:"registered" pg:",use_zero"`
+	CreatedAt  time.Time `json:"createdAt" pg:"default:now()"`
+}
@@ -5,8 +5,8 @@ import (
 	"time"
 
 	_errors "backend/errors"
-
-	"golang.org/x/crypto/bcrypt"
+	"backend/hasher"
+	"backend/identity"
 )
 
 const (
@@ -22,6 +22,8 @@ type User struct {
 	Login                         string    `json:"login,omitempty" pg:",unique,use_zero"`
 	Password                      string    `json:"-,omitempty" gqlgen:"-"`
 	Email                         string    `json:"email,omitempty" pg:",unique"`
+	LoginCanonical                string    `json:"-" gqlgen:"-" pg:",unique"`
+	EmailCanonical                string    `json:"-" gqlgen:"-" pg:",unique"`
 	CreatedAt                     time.Time `json:"createdAt,omitempty" pg:"default:now()"`
 	UpdatedAt                     time.Time `json:"updatedAt,omitempty" pg:"default:now()"`
 	Role                          int       `json:"role,omitempty"`
@@ -30,14 +32,21 @@ type User struct {
 	ActivationTokenGeneratedAt    time.Time `json:"-" gqlgen:"-" pg:"default:now()"`
 	ResetPasswordToken            string    `json:"-" gqlgen:"-"`
 	ResetPasswordTokenGeneratedAt time.Time `json:"-" gqlgen:"-" pg:"default:now()"`
+	DisplayName                   string    `json:"displayName,omitempty"`
+	Bio                           string    `json:"bio,omitempty"`
+	Locale                        string    `json:"locale,omitempty"`
+	Timezone                      string    `json:"timezone,omitempty"`
+	// Avatar is the storage key prefix of the resized avatars.
+	Avatar string `json:"avatar,omitempty"`
+	// The account is deleted at DeletionScheduledAt unless the deletion is cancelled with DeletionToken.
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty"`
+	DeletionToken       string     `json:"-" gqlgen:"-"`
 }
 
+// CompareHashAndPassword compares the plaintext password with the hash, the hash itself doesn't match.
 func (u *User) CompareHashAndPassword(password string) error {
-	if password == u.Password {
-		return nil
-	}
-	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
-		return _errors.Wrap(_errors.ErrInvalidCredentials)
+	if err := hasher.Default().Compare(u.Password, password); err != nil {
+		return _errors.Wrap(_errors.ErrInvalidCredentials, err)
 	}
 	return nil
 }
@@ -61,27 +70,43 @@ func (u *User) BeforeInsert(ctx context.Context) (context.Context, error) {
 	u.CreatedAt = time.Now()
 	u.UpdatedAt = time.Now()
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+	hashedPassword, err := hasher.Default().Hash(u.Password)
 	if err != nil {
 		return ctx, err
 	}
-	u.Password = string(hashedPassword)
+	u.Password = hashedPassword
+	u.Canonicalize()
 
 	return ctx, nil
 }
 
+// BeforeUpdate stores the password as it is, the usecases which change it call SetPassword.
 func (u *User) BeforeUpdate(ctx context.Context) (context.Context, error) {
 	u.UpdatedAt = time.Now()
+	u.Canonicalize()
 
-	if cost, _ := bcrypt.Cost([]byte(u.Password)); u.Password != "" && cost == 0 {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return ctx, err
-		}
-		u.Password = string(hashedPassword)
+	return ctx, nil
+}
+
+// SetPassword replaces the password with the hash of the new plaintext password.
+func (u *User) SetPassword(password string) error {
+	hashedPassword, err := hasher.Default().Hash(password)
+	if err != nil {
+		return _errors.Wrap(_errors.ErrInternalServerError, err)
 	}
+	u.Password = hashedPassword
+	return nil
+}
 
-	return ctx, nil
+// Canonicalize sets the canonical forms of the login and the email, the sign in and the unique indexes use them.
+// The empty identifiers are left as they are, the partial updates don't set them.
+func (u *User) Canonicalize() {
+	if u.Login != "" {
+		u.LoginCanonical = identity.Canonical(u.Login)
+	}
+	if u.Email != "" {
+		u.EmailCanonical = identity.Canonical(u.Email)
+	}
 }
 
 type UserInput struct {
@@ -105,6 +130,56 @@ func (input UserInput) ToUser() User {
 	return u
 }
 
+// ProfileInput contains the fields which users can change themselves, nil means no change.
+type ProfileInput struct {
+	Login       *string `json:"login"`
+	DisplayName *string `json:"displayName"`
+	Bio         *string `json:"bio"`
+	Locale      *string `json:"locale"`
+	Timezone    *string `json:"timezone"`
+}
+
+func (input ProfileInput) ToUser() User {
+	u := User{}
+	if input.Login != nil {
+		u.Login = *input.Login
+	}
+	if input.DisplayName != nil {
+		u.DisplayName = *input.DisplayName
+	}
+	if input.Bio != nil {
+		u.Bio = *input.Bio
+	}
+	if input.Locale != nil {
+		u.Locale = *input.Locale
+	}
+	if input.Timezone != nil {
+		u.Timezone = *input.Timezone
+	}
+	return u
+}
+
+// Columns returns the columns changed by the input.
+func (input ProfileInput) Columns() []string {
+	columns := []string{}
+	if input.Login != nil {
+		columns = append(columns, "login")
+	}
+	if input.DisplayName != nil {
+		columns = append(columns, "display_name")
+	}
+	if input.Bio != nil {
+		columns = append(columns, "bio")
+	}
+	if input.Locale != nil {
+		columns = append(columns, "locale")
+	}
+	if input.Timezone != nil {
+		columns = append(columns, "timezone")
+	}
+	return columns
+}
+
 type UserFilter struct {
 	tableName struct{} `urlstruct:"user"`
 
@@ -124,9 +199,11 @@ type UserFilter struct {
 	UpdatedAt   time.Time `gqlgen:"updatedAt"`
 	UpdatedAtGT time.Time `gqlgen:"updatedAtGt"`
 	UpdatedAtLT time.Time `gqlgen:"updatedAtLt"`
-	Offset      int       `urlstruct:",nowhere"`
-	Limit       int       `urlstruct:",nowhere"`
-	Order       []string  `urlstruct:",nowhere"`
+	// DeletionScheduledAtLT is used to purge the deleted accounts, it isn't exposed.
+	DeletionScheduledAtLT time.Time `gqlgen:"-"`
+	Offset                int       `urlstruct:",nowhere"`
+	Limit                 int       `urlstruct:",nowhere"`
+	Order                 []string  `urlstruct:",nowhere"`
 }
 
 type UserList struct {
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type UserFileFormat string
+
+const (
+	// UserFileFormatCSV files have a header with the names of the fields.
+	UserFileFormatCSV UserFileFormat = "CSV"
+	// UserFileFormatJSON files are imported from an array or lines of objects and exported as lines of objects.
+	UserFileFormatJSON UserFileFormat = "JSON"
+)
+
+func (f UserFileFormat) IsValid() bool {
+	switch f {
+	case UserFileFormatCSV, UserFileFormatJSON:
+		return true
+	}
+	return false
+}
+
+func (f UserFileFormat) String() string {
+	return string(f)
+}
+
+func (f *UserFileFormat) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*f = UserFileFormat(str)
+	if !f.IsValid() {
+		return fmt.Errorf("%s is not a valid UserFileFormat", str)
+	}
+	return nil
+}
+
+func (f UserFileFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(f.String()))
+}
+
+// UserImport is the result of an import, the users are imported only if no row has errors.
+type UserImport struct {
+	// Imported is the number of the imported users, in the dry run the number of the users which would be imported.
+	Imported int                `json:"imported"`
+	DryRun   bool               `json:"dryRun"`
+	Errors   []*UserImportError `json:"errors"`
+}
+
+// UserImportError is the error which would be returned for the user in the row.
+type UserImportError struct {
+	// Row is numbered from 1, the header of CSV files isn't counted.
+	Row     int                    `json:"row"`
+	Field   string                 `json:"field"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"-"`
+}
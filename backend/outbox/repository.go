@@ -0,0 +1,21 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"backend/models"
+)
+
+type Repository interface {
+	Enqueue(ctx context.Context, e *models.Email) error
+	Fetch(ctx context.Context, f *models.EmailFilter) (models.EmailList, error)
+	GetByID(ctx context.Context, id int) (*models.Email, error)
+	// Claim returns up to limit pending emails which are due and hides them from other workers for the lease duration.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.Email, error)
+	Update(ctx context.Context, e *models.Email) error
+	// DeleteFailedData clears the data of the emails which failed before the time, they can't be retried anymore.
+	DeleteFailedData(ctx context.Context, before time.Time) error
+	// DeleteByRecipient removes all emails sent to the address.
+	DeleteByRecipient(ctx context.Context, to string) error
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"backend/outbox"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	_errors "backend/errors"
+	"backend/models"
+)
+
+type memoryRepository struct {
+	mutex  sync.Mutex
+	emails []*models.Email
+	logrus *logrus.Entry
+}
+
+// NewMemoryOutboxRepository returns the repository which keeps the emails in memory, it is meant for the unit tests.
+// It ignores the transaction carried by ctx, the email is enqueued at once.
+func NewMemoryOutboxRepository() outbox.Repository {
+	return &memoryRepository{
+		logrus: logrus.WithField("package", "outbox/repository"),
+	}
+}
+
+func (repo *memoryRepository) Enqueue(ctx context.Context, e *models.Email) error {
+	repo.logrus.WithField("to", e.To).WithField("template", e.Template).Debug("Enqueue")
+	if e.To == "" {
+		return _errors.WrapField(_errors.ErrEmailPolicy, "to", nil)
+	}
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	now := time.Now()
+	e.ID = len(repo.emails) + 1
+	e.Status = models.EmailStatusPending
+	e.NextAttemptAt = now
+	e.CreatedAt = now
+	stored := *e
+	repo.emails = append(repo.emails, &stored)
+	return nil
+}
+
+func (repo *memoryRepository) Fetch(ctx context.Context, f *models.EmailFilter) (models.EmailList, error) {
+	repo.logrus.WithField("filter", f).Debug("Fetch")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	emails := []*models.Email{}
+	for _, e := range repo.emails {
+		if e == nil || (f != nil && ((f.Status != "" && e.Status != f.Status) || (f.To != "" && e.To != f.To))) {
+			continue
+		}
+		copy := *e
+		// the data isn't listed, like in the postgres repository
+		copy.Data = nil
+		emails = append(emails, &copy)
+	}
+	sort.SliceStable(emails, func(i, j int) bool {
+		return emails[i].CreatedAt.After(emails[j].CreatedAt)
+	})
+	list := models.EmailList{Total: len(emails)}
+	if f != nil {
+		if f.Offset >= len(emails) {
+			emails = emails[:0]
+		} else {
+			emails = emails[f.Offset:]
+		}
+		if f.Limit > 0 && f.Limit < len(emails) {
+			emails = emails[:f.Limit]
+		}
+	}
+	list.Items = emails
+	return list, nil
+}
+
+func (repo *memoryRepository) GetByID(ctx context.Context, id int) (*models.Email, error) {
+	repo.logrus.WithField("id", id).Debug("GetByID")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if id < 1 || id > len(repo.emails) || repo.emails[id-1] == nil {
+		return nil, _errors.Wrap(_errors.ErrEmailNotFound)
+	}
+	copy := *repo.emails[id-1]
+	return &copy, nil
+}
+
+func (repo *memoryRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.Email, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	claimed := []*models.Email{}
+	now := time.Now()
+	for _, e := range repo.emails {
+		if len(claimed) == limit {
+			break
+		}
+		if e != nil && e.Status == models.EmailStatusPending && !e.NextAttemptAt.After(now) {
+			e.NextAttemptAt = now.Add(lease)
+			copy := *e
+			claimed = append(claimed, &copy)
+		}
+	}
+	return claimed, nil
+}
+
+func (repo *memoryRepository) Update(ctx context.Context, e *models.Email) error {
+	repo.logrus.WithField("id", e.ID).WithField("status", e.Status).Debug("Update")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if e.ID < 1 || e.ID > len(repo.emails) || repo.emails[e.ID-1] == nil {
+		return _errors.Wrap(_errors.ErrEmailNotFound)
+	}
+	stored := *e
+	repo.emails[e.ID-1] = &stored
+	return nil
+}
+
+func (repo *memoryRepository) DeleteFailedData(ctx context.Context, before time.Time) error {
+	repo.logrus.WithField("before", before).Debug("DeleteFailedData")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for _, e := range repo.emails {
+		if e != nil && e.Status == models.EmailStatusFailed && e.NextAttemptAt.Before(before) {
+			e.Data = nil
+		}
+	}
+	return nil
+}
+
+func (repo *memoryRepository) DeleteByRecipient(ctx context.Context, to string) error {
+	repo.logrus.WithField("to", to).Debug("DeleteByRecipient")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	// the ids stay the indexes of the emails
+	for i, e := range repo.emails {
+		if e != nil && e.To == to {
+			repo.emails[i] = nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"backend/outbox"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	_errors "backend/errors"
+	"backend/models"
+	"backend/postgres"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+)
+
+const (
+	createIndex = `
+		CREATE INDEX IF NOT EXISTS email_outbox_status_next_attempt_at_idx
+		ON email_outbox (status, next_attempt_at);
+	`
+	claim = `
+		UPDATE email_outbox SET next_attempt_at = now() + ?::interval
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = ? AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`
+)
+
+// claimedEmail reads the data as it is stored, so an email whose data can't be decrypted
+// doesn't fail the whole claim.
+type claimedEmail struct {
+	models.Email
+	Data sealedEmailData
+}
+
+type sealedEmailData []byte
+
+func (d *sealedEmailData) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*d = nil
+	case []byte:
+		*d = append(sealedEmailData{}, src...)
+	case string:
+		*d = sealedEmailData(src)
+	default:
+		return fmt.Errorf("cannot scan %T into sealedEmailData", src)
+	}
+	return nil
+}
+
+var constraintErrors = postgres.NewErrorRegistry().
+	NotNull("email_outbox", "to", _errors.ErrEmailPolicy, "to").
+	NotNull("email_outbox", "template", _errors.ErrInvalidPayload, "template")
+
+type postgreRepository struct {
+	postgres.DB
+	timeouts postgres.Timeouts
+	logrus   *logrus.Entry
+}
+
+func NewPostgreOutboxRepository(conn postgres.DB, timeouts postgres.Timeouts) (outbox.Repository, error) {
+	log := logrus.WithField("package", "outbox/repository")
+	if err := conn.CreateTable((*models.Email)(nil), &orm.CreateTableOptions{
+		IfNotExists: true,
+	}); err != nil {
+		log.Debugf("Cannot create email outbox table: %s", err.Error())
+		return nil, err
+	}
+	if _, err := conn.Exec(createIndex); err != nil {
+		log.Debugf("Cannot create email outbox index: %s", err.Error())
+		return nil, err
+	}
+	return &postgreRepository{conn,
+		timeouts,
+		log,
+	}, nil
+}
+
+// Enqueue joins the transaction carried by ctx, so the email is sent only if the transaction is committed.
+func (repo *postgreRepository) Enqueue(ctx context.Context, e *models.Email) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "outbox.Enqueue")
+	defer cancel()
+	log := repo.logrus.WithField("to", e.To).WithField("template", e.Template)
+	log.Debug("Enqueue")
+	e.Status = models.EmailStatusPending
+	if _, err := postgres.Conn(ctx, repo.DB).
+		ModelContext(ctx, e).
+		Returning("*").
+		Insert(); err != nil {
+		log.Debugf("Enqueue err: %s", err.Error())
+		return constraintErrors.Translate(ctx, err, _errors.ErrInternalServerError)
+	}
+	return nil
+}
+
+func (repo *postgreRepository) Fetch(ctx context.Context, f *models.EmailFilter) (models.EmailList, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "outbox.Fetch")
+	defer cancel()
+	var err error
+	emails := []*models.Email{}
+	pagination := models.EmailList{}
+	// the data isn't listed, an email which can't be decrypted doesn't fail the list
+	query := postgres.ReadConn(ctx, repo.DB).ModelContext(ctx, &emails).ExcludeColumn("data").Order("created_at DESC")
+	log := repo.logrus.WithField("filter", f)
+	log.Debug("Fetch")
+
+	if f != nil {
+		query = query.
+			Limit(f.Limit).
+			Offset(f.Offset)
+
+		if f.Status != "" {
+			query = query.Where("status = ?", f.Status)
+		}
+		if f.To != "" {
+			query = query.Where("? = ?", pg.Ident("to"), f.To)
+		}
+	}
+
+	if pagination.Total, err = query.
+		SelectAndCount(); err != nil && err != pg.ErrNoRows {
+		log.Debugf("Fetch err: %s", err.Error())
+		return pagination, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	pagination.Items = emails
+
+	return pagination, nil
+}
+
+func (repo *postgreRepository) GetByID(ctx context.Context, id int) (*models.Email, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "outbox.GetByID")
+	defer cancel()
+	e := &models.Email{
+		ID: id,
+	}
+	log := repo.logrus.WithField("id", id)
+	log.Debug("GetByID")
+	if err := postgres.ReadConn(ctx, repo.DB).ModelContext(ctx, e).WherePK().Select(); err != nil {
+		log.Debugf("GetByID err: %s", err.Error())
+		if err == pg.ErrNoRows {
+			return nil, _errors.Wrap(_errors.ErrEmailNotFound, err)
+		}
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return e, nil
+}
+
+func (repo *postgreRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.Email, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "outbox.Claim")
+	defer cancel()
+	claimed := []*claimedEmail{}
+	if _, err := postgres.Conn(ctx, repo.DB).QueryContext(ctx,
+		&claimed,
+		claim,
+		fmt.Sprintf("%d milliseconds", lease.Milliseconds()),
+		models.EmailStatusPending,
+		limit); err != nil && err != pg.ErrNoRows {
+		repo.logrus.Debugf("Claim err: %s", err.Error())
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	emails := make([]*models.Email, 0, len(claimed))
+	for _, c := range claimed {
+		e := &c.Email
+		data, err := models.DecodeEmailData(c.Data)
+		if err == nil {
+			e.Data = data
+			emails = append(emails, e)
+			continue
+		}
+		// the stored data is kept, the email can be retried once the key is restored
+		log := repo.logrus.WithField("id", e.ID)
+		log.Warnf("Claim - Cannot decrypt the email data: %s", err.Error())
+		e.Status = models.EmailStatusFailed
+		e.LastError = "cannot decrypt the email data: " + err.Error()
+		if _, err := postgres.Conn(ctx, repo.DB).
+			ModelContext(ctx, e).
+			Column("status", "last_error").
+			WherePK().
+			Update(); err != nil {
+			log.Errorf("Claim - Cannot mark the email as failed: %s", err.Error())
+		}
+	}
+	return emails, nil
+}
+
+func (repo *postgreRepository) Update(ctx context.Context, e *models.Email) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "outbox.Update")
+	defer cancel()
+	log := repo.logrus.WithField("id", e.ID).WithField("status", e.Status)
+	log.Debug("Update")
+	if _, err := postgres.Conn(ctx, repo.DB).
+		ModelContext(ctx, e).
+		WherePK().
+		Returning("*").
+		Update(); err != nil {
+		log.Debugf("Update err: %s", err.Error())
+		if err == pg.ErrNoRows {
+			return _errors.Wrap(_errors.ErrEmailNotFound, err)
+		}
+		return constraintErrors.Translate(ctx, err, _errors.ErrInternalServerError)
+	}
+	return nil
+}
+
+func (repo *postgreRepository) DeleteFailedData(ctx context.Context, before time.Time) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "outbox.DeleteFailedData")
+	defer cancel()
+	log := repo.logrus.WithField("before", before)
+	log.Debug("DeleteFailedData")
+	if _, err := postgres.Conn(ctx, repo.DB).
+		ModelContext(ctx, (*models.Email)(nil)).
+		Set("data = NULL").
+		Where("status = ?", models.EmailStatusFailed).
+		Where("data IS NOT NULL").
+		Where("next_attempt_at < ?", before).
+		Update(); err != nil && err != pg.ErrNoRows {
+		log.Debugf("DeleteFailedData err: %s", err.Error())
+		return postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return nil
+}
+
+func (repo *postgreRepository) DeleteByRecipient(ctx context.Context, to string) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "outbox.DeleteByRecipient")
+	defer cancel()
+	log := repo.logrus.WithField("to", to)
+	log.Debug("DeleteByRecipient")
+	if _, err := postgres.Conn(ctx, repo.DB).
+		ModelContext(ctx, (*models.Email)(nil)).
+		Where("? = ?", pg.Ident("to"), to).
+		Delete(); err != nil && err != pg.ErrNoRows {
+		log.Debugf("DeleteByRecipient err: %s", err.Error())
+		return postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return nil
+}
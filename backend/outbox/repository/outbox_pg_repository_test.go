@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	_errors "backend/errors"
+	"backend/models"
+	"backend/postgres"
+	"backend/utils"
+	"backend/utils/testutil"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPgRepositoryConstraints raises every registered violation, so the names of the constraints
+// are checked against the schema.
+func TestPgRepositoryConstraints(t *testing.T) {
+	conn := utils.ConnectToPostgreTestDB(false)
+	defer conn.Close()
+	ctx := context.Background()
+	require.Equal(t, nil, models.SetEmailDataKey("secret"))
+
+	tests := []struct {
+		name       string
+		email      *models.Email
+		constraint string
+		code       string
+		field      string
+	}{
+		{"to", &models.Email{Template: "activation"}, "email_outbox.to", _errors.ErrEmailPolicy, "to"},
+		{"template", &models.Email{To: "user@example.com"}, "email_outbox.template", _errors.ErrInvalidPayload, "template"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the failed statement aborts the transaction, every violation has its own
+			tx, err := conn.Begin()
+			require.Equal(t, nil, err)
+			defer tx.Rollback()
+			repo, err := NewPostgreOutboxRepository(tx, postgres.Timeouts{})
+			require.Equal(t, nil, err)
+
+			err = repo.Enqueue(ctx, test.email)
+			require.Equal(t, test.constraint, testutil.Violation(err))
+			gqlErr := _errors.ToGqlError(err)
+			require.Equal(t, test.code, _errors.Code(gqlErr))
+			require.Equal(t, test.field, gqlErr.Extensions["field"])
+		})
+	}
+}
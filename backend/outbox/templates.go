@@ -0,0 +1,11 @@
+package outbox
+
+// Templates of the emails, see email/templates.
+const (
+	ActivateAccountTemplate = "activation"
+	ResetPasswordTemplate   = "reset_password"
+	PasswordChangedTemplate = "password_changed"
+	DataExportTemplate      = "data_export"
+	AccountDeletionTemplate = "account_deletion"
+	InvitationTemplate      = "invitation"
+)
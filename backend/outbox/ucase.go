@@ -0,0 +1,12 @@
+package outbox
+
+import (
+	"context"
+
+	"backend/models"
+)
+
+type Usecase interface {
+	Fetch(ctx context.Context, f *models.EmailFilter) (models.EmailList, error)
+	Retry(ctx context.Context, id int) (*models.Email, error)
+}
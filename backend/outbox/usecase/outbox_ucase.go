@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	_errors "backend/errors"
+	"backend/models"
+	"backend/outbox"
+	"backend/utils"
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultLimit = 100
+)
+
+type Config struct {
+	OutboxRepo outbox.Repository
+	// MaxLimit caps EmailFilter.Limit, 0 means no cap.
+	MaxLimit int
+}
+
+type usecase struct {
+	outboxRepo outbox.Repository
+	maxLimit   int
+	logrus     *logrus.Entry
+}
+
+func NewOutboxUsecase(cfg Config) outbox.Usecase {
+	return &usecase{
+		cfg.OutboxRepo,
+		cfg.MaxLimit,
+		logrus.WithField("package", "outbox/usecase"),
+	}
+}
+
+func (ucase *usecase) Fetch(ctx context.Context, f *models.EmailFilter) (models.EmailList, error) {
+	ucase.logrus.WithField("filter", f).Debug("Fetch")
+	if f == nil {
+		f = &models.EmailFilter{}
+	}
+	f.Limit = utils.NormalizeLimit(f.Limit, DefaultLimit, ucase.maxLimit)
+	return ucase.outboxRepo.Fetch(ctx, f)
+}
+
+// Retry moves the email back to the queue with a fresh attempt counter.
+func (ucase *usecase) Retry(ctx context.Context, id int) (*models.Email, error) {
+	entry := ucase.logrus.WithField("id", id)
+	entry.Debug("Retry")
+	e, err := ucase.outboxRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if e.Status == models.EmailStatusSent {
+		entry.Debug("Retry - The email has been sent.")
+		return nil, _errors.Wrap(_errors.ErrEmailAlreadySent)
+	} else if e.Data == nil {
+		// the data of the failed emails is deleted after the retention
+		entry.Debug("Retry - The email data has been deleted.")
+		return nil, _errors.Wrap(_errors.ErrEmailDataDeleted)
+	}
+	e.Status = models.EmailStatusPending
+	e.Attempts = 0
+	e.NextAttemptAt = time.Now()
+	if err := ucase.outboxRepo.Update(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,209 @@
+package worker
+
+import (
+	"backend/email"
+	"backend/models"
+	"backend/outbox"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/gomail.v2"
+)
+
+const (
+	DefaultConcurrency  = 4
+	DefaultPollInterval = time.Second
+	DefaultMaxAttempts  = 10
+	DefaultMinBackoff   = 10 * time.Second
+	DefaultMaxBackoff   = time.Hour
+	DefaultLease        = 5 * time.Minute
+	// DefaultFailedRetention is the time for which the data of a failed email is kept for a retry.
+	DefaultFailedRetention = 7 * 24 * time.Hour
+)
+
+// purgeInterval is the interval between the deletions of the data of the failed emails.
+var purgeInterval = time.Hour
+
+type Config struct {
+	OutboxRepo outbox.Repository
+	Mailer     email.Email
+	// Concurrency is the number of emails sent at the same time.
+	Concurrency  int
+	PollInterval time.Duration
+	// MaxAttempts is the number of failed attempts after which the email is moved to the dead-letter state.
+	MaxAttempts int
+	// The delay between attempts doubles from MinBackoff up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Lease is the time after which an email claimed by a worker which did not finish is claimed again.
+	Lease time.Duration
+	// FailedRetention is the time after which the data of a failed email is deleted and it can't be retried.
+	FailedRetention time.Duration
+}
+
+// Worker sends the emails from the outbox.
+type Worker struct {
+	cfg    Config
+	logrus *logrus.Entry
+	jobs   chan *models.Email
+	quit   chan struct{}
+	wg     sync.WaitGroup
+	// purgedAt is the time of the last deletion of the failed data, it is used by poll only.
+	purgedAt time.Time
+}
+
+func New(cfg Config) *Worker {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = DefaultConcurrency
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = DefaultLease
+	}
+	if cfg.FailedRetention <= 0 {
+		cfg.FailedRetention = DefaultFailedRetention
+	}
+	return &Worker{
+		cfg:    cfg,
+		logrus: logrus.WithField("package", "outbox/worker"),
+		jobs:   make(chan *models.Email),
+		quit:   make(chan struct{}),
+	}
+}
+
+func (w *Worker) Start() {
+	w.wg.Add(w.cfg.Concurrency)
+	for i := 0; i < w.cfg.Concurrency; i++ {
+		go func() {
+			defer w.wg.Done()
+			for e := range w.jobs {
+				w.send(e)
+			}
+		}()
+	}
+	go w.poll()
+}
+
+// Shutdown stops claiming new emails and waits until the claimed ones are sent or ctx is done.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	close(w.quit)
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Worker) poll() {
+	defer close(w.jobs)
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		if time.Since(w.purgedAt) >= purgeInterval {
+			w.purge()
+		}
+		emails, err := w.cfg.OutboxRepo.Claim(context.Background(), w.cfg.Concurrency, w.cfg.Lease)
+		if err != nil {
+			w.logrus.Errorf("Cannot claim emails: %s", err.Error())
+		}
+		for _, e := range emails {
+			w.jobs <- e
+		}
+		if len(emails) == w.cfg.Concurrency {
+			// there may be more due emails
+			select {
+			case <-w.quit:
+				return
+			default:
+				continue
+			}
+		}
+		select {
+		case <-w.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) send(e *models.Email) {
+	entry := w.logrus.WithField("id", e.ID).WithField("template", e.Template)
+	msg, err := w.compose(e)
+	if err == nil {
+		err = w.cfg.Mailer.Send(msg)
+	}
+	now := time.Now()
+	e.Attempts++
+	if err != nil {
+		entry.Debugf("Cannot send email (attempt %d): %s", e.Attempts, err.Error())
+		e.LastError = err.Error()
+		if e.Attempts >= w.cfg.MaxAttempts {
+			entry.Warnf("Email moved to the dead-letter state after %d attempts", e.Attempts)
+			e.Status = models.EmailStatusFailed
+			// the data is kept for a retry, the purge deletes it after the retention counted from now
+			e.NextAttemptAt = now
+		} else {
+			e.NextAttemptAt = now.Add(w.backoff(e.Attempts))
+		}
+	} else {
+		e.Status = models.EmailStatusSent
+		e.SentAt = &now
+		// the data may contain tokens or passwords, it isn't needed anymore
+		e.Data = nil
+	}
+	if err := w.cfg.OutboxRepo.Update(context.Background(), e); err != nil {
+		entry.Errorf("Cannot update email: %s", err.Error())
+	}
+}
+
+// purge deletes the tokens and passwords of the emails which failed before the retention.
+func (w *Worker) purge() {
+	w.purgedAt = time.Now()
+	if err := w.cfg.OutboxRepo.DeleteFailedData(context.Background(), w.purgedAt.Add(-w.cfg.FailedRetention)); err != nil {
+		w.logrus.Errorf("Cannot delete the data of the failed emails: %s", err.Error())
+	}
+}
+
+func (w *Worker) backoff(attempts int) time.Duration {
+	d := w.cfg.MinBackoff
+	for i := 1; i < attempts && d < w.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > w.cfg.MaxBackoff {
+		d = w.cfg.MaxBackoff
+	}
+	return d
+}
+
+func (w *Worker) compose(e *models.Email) (*gomail.Message, error) {
+	rendered, err := w.cfg.Mailer.Render(e.Template, e.Language, e.Data)
+	if err != nil {
+		return nil, err
+	}
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", w.cfg.Mailer.GetAddress())
+	msg.SetHeader("To", e.To)
+	msg.SetHeader("Subject", rendered.Subject)
+	msg.SetBody("text/plain", rendered.Text)
+	msg.AddAlternative("text/html", rendered.HTML)
+	return msg, nil
+}
@@ -0,0 +1,206 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/email"
+	"backend/models"
+	"backend/outbox"
+	"backend/outbox/repository"
+	"backend/outbox/usecase"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+	"gopkg.in/gomail.v2"
+)
+
+type stubMailer struct {
+	mu       sync.Mutex
+	failures int
+	sent     []*gomail.Message
+}
+
+func (m *stubMailer) Send(msg *gomail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failures > 0 {
+		m.failures--
+		return fmt.Errorf("connection refused")
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *stubMailer) Render(name, lang string, data map[string]interface{}) (*email.Message, error) {
+	return &email.Message{
+		Language: language.English,
+		Subject:  name,
+		HTML:     fmt.Sprint(data),
+		Text:     fmt.Sprint(data),
+	}, nil
+}
+
+func (m *stubMailer) LoadTemplates(dir string) error {
+	return nil
+}
+
+func (m *stubMailer) Templates() []string {
+	return nil
+}
+
+func (m *stubMailer) Languages() []language.Tag {
+	return nil
+}
+
+func (m *stubMailer) GetAddress() string {
+	return "noreply@example.com"
+}
+
+func (m *stubMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sent)
+}
+
+func newTestWorker(repo outbox.Repository, mailer *stubMailer, maxAttempts int) *Worker {
+	return New(Config{
+		OutboxRepo:   repo,
+		Mailer:       mailer,
+		PollInterval: time.Millisecond,
+		MaxAttempts:  maxAttempts,
+		MinBackoff:   time.Millisecond,
+		MaxBackoff:   2 * time.Millisecond,
+	})
+}
+
+func waitForStatus(t *testing.T, repo outbox.Repository, id int, status models.EmailStatus) *models.Email {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if e, _ := repo.GetByID(context.Background(), id); e.Status == status {
+			return e
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("email %d is not %s", id, status)
+	return nil
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("retries until the email is sent", func(t *testing.T) {
+		repo := repository.NewMemoryOutboxRepository()
+		mailer := &stubMailer{failures: 2}
+		repo.Enqueue(context.Background(), &models.Email{
+			To:       "user@example.com",
+			Template: "activation",
+			Data:     map[string]interface{}{"Login": "user"},
+		})
+		w := newTestWorker(repo, mailer, 5)
+		w.Start()
+		defer w.Shutdown(context.Background())
+
+		e := waitForStatus(t, repo, 1, models.EmailStatusSent)
+		require.Equal(t, 3, e.Attempts)
+		require.Equal(t, "connection refused", e.LastError)
+		require.Equal(t, true, e.Data == nil)
+		require.Equal(t, 1, mailer.count())
+		require.Equal(t, []string{"user@example.com"}, mailer.sent[0].GetHeader("To"))
+	})
+
+	t.Run("moves the email to the dead-letter state", func(t *testing.T) {
+		repo := repository.NewMemoryOutboxRepository()
+		mailer := &stubMailer{failures: 100}
+		repo.Enqueue(context.Background(), &models.Email{To: "user@example.com", Template: "activation", Data: map[string]interface{}{"Href": "token"}})
+		w := newTestWorker(repo, mailer, 3)
+		w.Start()
+		defer w.Shutdown(context.Background())
+
+		e := waitForStatus(t, repo, 1, models.EmailStatusFailed)
+		require.Equal(t, 3, e.Attempts)
+		require.Equal(t, models.EmailData{"Href": "token"}, e.Data)
+		require.Equal(t, 0, mailer.count())
+	})
+
+	t.Run("sends a failed email after a retry", func(t *testing.T) {
+		repo := repository.NewMemoryOutboxRepository()
+		mailer := &stubMailer{failures: 3}
+		repo.Enqueue(context.Background(), &models.Email{To: "user@example.com", Template: "activation", Data: map[string]interface{}{"Href": "token"}})
+		w := newTestWorker(repo, mailer, 3)
+		w.Start()
+		defer w.Shutdown(context.Background())
+		waitForStatus(t, repo, 1, models.EmailStatusFailed)
+
+		retried, err := usecase.NewOutboxUsecase(usecase.Config{OutboxRepo: repo}).Retry(context.Background(), 1)
+		require.Equal(t, nil, err)
+		require.Equal(t, models.EmailStatusPending, retried.Status)
+		require.Equal(t, 0, retried.Attempts)
+
+		e := waitForStatus(t, repo, 1, models.EmailStatusSent)
+		require.Equal(t, 1, e.Attempts)
+		require.Equal(t, true, e.Data == nil)
+		require.Equal(t, 1, mailer.count())
+		// the kept data is rendered
+		body := &bytes.Buffer{}
+		_, err = mailer.sent[0].WriteTo(body)
+		require.Equal(t, nil, err)
+		require.Contains(t, body.String(), "map[Href:token]")
+	})
+
+	t.Run("deletes the data of the failed emails after the retention", func(t *testing.T) {
+		repo := repository.NewMemoryOutboxRepository()
+		for i := 0; i < 2; i++ {
+			repo.Enqueue(context.Background(), &models.Email{To: "user@example.com", Template: "activation", Data: map[string]interface{}{"Href": "token"}})
+		}
+		for id, failedAt := range map[int]time.Time{1: time.Now().Add(-2 * time.Hour), 2: time.Now()} {
+			e, err := repo.GetByID(context.Background(), id)
+			require.Equal(t, nil, err)
+			e.Status = models.EmailStatusFailed
+			e.NextAttemptAt = failedAt
+			require.Equal(t, nil, repo.Update(context.Background(), e))
+		}
+		w := New(Config{OutboxRepo: repo, FailedRetention: time.Hour})
+		w.purge()
+		old, _ := repo.GetByID(context.Background(), 1)
+		require.Equal(t, true, old.Data == nil)
+		recent, _ := repo.GetByID(context.Background(), 2)
+		require.Equal(t, models.EmailData{"Href": "token"}, recent.Data)
+
+		_, err := usecase.NewOutboxUsecase(usecase.Config{OutboxRepo: repo}).Retry(context.Background(), 1)
+		require.NotEqual(t, nil, err)
+	})
+
+	t.Run("backoff doubles up to the maximum", func(t *testing.T) {
+		w := New(Config{MinBackoff: time.Second, MaxBackoff: 5 * time.Second})
+		require.Equal(t, time.Second, w.backoff(1))
+		require.Equal(t, 2*time.Second, w.backoff(2))
+		require.Equal(t, 4*time.Second, w.backoff(3))
+		require.Equal(t, 5*time.Second, w.backoff(4))
+	})
+
+	t.Run("shutdown waits for the claimed emails", func(t *testing.T) {
+		repo := repository.NewMemoryOutboxRepository()
+		mailer := &stubMailer{}
+		for i := 0; i < 10; i++ {
+			repo.Enqueue(context.Background(), &models.Email{To: "user@example.com", Template: "activation"})
+		}
+		w := newTestWorker(repo, mailer, 3)
+		w.Start()
+		waitForStatus(t, repo, 1, models.EmailStatusSent)
+		require.Equal(t, nil, w.Shutdown(context.Background()))
+
+		sent := 0
+		for i := 1; i <= 10; i++ {
+			e, _ := repo.GetByID(context.Background(), i)
+			if e.Status == models.EmailStatusSent {
+				sent++
+			} else {
+				require.Equal(t, 0, e.Attempts)
+			}
+		}
+		require.Equal(t, sent, mailer.count())
+	})
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+
+	_errors "backend/errors"
+
+	"github.com/go-pg/pg/v9"
+)
+
+// SQLSTATE codes of the integrity constraint violations, https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	NotNullViolation    = "23502"
+	ForeignKeyViolation = "23503"
+	UniqueViolation     = "23505"
+	CheckViolation      = "23514"
+)
+
+// Violation is the integrity constraint violated by a statement.
+type Violation struct {
+	// Code is the SQLSTATE code.
+	Code       string
+	Constraint string
+	Table      string
+	// Column is set for the not-null violations, they have no constraint name.
+	Column string
+}
+
+// ViolationFromError returns the violation when err is an integrity constraint violation reported by Postgres.
+func ViolationFromError(err error) (Violation, bool) {
+	pgErr, ok := err.(pg.Error)
+	if !ok || !pgErr.IntegrityViolation() {
+		return Violation{}, false
+	}
+	return Violation{
+		Code:       pgErr.Field('C'),
+		Constraint: pgErr.Field('n'),
+		Table:      pgErr.Field('t'),
+		Column:     pgErr.Field('c'),
+	}, true
+}
+
+type violationKey struct {
+	code string
+	// name is the constraint or, for the not-null violations, table.column.
+	name string
+}
+
+func (v Violation) key() violationKey {
+	if v.Code == NotNullViolation {
+		return violationKey{v.Code, v.Table + "." + v.Column}
+	}
+	return violationKey{v.Code, v.Constraint}
+}
+
+type errorMapping struct {
+	code  string
+	field string
+}
+
+// ErrorRegistry maps the constraint violations of a repository to the domain errors,
+// the field is the input field which caused the violation and it can be empty.
+type ErrorRegistry struct {
+	mappings map[violationKey]errorMapping
+}
+
+func NewErrorRegistry() *ErrorRegistry {
+	return &ErrorRegistry{map[violationKey]errorMapping{}}
+}
+
+func (r *ErrorRegistry) Unique(constraint, code, field string) *ErrorRegistry {
+	return r.register(violationKey{UniqueViolation, constraint}, code, field)
+}
+
+func (r *ErrorRegistry) ForeignKey(constraint, code, field string) *ErrorRegistry {
+	return r.register(violationKey{ForeignKeyViolation, constraint}, code, field)
+}
+
+func (r *ErrorRegistry) Check(constraint, code, field string) *ErrorRegistry {
+	return r.register(violationKey{CheckViolation, constraint}, code, field)
+}
+
+func (r *ErrorRegistry) NotNull(table, column, code, field string) *ErrorRegistry {
+	return r.register(violationKey{NotNullViolation, table + "." + column}, code, field)
+}
+
+func (r *ErrorRegistry) register(key violationKey, code, field string) *ErrorRegistry {
+	r.mappings[key] = errorMapping{code, field}
+	return r
+}
+
+// Translate wraps err with the domain error of the violated constraint,
+// the errors which aren't registered violations are wrapped by WrapError.
+func (r *ErrorRegistry) Translate(ctx context.Context, err error, fallback string) error {
+	if violation, ok := ViolationFromError(err); ok {
+		if mapping, ok := r.mappings[violation.key()]; ok {
+			return _errors.WrapField(mapping.code, mapping.field, nil, err)
+		}
+	}
+	return WrapError(ctx, err, fallback)
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	_errors "backend/errors"
+
+	"github.com/stretchr/testify/require"
+)
+
+type pgError map[byte]string
+
+func (e pgError) Error() string {
+	return "ERROR #" + e['C'] + " " + e['M']
+}
+
+func (e pgError) Field(k byte) string {
+	return e[k]
+}
+
+func (e pgError) IntegrityViolation() bool {
+	switch e['C'] {
+	case NotNullViolation, ForeignKeyViolation, UniqueViolation, CheckViolation:
+		return true
+	}
+	return false
+}
+
+// TestErrorRegistry checks the translation of the errors, the registries of the repositories are checked
+// against the schema by their tests.
+func TestErrorRegistry(t *testing.T) {
+	registry := NewErrorRegistry().
+		Unique("users_login_key", _errors.ErrLoginMustBeUnique, "login").
+		ForeignKey("child_parent_id_fkey", _errors.ErrUserNotFound, "").
+		Check("child_max_uses_check", _errors.ErrInvitationMaxUsesPolicy, "maxUses").
+		NotNull("email_outbox", "to", _errors.ErrEmailPolicy, "to")
+
+	tests := []struct {
+		name  string
+		err   error
+		code  string
+		field string
+	}{
+		{
+			name:  "unique",
+			err:   pgError{'C': UniqueViolation, 'n': "users_login_key", 't': "users"},
+			code:  _errors.ErrLoginMustBeUnique,
+			field: "login",
+		},
+		{
+			name: "foreign key",
+			err:  pgError{'C': ForeignKeyViolation, 'n': "child_parent_id_fkey", 't': "child"},
+			code: _errors.ErrUserNotFound,
+		},
+		{
+			name:  "check",
+			err:   pgError{'C': CheckViolation, 'n': "child_max_uses_check", 't': "child"},
+			code:  _errors.ErrInvitationMaxUsesPolicy,
+			field: "maxUses",
+		},
+		{
+			name:  "not null",
+			err:   pgError{'C': NotNullViolation, 't': "email_outbox", 'c': "to"},
+			code:  _errors.ErrEmailPolicy,
+			field: "to",
+		},
+		{
+			name: "not null of other table",
+			err:  pgError{'C': NotNullViolation, 't': "users", 'c': "to"},
+			code: _errors.ErrInternalServerError,
+		},
+		{
+			// the message mentions the login, but the constraint isn't registered
+			name: "unregistered constraint",
+			err:  pgError{'C': UniqueViolation, 'n': "users_slug_key", 'M': `duplicate key value (login)`},
+			code: _errors.ErrInternalServerError,
+		},
+		{
+			name: "not a violation",
+			err:  pgError{'C': "42P01", 'n': "users_login_key"},
+			code: _errors.ErrInternalServerError,
+		},
+		{
+			name: "not a postgres error",
+			err:  errors.New("users_login_key"),
+			code: _errors.ErrInternalServerError,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := _errors.ToGqlError(registry.Translate(context.Background(), test.err, _errors.ErrInternalServerError))
+			require.Equal(t, test.code, _errors.Code(err))
+			field, _ := err.Extensions["field"].(string)
+			require.Equal(t, test.field, field)
+		})
+	}
+}
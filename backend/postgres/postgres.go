@@ -55,6 +55,10 @@ const (
 		CREATE TRIGGER set_slug_user
 		BEFORE INSERT ON users FOR EACH ROW
 		WHEN (NEW.login IS NOT NULL AND NEW.slug IS NULL) EXECUTE PROCEDURE set_slug_from_login();
+		DROP TRIGGER IF EXISTS update_slug_user ON users;
+		CREATE TRIGGER update_slug_user
+		BEFORE UPDATE OF login ON users FOR EACH ROW
+		WHEN (NEW.login IS DISTINCT FROM OLD.login) EXECUTE PROCEDURE set_slug_from_login();
 	`
 )
 
@@ -0,0 +1,220 @@
+package postgres
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+)
+
+// Policy chooses the replica which serves a read, it is called with at least one replica.
+type Policy interface {
+	Choose(replicas []DB) DB
+}
+
+type roundRobinPolicy struct {
+	next uint32
+}
+
+func NewRoundRobinPolicy() Policy {
+	return &roundRobinPolicy{}
+}
+
+func (p *roundRobinPolicy) Choose(replicas []DB) DB {
+	return replicas[(atomic.AddUint32(&p.next, 1)-1)%uint32(len(replicas))]
+}
+
+type randomPolicy struct{}
+
+func NewRandomPolicy() Policy {
+	return randomPolicy{}
+}
+
+func (randomPolicy) Choose(replicas []DB) DB {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+type ClusterConfig struct {
+	Primary  DB
+	Replicas []DB
+	// Policy is round robin by default.
+	Policy Policy
+	// ReadYourWrites is how long the reads of a session go to the primary after the session writes,
+	// it should exceed the replication lag.
+	ReadYourWrites time.Duration
+}
+
+// Cluster is the primary with its read replicas. It is a DB itself, all its methods, the writes
+// and the transactions go to the primary, only ReadConn returns the replicas.
+type Cluster struct {
+	DB
+	replicas       []DB
+	policy         Policy
+	readYourWrites time.Duration
+}
+
+func NewCluster(cfg ClusterConfig) *Cluster {
+	if cfg.Policy == nil {
+		cfg.Policy = NewRoundRobinPolicy()
+	}
+	return &Cluster{
+		DB:             cfg.Primary,
+		replicas:       cfg.Replicas,
+		policy:         cfg.Policy,
+		readYourWrites: cfg.ReadYourWrites,
+	}
+}
+
+// Close closes the replicas and the primary.
+func (c *Cluster) Close() error {
+	var err error
+	for _, replica := range c.replicas {
+		if closeErr := replica.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	if closeErr := c.DB.Close(); closeErr != nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (c *Cluster) reader(ctx context.Context) DB {
+	if len(c.replicas) == 0 || ctx.Value(primaryContextKey{}) != nil {
+		return c.DB
+	}
+	if w, ok := ctx.Value(writesContextKey{}).(*Writes); ok && time.Since(w.Last()) < c.readYourWrites {
+		return c.DB
+	}
+	return c.policy.Choose(c.replicas)
+}
+
+// ReadConn returns the transaction carried by ctx or, if db is a Cluster, the connection chosen for a read.
+// The repositories run their read-only queries on it, the other queries run on Conn.
+func ReadConn(ctx context.Context, db DB) DB {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return state.tx
+	}
+	if c, ok := db.(*Cluster); ok {
+		return c.reader(ctx)
+	}
+	return db
+}
+
+type primaryContextKey struct{}
+
+// WithPrimary returns ctx whose reads go to the primary, e.g. to fill a cache which would keep the lagging data.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+type writesContextKey struct{}
+
+// Writes is the time of the last write of a session, Conn records it for the Writes carried by the context
+// after the write succeeds.
+type Writes struct {
+	last int64
+}
+
+// NewWrites returns the Writes of a session which last wrote at last, e.g. during its previous request.
+func NewWrites(last time.Time) *Writes {
+	w := &Writes{}
+	if !last.IsZero() {
+		w.last = last.UnixNano()
+	}
+	return w
+}
+
+// WithWrites returns ctx whose reads see the writes recorded by w.
+func WithWrites(ctx context.Context, w *Writes) context.Context {
+	return context.WithValue(ctx, writesContextKey{}, w)
+}
+
+func (w *Writes) Last() time.Time {
+	if last := atomic.LoadInt64(&w.last); last != 0 {
+		return time.Unix(0, last)
+	}
+	return time.Time{}
+}
+
+func (w *Writes) record() {
+	atomic.StoreInt64(&w.last, time.Now().UnixNano())
+}
+
+// writeConn records the statements which succeed, the model queries are built on it
+// so their statements are recorded too.
+type writeConn struct {
+	DB
+	writes *Writes
+}
+
+func (c *writeConn) recorded(res pg.Result, err error) (pg.Result, error) {
+	if err == nil {
+		c.writes.record()
+	}
+	return res, err
+}
+
+func (c *writeConn) Model(model ...interface{}) *orm.Query {
+	return orm.NewQuery(c, model...)
+}
+
+func (c *writeConn) ModelContext(ctx context.Context, model ...interface{}) *orm.Query {
+	return orm.NewQueryContext(ctx, c, model...)
+}
+
+func (c *writeConn) Exec(query interface{}, params ...interface{}) (pg.Result, error) {
+	return c.recorded(c.DB.Exec(query, params...))
+}
+
+func (c *writeConn) ExecContext(ctx context.Context, query interface{}, params ...interface{}) (pg.Result, error) {
+	return c.recorded(c.DB.ExecContext(ctx, query, params...))
+}
+
+func (c *writeConn) ExecOne(query interface{}, params ...interface{}) (pg.Result, error) {
+	return c.recorded(c.DB.ExecOne(query, params...))
+}
+
+func (c *writeConn) ExecOneContext(ctx context.Context, query interface{}, params ...interface{}) (pg.Result, error) {
+	return c.recorded(c.DB.ExecOneContext(ctx, query, params...))
+}
+
+func (c *writeConn) Query(model, query interface{}, params ...interface{}) (pg.Result, error) {
+	return c.recorded(c.DB.Query(model, query, params...))
+}
+
+func (c *writeConn) QueryContext(ctx context.Context, model, query interface{}, params ...interface{}) (pg.Result, error) {
+	return c.recorded(c.DB.QueryContext(ctx, model, query, params...))
+}
+
+func (c *writeConn) QueryOne(model, query interface{}, params ...interface{}) (pg.Result, error) {
+	return c.recorded(c.DB.QueryOne(model, query, params...))
+}
+
+func (c *writeConn) QueryOneContext(ctx context.Context, model, query interface{}, params ...interface{}) (pg.Result, error) {
+	return c.recorded(c.DB.QueryOneContext(ctx, model, query, params...))
+}
+
+func (c *writeConn) CopyFrom(r io.Reader, query interface{}, params ...interface{}) (pg.Result, error) {
+	return c.recorded(c.DB.CopyFrom(r, query, params...))
+}
+
+func (c *writeConn) Insert(model ...interface{}) error {
+	return orm.Insert(c, model...)
+}
+
+func (c *writeConn) Update(model interface{}) error {
+	return orm.Update(c, model)
+}
+
+func (c *writeConn) Delete(model interface{}) error {
+	return orm.Delete(c, model)
+}
+
+func (c *writeConn) ForceDelete(model interface{}) error {
+	return orm.ForceDelete(c, model)
+}
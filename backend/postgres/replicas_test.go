@@ -0,0 +1,96 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/postgres"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// execDB runs the statements without a database, they fail with err.
+type execDB struct {
+	postgres.DB
+	err error
+}
+
+func (db execDB) ExecContext(c context.Context, query interface{}, params ...interface{}) (pg.Result, error) {
+	return nil, db.err
+}
+
+func (db execDB) QueryContext(c context.Context, model, query interface{}, params ...interface{}) (pg.Result, error) {
+	return nil, db.err
+}
+
+func TestCluster(t *testing.T) {
+	// pg.Connect doesn't dial, the connections are only compared
+	primary, replica1, replica2 := pg.Connect(&pg.Options{}), pg.Connect(&pg.Options{}), pg.Connect(&pg.Options{})
+	cluster := postgres.NewCluster(postgres.ClusterConfig{
+		Primary:        primary,
+		Replicas:       []postgres.DB{replica1, replica2},
+		ReadYourWrites: time.Minute,
+	})
+	defer cluster.Close()
+	ctx := context.Background()
+
+	t.Run("Round robin", func(t *testing.T) {
+		first := postgres.ReadConn(ctx, cluster)
+		second := postgres.ReadConn(ctx, cluster)
+		require.Equal(t, true, first == replica1 || first == replica2)
+		require.Equal(t, true, second == replica1 || second == replica2)
+		require.Equal(t, false, first == second)
+		require.Equal(t, true, first == postgres.ReadConn(ctx, cluster))
+		// the writes go to the primary
+		require.Equal(t, true, postgres.Conn(ctx, cluster) == postgres.DB(cluster))
+	})
+
+	t.Run("Read your writes", func(t *testing.T) {
+		writes := postgres.NewWrites(time.Now().Add(-2 * time.Minute))
+		ctx := postgres.WithWrites(ctx, writes)
+		require.Equal(t, false, postgres.ReadConn(ctx, cluster) == primary)
+
+		// only the statements which succeed are recorded
+		_, err := postgres.Conn(ctx, execDB{err: errors.New("failed")}).ExecContext(ctx, "INSERT")
+		require.NotEqual(t, nil, err)
+		_, _ = postgres.TxConn(ctx, execDB{}).ExecContext(ctx, "SELECT pg_notify('channel', 'payload')")
+		require.Equal(t, false, postgres.ReadConn(ctx, cluster) == primary)
+
+		_, err = postgres.Conn(ctx, execDB{}).ExecContext(ctx, "INSERT")
+		require.Equal(t, nil, err)
+		require.Equal(t, true, time.Since(writes.Last()) < time.Second)
+		require.Equal(t, true, postgres.ReadConn(ctx, cluster) == primary)
+
+		// the session wrote during its previous request
+		ctx = postgres.WithWrites(context.Background(), postgres.NewWrites(time.Now().Add(-time.Second)))
+		require.Equal(t, true, postgres.ReadConn(ctx, cluster) == primary)
+	})
+
+	t.Run("Failed writes and locking reads", func(t *testing.T) {
+		lastWriteAt := time.Now().Add(-2 * time.Minute)
+		writes := postgres.NewWrites(lastWriteAt)
+		ctx := postgres.WithWrites(ctx, writes)
+
+		_, err := postgres.Conn(ctx, execDB{err: errors.New("failed")}).ExecContext(ctx, "UPDATE users SET bio = '' WHERE id = 1")
+		require.NotEqual(t, nil, err)
+		_, err = postgres.Conn(ctx, execDB{err: errors.New("failed")}).QueryContext(ctx, nil, "INSERT INTO users DEFAULT VALUES RETURNING id")
+		require.NotEqual(t, nil, err)
+		// the repositories lock the rows on TxConn
+		_, err = postgres.TxConn(ctx, execDB{}).QueryContext(ctx, nil, "SELECT id FROM users WHERE id = 1 FOR UPDATE")
+		require.Equal(t, nil, err)
+		require.Equal(t, true, writes.Last().Equal(lastWriteAt))
+		require.Equal(t, false, postgres.ReadConn(ctx, cluster) == primary)
+	})
+
+	t.Run("Primary", func(t *testing.T) {
+		require.Equal(t, true, postgres.ReadConn(postgres.WithPrimary(ctx), cluster) == primary)
+	})
+
+	t.Run("Without replicas", func(t *testing.T) {
+		cluster := postgres.NewCluster(postgres.ClusterConfig{Primary: primary})
+		require.Equal(t, true, postgres.ReadConn(ctx, cluster) == primary)
+	})
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	_errors "backend/errors"
+
+	"github.com/go-pg/pg/v9"
+)
+
+// Timeouts are the query timeouts of the repository operations, zero means no timeout.
+type Timeouts struct {
+	Default time.Duration
+	// Operations override Default, the keys are "repository.method", e.g. "user.fetch", and are case-insensitive.
+	Operations map[string]time.Duration
+}
+
+// For returns the timeout of the operation.
+func (t Timeouts) For(operation string) time.Duration {
+	if timeout, ok := t.Operations[strings.ToLower(operation)]; ok {
+		return timeout
+	}
+	return t.Default
+}
+
+// WithTimeout returns ctx with the deadline of the operation, the queries run with the returned context
+// are canceled when the deadline is exceeded or ctx is canceled.
+func (t Timeouts) WithTimeout(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	if timeout := t.For(operation); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// WithStatementTimeout runs fn with the statement_timeout of the Postgres session set to the time left
+// until the deadline of ctx, for the statements which go-pg can't cancel with ctx, e.g. COPY. The timeout
+// is local to the transaction carried by ctx or to the one started on db, so ctx expires with it and
+// WrapError reports ErrTimeout. Without a deadline fn runs on db.
+func WithStatementTimeout(ctx context.Context, db DB, fn func(db DB) error) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return fn(db)
+	}
+	setTimeout := func(tx DB) error {
+		timeout := time.Until(deadline).Milliseconds()
+		if timeout < 1 {
+			return context.DeadlineExceeded
+		}
+		_, err := tx.ExecContext(ctx, "SET LOCAL statement_timeout = ?", timeout)
+		return err
+	}
+	inner, writes := db, (*Writes)(nil)
+	if c, ok := db.(*writeConn); ok {
+		inner, writes = c.DB, c.writes
+	}
+	// RunInTransaction of a *pg.Tx commits it
+	if _, ok := inner.(*pg.Tx); ok || InTx(ctx) {
+		if err := setTimeout(inner); err != nil {
+			return err
+		}
+		if err := fn(db); err != nil {
+			return err
+		}
+		_, err := inner.ExecContext(ctx, "SET LOCAL statement_timeout TO DEFAULT")
+		return err
+	}
+	return inner.RunInTransaction(func(tx *pg.Tx) error {
+		if err := setTimeout(tx); err != nil {
+			return err
+		}
+		if writes != nil {
+			return fn(&writeConn{tx, writes})
+		}
+		return fn(tx)
+	})
+}
+
+// WrapError wraps err with ErrTimeout when the deadline of ctx is exceeded, otherwise with the fallback code.
+// Postgres reports the canceled queries as its own errors, so ctx decides.
+func WrapError(ctx context.Context, err error, fallback string) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return _errors.Wrap(_errors.ErrTimeout, err)
+	}
+	return _errors.Wrap(fallback, err)
+}
+
+// WrapNoRows wraps pg.ErrNoRows with the code of the missing resource, the other errors are unexpected.
+func WrapNoRows(ctx context.Context, err error, code string) error {
+	if err == pg.ErrNoRows {
+		return _errors.Wrap(code, err)
+	}
+	return WrapError(ctx, err, _errors.ErrInternalServerError)
+}
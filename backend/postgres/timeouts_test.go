@@ -0,0 +1,79 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	_errors "backend/errors"
+	"backend/postgres"
+	"backend/utils"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeouts(t *testing.T) {
+	timeouts := postgres.Timeouts{
+		Default: time.Second,
+		Operations: map[string]time.Duration{
+			"user.fetch": time.Minute,
+		},
+	}
+	require.Equal(t, time.Minute, timeouts.For("user.Fetch"))
+	require.Equal(t, time.Second, timeouts.For("user.GetByID"))
+
+	ctx, cancel := postgres.Timeouts{Default: time.Nanosecond}.WithTimeout(context.Background(), "user.Fetch")
+	defer cancel()
+	<-ctx.Done()
+	err := postgres.WrapError(ctx, errors.New("canceling statement due to user request"), _errors.ErrInternalServerError)
+	require.Equal(t, _errors.ErrTimeout, _errors.Code(_errors.ToGqlError(err)))
+
+	err = postgres.WrapError(context.Background(), errors.New("connection refused"), _errors.ErrInternalServerError)
+	require.Equal(t, _errors.ErrInternalServerError, _errors.Code(_errors.ToGqlError(err)))
+}
+
+func TestCanceledQuery(t *testing.T) {
+	conn := utils.ConnectToPostgreTestDB(false)
+	defer conn.Close()
+
+	t.Run("Canceled context aborts the query", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := postgres.Conn(ctx, conn).ExecContext(ctx, "SELECT pg_sleep(10)")
+		require.NotEqual(t, nil, err)
+		require.True(t, time.Since(start) < 5*time.Second)
+	})
+
+	t.Run("Exceeded timeout aborts the query", func(t *testing.T) {
+		ctx, cancel := postgres.Timeouts{Default: 100 * time.Millisecond}.WithTimeout(context.Background(), "test.sleep")
+		defer cancel()
+		start := time.Now()
+		_, err := postgres.Conn(ctx, conn).ExecContext(ctx, "SELECT pg_sleep(10)")
+		require.True(t, time.Since(start) < 5*time.Second)
+		err = postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+		require.Equal(t, _errors.ErrTimeout, _errors.Code(_errors.ToGqlError(err)))
+	})
+
+	t.Run("Exceeded timeout aborts COPY", func(t *testing.T) {
+		ctx, cancel := postgres.Timeouts{Default: 100 * time.Millisecond}.WithTimeout(context.Background(), "test.copy")
+		defer cancel()
+		start := time.Now()
+		err := postgres.WithStatementTimeout(ctx, conn, func(db postgres.DB) error {
+			_, err := db.CopyTo(ioutil.Discard, "COPY (SELECT pg_sleep(10)) TO STDOUT")
+			return err
+		})
+		require.True(t, time.Since(start) < 5*time.Second)
+		err = postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+		require.Equal(t, _errors.ErrTimeout, _errors.Code(_errors.ToGqlError(err)))
+
+		// the timeout doesn't outlive the transaction
+		var timeout string
+		_, err = conn.QueryOne(pg.Scan(&timeout), "SHOW statement_timeout")
+		require.Equal(t, nil, err)
+		require.Equal(t, "0", timeout)
+	})
+}
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-pg/pg/v9"
+)
+
+type txContextKey struct{}
+
+// txState is the ambient transaction, depth is the number of the savepoints it is nested in.
+type txState struct {
+	tx    *pg.Tx
+	depth int
+}
+
+// Transactor runs functions in a transaction carried by the context.
+type Transactor interface {
+	// WithinTx commits the transaction if fn returns nil, otherwise or when fn panics it is rolled back.
+	// The repositories built on DB join the transaction carried by the context passed to fn.
+	// If ctx already carries a transaction fn runs in a savepoint of it, so its error rolls back only
+	// the changes made by fn and the outer function decides whether to commit.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type transactor struct {
+	db DB
+}
+
+func NewTransactor(db DB) Transactor {
+	return &transactor{db}
+}
+
+func (t *transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return withinSavepoint(ctx, state, fn)
+	}
+	// pg.Tx.RunInTransaction rolls back and repanics when fn panics
+	return t.db.RunInTransaction(func(tx *pg.Tx) error {
+		return fn(context.WithValue(ctx, txContextKey{}, &txState{tx, 0}))
+	})
+}
+
+func withinSavepoint(ctx context.Context, state *txState, fn func(ctx context.Context) error) error {
+	nested := &txState{state.tx, state.depth + 1}
+	savepoint := pg.Ident(fmt.Sprintf("sp_%d", nested.depth))
+	if _, err := state.tx.ExecContext(ctx, "SAVEPOINT ?", savepoint); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = state.tx.Exec("ROLLBACK TO SAVEPOINT ?", savepoint)
+			panic(p)
+		}
+	}()
+	if err := fn(context.WithValue(ctx, txContextKey{}, nested)); err != nil {
+		// the outer transaction is aborted when the rollback fails, so it can't be committed anyway
+		_, _ = state.tx.Exec("ROLLBACK TO SAVEPOINT ?", savepoint)
+		return err
+	}
+	_, err := state.tx.ExecContext(ctx, "RELEASE SAVEPOINT ?", savepoint)
+	return err
+}
+
+// Conn returns the transaction carried by ctx or db if there is none, it is the primary of a Cluster.
+// The repositories run their writes on it to join the transactions started by WithinTx. Its statements
+// which succeed are recorded for the Writes carried by ctx, so the reads of the session are served
+// by the primary for a while after.
+func Conn(ctx context.Context, db DB) DB {
+	conn := TxConn(ctx, db)
+	if w, ok := ctx.Value(writesContextKey{}).(*Writes); ok {
+		return &writeConn{conn, w}
+	}
+	return conn
+}
+
+// TxConn is Conn which doesn't record the writes, for the statements which must run on the primary
+// or in the transaction but don't change the data, e.g. the locking reads and the notifications.
+func TxConn(ctx context.Context, db DB) DB {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return state.tx
+	}
+	return db
+}
+
+// InTx reports whether ctx carries a transaction started by WithinTx.
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txContextKey{}).(*txState)
+	return ok
+}
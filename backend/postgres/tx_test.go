@@ -0,0 +1,69 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"backend/postgres"
+	"backend/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithinTx(t *testing.T) {
+	conn := utils.ConnectToPostgreTestDB(false)
+	defer conn.Close()
+	// not a temporary table, the transactions can run on other connections of the pool
+	_, err := conn.Exec(`DROP TABLE IF EXISTS tx_test; CREATE TABLE tx_test (value int)`)
+	require.Equal(t, nil, err)
+	defer conn.Exec(`DROP TABLE tx_test`)
+	transactor := postgres.NewTransactor(conn)
+	insert := func(ctx context.Context, value int) {
+		_, err := postgres.Conn(ctx, conn).ExecContext(ctx, "INSERT INTO tx_test VALUES (?)", value)
+		require.Equal(t, nil, err)
+	}
+	values := func() []int {
+		values := []int{}
+		_, err := conn.Query(&values, "SELECT value FROM tx_test ORDER BY value")
+		require.Equal(t, nil, err)
+		return values
+	}
+	errRollback := errors.New("rollback")
+
+	t.Run("Rolled back on error", func(t *testing.T) {
+		err := transactor.WithinTx(context.Background(), func(ctx context.Context) error {
+			insert(ctx, 1)
+			return errRollback
+		})
+		require.Equal(t, errRollback, err)
+		require.Equal(t, []int{}, values())
+	})
+
+	t.Run("Rolled back on panic", func(t *testing.T) {
+		require.Panics(t, func() {
+			_ = transactor.WithinTx(context.Background(), func(ctx context.Context) error {
+				insert(ctx, 1)
+				panic("rollback")
+			})
+		})
+		require.Equal(t, []int{}, values())
+	})
+
+	t.Run("Nested error rolls back only the savepoint", func(t *testing.T) {
+		err := transactor.WithinTx(context.Background(), func(ctx context.Context) error {
+			insert(ctx, 1)
+			err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+				insert(ctx, 2)
+				return errRollback
+			})
+			require.Equal(t, errRollback, err)
+			return transactor.WithinTx(ctx, func(ctx context.Context) error {
+				insert(ctx, 3)
+				return nil
+			})
+		})
+		require.Equal(t, nil, err)
+		require.Equal(t, []int{1, 3}, values())
+	})
+}
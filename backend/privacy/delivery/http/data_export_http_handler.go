@@ -0,0 +1,55 @@
+package http
+
+import (
+	_errors "backend/errors"
+	"backend/privacy"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type Config struct {
+	PrivacyUcase privacy.Usecase
+}
+
+type handler struct {
+	privacyUcase privacy.Usecase
+	logrus       *logrus.Entry
+}
+
+// NewDataExportHandler registers the route which downloads the data exports, the token in the link authorizes it.
+func NewDataExportHandler(g *echo.Group, cfg Config) error {
+	if cfg.PrivacyUcase == nil {
+		return fmt.Errorf("PrivacyUcase cannot be nil")
+	}
+	h := &handler{cfg.PrivacyUcase, logrus.WithField("package", "privacy/delivery/http")}
+	g.GET("/exports/:token", h.download)
+	return nil
+}
+
+func (h *handler) download(c echo.Context) error {
+	content, object, err := h.privacyUcase.DownloadDataExport(c.Request().Context(), c.Param("token"))
+	if err != nil {
+		if _errors.Code(_errors.ToGqlError(err)) == _errors.ErrDataExportNotFound {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		h.logrus.Errorf("Cannot download data export: %s", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError)
+	}
+	defer content.Close()
+
+	header := c.Response().Header()
+	header.Set("Cache-Control", "private, no-store")
+	header.Set(echo.HeaderContentType, "application/zip")
+	header.Set(echo.HeaderContentDisposition, `attachment; filename="data-export.zip"`)
+	if object.Size >= 0 {
+		header.Set(echo.HeaderContentLength, strconv.FormatInt(object.Size, 10))
+	}
+	c.Response().WriteHeader(http.StatusOK)
+	_, err = io.Copy(c.Response(), content)
+	return err
+}
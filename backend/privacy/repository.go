@@ -0,0 +1,21 @@
+package privacy
+
+import (
+	"context"
+	"time"
+
+	"backend/models"
+)
+
+type Repository interface {
+	Store(ctx context.Context, e *models.DataExport) error
+	GetByToken(ctx context.Context, token string) (*models.DataExport, error)
+	// GetPending returns the pending export of the user or nil.
+	GetPending(ctx context.Context, userID int) (*models.DataExport, error)
+	// Claim returns a pending export, which is not being built, or nil, and hides it from other workers for the lease duration.
+	Claim(ctx context.Context, lease time.Duration) (*models.DataExport, error)
+	Update(ctx context.Context, e *models.DataExport) error
+	// DeleteExpired removes the exports which expired before t.
+	DeleteExpired(ctx context.Context, t time.Time) ([]*models.DataExport, error)
+	DeleteByUserID(ctx context.Context, userID int) error
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"backend/privacy"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	_errors "backend/errors"
+	"backend/models"
+	"backend/postgres"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+)
+
+const (
+	createIndex = `
+		CREATE INDEX IF NOT EXISTS data_exports_user_id_idx ON data_exports (user_id);
+	`
+	claim = `
+		UPDATE data_exports SET claimed_at = now(), attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM data_exports
+			WHERE status = ? AND claimed_at <= now() - ?::interval
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`
+)
+
+var constraintErrors = postgres.NewErrorRegistry().
+	Unique("data_exports_token_key", _errors.ErrDataExportTokenTaken, "").
+	NotNull("data_exports", "user_id", _errors.ErrUserNotFound, "")
+
+type postgreRepository struct {
+	postgres.DB
+	timeouts postgres.Timeouts
+	logrus   *logrus.Entry
+}
+
+func NewPostgrePrivacyRepository(conn postgres.DB, timeouts postgres.Timeouts) (privacy.Repository, error) {
+	log := logrus.WithField("package", "privacy/repository")
+	if err := conn.CreateTable((*models.DataExport)(nil), &orm.CreateTableOptions{
+		IfNotExists: true,
+	}); err != nil {
+		log.Debugf("Cannot create data exports table: %s", err.Error())
+		return nil, err
+	}
+	if _, err := conn.Exec(createIndex); err != nil {
+		log.Debugf("Cannot create data exports index: %s", err.Error())
+		return nil, err
+	}
+	return &postgreRepository{conn,
+		timeouts,
+		log,
+	}, nil
+}
+
+func (repo *postgreRepository) Store(ctx context.Context, e *models.DataExport) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "privacy.Store")
+	defer cancel()
+	log := repo.logrus.WithField("userID", e.UserID)
+	log.Debug("Store")
+	e.Status = models.DataExportStatusPending
+	if _, err := postgres.Conn(ctx, repo.DB).
+		ModelContext(ctx, e).
+		Returning("*").
+		Insert(); err != nil {
+		log.Debugf("Store err: %s", err.Error())
+		return constraintErrors.Translate(ctx, err, _errors.ErrInternalServerError)
+	}
+	return nil
+}
+
+func (repo *postgreRepository) GetByToken(ctx context.Context, token string) (*models.DataExport, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "privacy.GetByToken")
+	defer cancel()
+	e := &models.DataExport{}
+	log := repo.logrus.WithField("token", token)
+	log.Debug("GetByToken")
+	if err := postgres.ReadConn(ctx, repo.DB).ModelContext(ctx, e).Where("token = ?", token).Select(); err != nil {
+		log.Debugf("GetByToken err: %s", err.Error())
+		if err == pg.ErrNoRows {
+			return nil, _errors.Wrap(_errors.ErrDataExportNotFound, err)
+		}
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return e, nil
+}
+
+func (repo *postgreRepository) GetPending(ctx context.Context, userID int) (*models.DataExport, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "privacy.GetPending")
+	defer cancel()
+	e := &models.DataExport{}
+	log := repo.logrus.WithField("userID", userID)
+	log.Debug("GetPending")
+	if err := postgres.ReadConn(ctx, repo.DB).ModelContext(ctx, e).
+		Where("user_id = ?", userID).
+		Where("status = ?", models.DataExportStatusPending).
+		Limit(1).
+		Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, nil
+		}
+		log.Debugf("GetPending err: %s", err.Error())
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return e, nil
+}
+
+func (repo *postgreRepository) Claim(ctx context.Context, lease time.Duration) (*models.DataExport, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "privacy.Claim")
+	defer cancel()
+	exports := []*models.DataExport{}
+	if _, err := postgres.Conn(ctx, repo.DB).QueryContext(ctx,
+		&exports,
+		claim,
+		models.DataExportStatusPending,
+		fmt.Sprintf("%d milliseconds", lease.Milliseconds())); err != nil && err != pg.ErrNoRows {
+		repo.logrus.Debugf("Claim err: %s", err.Error())
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	if len(exports) == 0 {
+		return nil, nil
+	}
+	return exports[0], nil
+}
+
+func (repo *postgreRepository) Update(ctx context.Context, e *models.DataExport) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "privacy.Update")
+	defer cancel()
+	log := repo.logrus.WithField("id", e.ID).WithField("status", e.Status)
+	log.Debug("Update")
+	if _, err := postgres.Conn(ctx, repo.DB).
+		ModelContext(ctx, e).
+		WherePK().
+		Returning("*").
+		Update(); err != nil {
+		log.Debugf("Update err: %s", err.Error())
+		if err == pg.ErrNoRows {
+			return _errors.Wrap(_errors.ErrDataExportNotFound, err)
+		}
+		return constraintErrors.Translate(ctx, err, _errors.ErrInternalServerError)
+	}
+	return nil
+}
+
+func (repo *postgreRepository) DeleteExpired(ctx context.Context, t time.Time) ([]*models.DataExport, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "privacy.DeleteExpired")
+	defer cancel()
+	exports := []*models.DataExport{}
+	log := repo.logrus.WithField("time", t)
+	log.Debug("DeleteExpired")
+	if _, err := postgres.Conn(ctx, repo.DB).ModelContext(ctx, &exports).
+		Where("expires_at < ?", t).
+		Returning("*").
+		Delete(); err != nil && err != pg.ErrNoRows {
+		log.Debugf("DeleteExpired err: %s", err.Error())
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return exports, nil
+}
+
+func (repo *postgreRepository) DeleteByUserID(ctx context.Context, userID int) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "privacy.DeleteByUserID")
+	defer cancel()
+	log := repo.logrus.WithField("userID", userID)
+	log.Debug("DeleteByUserID")
+	if _, err := postgres.Conn(ctx, repo.DB).
+		ModelContext(ctx, (*models.DataExport)(nil)).
+		Where("user_id = ?", userID).
+		Delete(); err != nil && err != pg.ErrNoRows {
+		log.Debugf("DeleteByUserID err: %s", err.Error())
+		return postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	_errors "backend/errors"
+	"backend/models"
+	"backend/postgres"
+	"backend/utils"
+	"backend/utils/testutil"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPgRepositoryConstraints raises every registered violation, so the names of the constraints
+// are checked against the schema.
+func TestPgRepositoryConstraints(t *testing.T) {
+	conn := utils.ConnectToPostgreTestDB(false)
+	defer conn.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		export     *models.DataExport
+		constraint string
+		code       string
+	}{
+		{"token", &models.DataExport{UserID: 1, Token: "constraint"}, "data_exports_token_key", _errors.ErrDataExportTokenTaken},
+		{"user", &models.DataExport{Token: "other"}, "data_exports.user_id", _errors.ErrUserNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the failed statement aborts the transaction, every violation has its own
+			tx, err := conn.Begin()
+			require.Equal(t, nil, err)
+			defer tx.Rollback()
+			repo, err := NewPostgrePrivacyRepository(tx, postgres.Timeouts{})
+			require.Equal(t, nil, err)
+			require.Equal(t, nil, repo.Store(ctx, &models.DataExport{UserID: 1, Token: "constraint"}))
+
+			err = repo.Store(ctx, test.export)
+			require.Equal(t, test.constraint, testutil.Violation(err))
+			require.Equal(t, test.code, _errors.Code(_errors.ToGqlError(err)))
+		})
+	}
+}
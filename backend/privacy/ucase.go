@@ -0,0 +1,23 @@
+package privacy
+
+import (
+	"context"
+	"io"
+
+	"backend/models"
+	"backend/storage"
+)
+
+type Usecase interface {
+	// RequestDataExport schedules building the archive, the download link is sent by email.
+	RequestDataExport(ctx context.Context, userID int) (*models.DataExport, error)
+	BuildPendingDataExports(ctx context.Context) error
+	// DownloadDataExport returns the archive, the caller must close it.
+	DownloadDataExport(ctx context.Context, token string) (io.ReadCloser, *storage.Object, error)
+	DeleteExpiredDataExports(ctx context.Context) error
+	// RequestAccountDeletion schedules the deletion after the grace period, the cancel link is sent by email.
+	RequestAccountDeletion(ctx context.Context, userID int, password string) (*models.User, error)
+	CancelAccountDeletion(ctx context.Context, id int, token string) (*models.User, error)
+	// PurgeDeletedAccounts deletes the accounts whose grace period is over.
+	PurgeDeletedAccounts(ctx context.Context) ([]*models.User, error)
+}
@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"time"
+
+	"backend/models"
+)
+
+// The archive contains all personal data which is stored about the user. The sessions
+// are kept only in the signed cookies, so there is nothing to export about them.
+const (
+	profileFile = "profile.json"
+	tokensFile  = "tokens.json"
+	emailsFile  = "emails.json"
+	avatarFile  = "avatar.jpg"
+)
+
+type profile struct {
+	ID                  int        `json:"id"`
+	Slug                string     `json:"slug"`
+	Login               string     `json:"login"`
+	Email               string     `json:"email"`
+	Role                int        `json:"role"`
+	Activated           bool       `json:"activated"`
+	DisplayName         string     `json:"displayName"`
+	Bio                 string     `json:"bio"`
+	Locale              string     `json:"locale"`
+	Timezone            string     `json:"timezone"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt"`
+}
+
+// token describes a token without its value, the archive is sent by email
+// and the tokens still may be valid.
+type token struct {
+	Type        string    `json:"type"`
+	GeneratedAt time.Time `json:"generatedAt"`
+}
+
+type archive struct {
+	user   *models.User
+	emails []*models.Email
+	avatar []byte
+}
+
+func (a *archive) writeTo(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	activated := a.user.Activated != nil && *a.user.Activated
+	if err := writeJSON(zw, profileFile, profile{
+		ID:                  a.user.ID,
+		Slug:                a.user.Slug,
+		Login:               a.user.Login,
+		Email:               a.user.Email,
+		Role:                a.user.Role,
+		Activated:           activated,
+		DisplayName:         a.user.DisplayName,
+		Bio:                 a.user.Bio,
+		Locale:              a.user.Locale,
+		Timezone:            a.user.Timezone,
+		CreatedAt:           a.user.CreatedAt,
+		UpdatedAt:           a.user.UpdatedAt,
+		DeletionScheduledAt: a.user.DeletionScheduledAt,
+	}); err != nil {
+		return err
+	}
+	tokens := []token{}
+	if a.user.ActivationToken != "" && !activated {
+		tokens = append(tokens, token{"activation", a.user.ActivationTokenGeneratedAt})
+	}
+	if a.user.ResetPasswordToken != "" {
+		tokens = append(tokens, token{"resetPassword", a.user.ResetPasswordTokenGeneratedAt})
+	}
+	if err := writeJSON(zw, tokensFile, tokens); err != nil {
+		return err
+	}
+	emails := a.emails
+	if emails == nil {
+		emails = []*models.Email{}
+	}
+	if err := writeJSON(zw, emailsFile, emails); err != nil {
+		return err
+	}
+	if len(a.avatar) > 0 {
+		f, err := zw.Create(avatarFile)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(a.avatar); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
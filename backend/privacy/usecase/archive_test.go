@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"backend/models"
+	"backend/utils/seed"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive(t *testing.T) {
+	u := seed.Users(1)[0]
+	u.ID = 1
+	u.ActivationToken = "activation-token"
+	u.ResetPasswordToken = "reset-password-token"
+	a := &archive{
+		user:   &u,
+		emails: []*models.Email{{To: u.Email, Template: "activation", Data: map[string]interface{}{"Href": "secret"}}},
+		avatar: []byte("jpeg"),
+	}
+	var buf bytes.Buffer
+	require.Equal(t, nil, a.writeTo(&buf))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.Equal(t, nil, err)
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.Equal(t, nil, err)
+		content, _ := ioutil.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(content)
+	}
+	require.Equal(t, 4, len(files))
+	require.Equal(t, true, strings.Contains(files[profileFile], `"login": "`+u.Login+`"`))
+	require.Equal(t, true, strings.Contains(files[tokensFile], `"type": "resetPassword"`))
+	require.Equal(t, true, strings.Contains(files[emailsFile], `"template": "activation"`))
+	require.Equal(t, "jpeg", files[avatarFile])
+	for name, content := range files {
+		for _, secret := range []string{u.Password, u.ActivationToken, u.ResetPasswordToken, "secret"} {
+			require.Equal(t, false, strings.Contains(content, secret), name)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package usecase
+
+import (
+	"backend/avatar"
+	_errors "backend/errors"
+	"backend/middleware"
+	"backend/models"
+	"backend/outbox"
+	"backend/postgres"
+	"backend/privacy"
+	"backend/storage"
+	"backend/user"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultExportExpiresIn     = 48 * time.Hour
+	DefaultDeletionGracePeriod = 30 * 24 * time.Hour
+	// exportLease is the time after which an export which wasn't built is claimed again
+	exportLease = 10 * time.Minute
+	// maxExportAttempts is the number of failed attempts after which the export is marked as failed
+	maxExportAttempts = 3
+	linkTimeFormat    = "2006-01-02 15:04 MST"
+)
+
+type Config struct {
+	UserRepo    user.Repository
+	UserEvents  user.Events
+	PrivacyRepo privacy.Repository
+	OutboxRepo  outbox.Repository
+	// Transactor makes the updates and the enqueued emails atomic, it is optional.
+	Transactor postgres.Transactor
+	Storage    storage.Storage
+	// URL is the public URL of the backend, the download links start with it.
+	URL                 string
+	FrontendURL         string
+	ExportExpiresIn     time.Duration
+	DeletionGracePeriod time.Duration
+}
+
+type usecase struct {
+	userRepo            user.Repository
+	userEvents          user.Events
+	privacyRepo         privacy.Repository
+	outboxRepo          outbox.Repository
+	transactor          postgres.Transactor
+	storage             storage.Storage
+	url                 string
+	frontendURL         string
+	exportExpiresIn     time.Duration
+	deletionGracePeriod time.Duration
+	logrus              *logrus.Entry
+}
+
+func NewPrivacyUsecase(cfg Config) privacy.Usecase {
+	if cfg.ExportExpiresIn <= 0 {
+		cfg.ExportExpiresIn = DefaultExportExpiresIn
+	}
+	if cfg.DeletionGracePeriod <= 0 {
+		cfg.DeletionGracePeriod = DefaultDeletionGracePeriod
+	}
+	return &usecase{
+		cfg.UserRepo,
+		cfg.UserEvents,
+		cfg.PrivacyRepo,
+		cfg.OutboxRepo,
+		cfg.Transactor,
+		cfg.Storage,
+		cfg.URL,
+		cfg.FrontendURL,
+		cfg.ExportExpiresIn,
+		cfg.DeletionGracePeriod,
+		logrus.WithField("package", "privacy/usecase"),
+	}
+}
+
+func (ucase *usecase) RequestDataExport(ctx context.Context, userID int) (*models.DataExport, error) {
+	entry := ucase.logrus.WithField("userID", userID)
+	entry.Debug("RequestDataExport")
+	pending, err := ucase.privacyRepo.GetPending(ctx, userID)
+	if err != nil {
+		return nil, err
+	} else if pending != nil {
+		entry.Debug("RequestDataExport - The export is pending.")
+		return pending, nil
+	}
+	e := &models.DataExport{
+		UserID: userID,
+		Token:  uuid.New().String(),
+	}
+	if err := ucase.privacyRepo.Store(ctx, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (ucase *usecase) BuildPendingDataExports(ctx context.Context) error {
+	for {
+		e, err := ucase.privacyRepo.Claim(ctx, exportLease)
+		if err != nil {
+			return err
+		} else if e == nil {
+			return nil
+		}
+		entry := ucase.logrus.WithField("id", e.ID).WithField("userID", e.UserID)
+		if err := ucase.buildDataExport(ctx, e); err != nil {
+			entry.Errorf("Cannot build data export (attempt %d): %s", e.Attempts, err.Error())
+			if e.Attempts >= maxExportAttempts {
+				e.Status = models.DataExportStatusFailed
+				if err := ucase.privacyRepo.Update(ctx, e); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (ucase *usecase) buildDataExport(ctx context.Context, e *models.DataExport) error {
+	u, err := ucase.userRepo.GetByID(ctx, e.UserID)
+	if err != nil {
+		return err
+	}
+	a := &archive{user: u}
+	if ucase.outboxRepo != nil {
+		emails, err := ucase.outboxRepo.Fetch(ctx, &models.EmailFilter{To: u.Email})
+		if err != nil {
+			return err
+		}
+		a.emails = emails.Items
+	}
+	if u.Avatar != "" {
+		r, _, err := ucase.storage.Get(ctx, avatar.Key(u.Avatar, avatar.Sizes[len(avatar.Sizes)-1]))
+		if err != nil && err != storage.ErrNotFound {
+			return err
+		} else if err == nil {
+			a.avatar, err = ioutil.ReadAll(r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := a.writeTo(&buf); err != nil {
+		return err
+	}
+	if err := ucase.storage.Put(ctx, exportKey(e), &buf, int64(buf.Len()), "application/zip"); err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(ucase.exportExpiresIn)
+	e.Status = models.DataExportStatusReady
+	e.ExpiresAt = &expiresAt
+	return ucase.withinTx(ctx, func(ctx context.Context) error {
+		if err := ucase.privacyRepo.Update(ctx, e); err != nil {
+			return err
+		}
+		return ucase.enqueueEmail(ctx, u, outbox.DataExportTemplate, map[string]interface{}{
+			"Href":      fmt.Sprintf("%s/exports/%s", ucase.url, e.Token),
+			"ExpiresAt": expiresAt.UTC().Format(linkTimeFormat),
+		})
+	})
+}
+
+func (ucase *usecase) DownloadDataExport(ctx context.Context, token string) (io.ReadCloser, *storage.Object, error) {
+	entry := ucase.logrus.WithField("token", token)
+	entry.Debug("DownloadDataExport")
+	e, err := ucase.privacyRepo.GetByToken(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e.Status != models.DataExportStatusReady || e.ExpiresAt == nil || e.ExpiresAt.Before(time.Now()) {
+		entry.Debug("DownloadDataExport - The export isn't ready or has expired.")
+		return nil, nil, _errors.Wrap(_errors.ErrDataExportNotFound)
+	}
+	r, object, err := ucase.storage.Get(ctx, exportKey(e))
+	if err == storage.ErrNotFound {
+		return nil, nil, _errors.Wrap(_errors.ErrDataExportNotFound, err)
+	} else if err != nil {
+		return nil, nil, _errors.Wrap(_errors.ErrInternalServerError, err)
+	}
+	return r, object, nil
+}
+
+func (ucase *usecase) DeleteExpiredDataExports(ctx context.Context) error {
+	exports, err := ucase.privacyRepo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, e := range exports {
+		if err := ucase.storage.Delete(ctx, exportKey(e)); err != nil {
+			ucase.logrus.WithField("id", e.ID).Errorf("Cannot delete data export: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+func (ucase *usecase) RequestAccountDeletion(ctx context.Context, userID int, password string) (*models.User, error) {
+	entry := ucase.logrus.WithField("userID", userID)
+	entry.Debug("RequestAccountDeletion")
+	u, err := ucase.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	} else if u.DeletionScheduledAt != nil {
+		entry.Debug("RequestAccountDeletion - The deletion is scheduled.")
+		return nil, _errors.Wrap(_errors.ErrAccountDeletionScheduled)
+	}
+	if err := u.CompareHashAndPassword(password); err != nil {
+		entry.Debug("RequestAccountDeletion - Wrong password.")
+		return nil, err
+	}
+	scheduledAt := time.Now().Add(ucase.deletionGracePeriod)
+	u.DeletionScheduledAt = &scheduledAt
+	u.DeletionToken = uuid.New().String()
+	if err := ucase.withinTx(ctx, func(ctx context.Context) error {
+		if err := ucase.userRepo.UpdateColumns(ctx, u, "deletion_scheduled_at", "deletion_token"); err != nil {
+			return err
+		}
+		return ucase.enqueueEmail(ctx, u, outbox.AccountDeletionTemplate, map[string]interface{}{
+			"Href":        fmt.Sprintf("%s/%d/cancel-deletion/%s", ucase.frontendURL, u.ID, u.DeletionToken),
+			"ScheduledAt": scheduledAt.UTC().Format(linkTimeFormat),
+		})
+	}); err != nil {
+		return nil, err
+	}
+	ucase.publishUpdated(ctx, u)
+	return u, nil
+}
+
+func (ucase *usecase) CancelAccountDeletion(ctx context.Context, id int, token string) (*models.User, error) {
+	entry := ucase.logrus.WithField("id", id).WithField("token", token)
+	entry.Debug("CancelAccountDeletion")
+	u, err := ucase.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if u.DeletionScheduledAt == nil {
+		entry.Debug("CancelAccountDeletion - The deletion isn't scheduled.")
+		return nil, _errors.Wrap(_errors.ErrAccountDeletionNotScheduled)
+	} else if subtle.ConstantTimeCompare([]byte(u.DeletionToken), []byte(token)) != 1 {
+		entry.Debug("CancelAccountDeletion - Wrong deletion token.")
+		return nil, _errors.Wrap(_errors.ErrWrongDeletionToken)
+	} else if u.DeletionScheduledAt.Before(time.Now()) {
+		// the account waits for the purge
+		entry.Debug("CancelAccountDeletion - The grace period is over.")
+		return nil, _errors.Wrap(_errors.ErrAccountDeletionGracePeriodOver)
+	}
+	u.DeletionScheduledAt = nil
+	u.DeletionToken = ""
+	if err := ucase.userRepo.UpdateColumns(ctx, u, "deletion_scheduled_at", "deletion_token"); err != nil {
+		return nil, err
+	}
+	ucase.publishUpdated(ctx, u)
+	return u, nil
+}
+
+func (ucase *usecase) PurgeDeletedAccounts(ctx context.Context) ([]*models.User, error) {
+	users, err := ucase.userRepo.Delete(ctx, &models.UserFilter{
+		DeletionScheduledAtLT: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		entry := ucase.logrus.WithField("id", u.ID)
+		entry.Info("The account has been deleted")
+		// the files and emails are removed after the user, so a failure leaves no account behind
+		if err := ucase.storage.Delete(ctx, fmt.Sprintf("avatars/%d", u.ID)); err != nil {
+			entry.Errorf("Cannot delete avatars: %s", err.Error())
+		}
+		if err := ucase.storage.Delete(ctx, fmt.Sprintf("exports/%d", u.ID)); err != nil {
+			entry.Errorf("Cannot delete data exports: %s", err.Error())
+		}
+		if err := ucase.privacyRepo.DeleteByUserID(ctx, u.ID); err != nil {
+			entry.Errorf("Cannot delete data exports: %s", err.Error())
+		}
+		if ucase.outboxRepo != nil {
+			if err := ucase.outboxRepo.DeleteByRecipient(ctx, u.Email); err != nil {
+				entry.Errorf("Cannot delete emails: %s", err.Error())
+			}
+		}
+		if ucase.userEvents != nil {
+			if err := ucase.userEvents.PublishAccountChanged(ctx, &models.AccountEvent{
+				Type: models.AccountEventTypeLoggedOut,
+				User: u,
+			}); err != nil {
+				entry.Debugf("Cannot publish account changed event: %s", err.Error())
+			}
+		}
+	}
+	return users, nil
+}
+
+func exportKey(e *models.DataExport) string {
+	return fmt.Sprintf("exports/%d/%s.zip", e.UserID, e.Token)
+}
+
+func (ucase *usecase) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ucase.transactor == nil {
+		return fn(ctx)
+	}
+	return ucase.transactor.WithinTx(ctx, fn)
+}
+
+// enqueueEmail adds the email to the outbox, it is localized to the locale of the user
+// or, if it isn't set, to the language of the request.
+func (ucase *usecase) enqueueEmail(ctx context.Context, u *models.User, template string, data map[string]interface{}) error {
+	if ucase.outboxRepo == nil {
+		return nil
+	}
+	data["Login"] = u.Login
+	lang := u.Locale
+	if lang == "" {
+		lang = middleware.LanguageFromContext(ctx)
+	}
+	return ucase.outboxRepo.Enqueue(ctx, &models.Email{
+		To:       u.Email,
+		Template: template,
+		Language: lang,
+		Data:     data,
+	})
+}
+
+func (ucase *usecase) publishUpdated(ctx context.Context, u *models.User) {
+	if ucase.userEvents == nil {
+		return
+	}
+	if err := ucase.userEvents.PublishUserUpdated(ctx, u); err != nil {
+		ucase.logrus.WithField("id", u.ID).Debugf("Cannot publish user updated event: %s", err.Error())
+	}
+}
@@ -0,0 +1,158 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	_errors "backend/errors"
+	"backend/models"
+	"backend/outbox"
+	_outboxRepository "backend/outbox/repository"
+	"backend/privacy"
+	"backend/storage"
+	"backend/user/repository"
+	"backend/utils/testutil"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memoryPrivacyRepo records the users whose exports are deleted, the other methods aren't used by the deletion.
+type memoryPrivacyRepo struct {
+	privacy.Repository
+	deleted []int
+}
+
+func (r *memoryPrivacyRepo) DeleteByUserID(ctx context.Context, userID int) error {
+	r.deleted = append(r.deleted, userID)
+	return nil
+}
+
+func TestAccountDeletion(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "storage")
+	require.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+	store, err := storage.NewLocalStorage(dir)
+	require.Equal(t, nil, err)
+
+	newUcase := func(t *testing.T) (*usecase, *memoryPrivacyRepo, *models.User) {
+		userRepo := repository.NewMemoryUserRepository()
+		u := &models.User{Login: "john", Password: "Password123", Email: "john@example.com", Role: 1}
+		require.Equal(t, nil, userRepo.Store(ctx, u))
+		privacyRepo := &memoryPrivacyRepo{}
+		return NewPrivacyUsecase(Config{
+			UserRepo:            userRepo,
+			PrivacyRepo:         privacyRepo,
+			OutboxRepo:          _outboxRepository.NewMemoryOutboxRepository(),
+			Storage:             store,
+			FrontendURL:         "http://frontend",
+			DeletionGracePeriod: time.Hour,
+		}).(*usecase), privacyRepo, u
+	}
+	// scheduleAt moves the deletion of the user, which is requested, to t
+	scheduleAt := func(t *testing.T, ucase *usecase, id int, at time.Time) {
+		require.Equal(t, nil, ucase.userRepo.UpdateColumns(ctx, &models.User{ID: id, DeletionScheduledAt: &at}, "deletion_scheduled_at"))
+	}
+
+	t.Run("RequestAccountDeletion", func(t *testing.T) {
+		ucase, _, u := newUcase(t)
+		_, err := ucase.RequestAccountDeletion(ctx, u.ID, "wrong")
+		require.Equal(t, _errors.ErrInvalidCredentials, testutil.ErrorCode(err))
+
+		requested, err := ucase.RequestAccountDeletion(ctx, u.ID, "Password123")
+		require.Equal(t, nil, err)
+		require.NotEqual(t, "", requested.DeletionToken)
+		require.WithinDuration(t, time.Now().Add(time.Hour), *requested.DeletionScheduledAt, time.Minute)
+		stored, err := ucase.userRepo.GetByID(ctx, u.ID)
+		require.Equal(t, nil, err)
+		require.Equal(t, requested.DeletionToken, stored.DeletionToken)
+
+		emails, err := ucase.outboxRepo.Fetch(ctx, &models.EmailFilter{To: u.Email})
+		require.Equal(t, nil, err)
+		require.Equal(t, 1, emails.Total)
+		e, err := ucase.outboxRepo.GetByID(ctx, emails.Items[0].ID)
+		require.Equal(t, nil, err)
+		require.Equal(t, outbox.AccountDeletionTemplate, e.Template)
+		require.Equal(t, fmt.Sprintf("http://frontend/%d/cancel-deletion/%s", u.ID, requested.DeletionToken), e.Data["Href"])
+
+		_, err = ucase.RequestAccountDeletion(ctx, u.ID, "Password123")
+		require.Equal(t, _errors.ErrAccountDeletionScheduled, testutil.ErrorCode(err))
+	})
+
+	t.Run("CancelAccountDeletion", func(t *testing.T) {
+		ucase, _, u := newUcase(t)
+		_, err := ucase.CancelAccountDeletion(ctx, u.ID, "")
+		require.Equal(t, _errors.ErrAccountDeletionNotScheduled, testutil.ErrorCode(err))
+		requested, err := ucase.RequestAccountDeletion(ctx, u.ID, "Password123")
+		require.Equal(t, nil, err)
+
+		_, err = ucase.CancelAccountDeletion(ctx, u.ID, "wrong")
+		require.Equal(t, _errors.ErrWrongDeletionToken, testutil.ErrorCode(err))
+		_, err = ucase.CancelAccountDeletion(ctx, u.ID, "")
+		require.Equal(t, _errors.ErrWrongDeletionToken, testutil.ErrorCode(err))
+
+		cancelled, err := ucase.CancelAccountDeletion(ctx, u.ID, requested.DeletionToken)
+		require.Equal(t, nil, err)
+		require.Equal(t, true, cancelled.DeletionScheduledAt == nil)
+		stored, err := ucase.userRepo.GetByID(ctx, u.ID)
+		require.Equal(t, nil, err)
+		require.Equal(t, true, stored.DeletionScheduledAt == nil)
+		require.Equal(t, "", stored.DeletionToken)
+
+		// the token can be used once
+		_, err = ucase.CancelAccountDeletion(ctx, u.ID, requested.DeletionToken)
+		require.Equal(t, _errors.ErrAccountDeletionNotScheduled, testutil.ErrorCode(err))
+	})
+
+	t.Run("CancelAccountDeletion after the grace period", func(t *testing.T) {
+		ucase, _, u := newUcase(t)
+		requested, err := ucase.RequestAccountDeletion(ctx, u.ID, "Password123")
+		require.Equal(t, nil, err)
+		scheduleAt(t, ucase, u.ID, time.Now().Add(-time.Minute))
+
+		_, err = ucase.CancelAccountDeletion(ctx, u.ID, requested.DeletionToken)
+		require.Equal(t, _errors.ErrAccountDeletionGracePeriodOver, testutil.ErrorCode(err))
+		stored, err := ucase.userRepo.GetByID(ctx, u.ID)
+		require.Equal(t, nil, err)
+		require.NotEqual(t, true, stored.DeletionScheduledAt == nil)
+	})
+
+	t.Run("PurgeDeletedAccounts", func(t *testing.T) {
+		ucase, privacyRepo, u := newUcase(t)
+		pending := &models.User{Login: "jane", Password: "Password123", Email: "jane@example.com", Role: 1}
+		kept := &models.User{Login: "bob", Password: "Password123", Email: "bob@example.com", Role: 1}
+		require.Equal(t, nil, ucase.userRepo.Store(ctx, pending))
+		require.Equal(t, nil, ucase.userRepo.Store(ctx, kept))
+		for _, id := range []int{u.ID, pending.ID} {
+			_, err := ucase.RequestAccountDeletion(ctx, id, "Password123")
+			require.Equal(t, nil, err)
+		}
+		scheduleAt(t, ucase, u.ID, time.Now().Add(-time.Minute))
+
+		purged, err := ucase.PurgeDeletedAccounts(ctx)
+		require.Equal(t, nil, err)
+		require.Equal(t, 1, len(purged))
+		require.Equal(t, u.ID, purged[0].ID)
+		_, err = ucase.userRepo.GetByID(ctx, u.ID)
+		require.Equal(t, _errors.ErrUserNotFound, testutil.ErrorCode(err))
+		for _, id := range []int{pending.ID, kept.ID} {
+			_, err = ucase.userRepo.GetByID(ctx, id)
+			require.Equal(t, nil, err)
+		}
+		require.Equal(t, []int{u.ID}, privacyRepo.deleted)
+		emails, err := ucase.outboxRepo.Fetch(ctx, &models.EmailFilter{To: u.Email})
+		require.Equal(t, nil, err)
+		require.Equal(t, 0, emails.Total)
+		emails, err = ucase.outboxRepo.Fetch(ctx, &models.EmailFilter{To: pending.Email})
+		require.Equal(t, nil, err)
+		require.Equal(t, 1, emails.Total)
+
+		purged, err = ucase.PurgeDeletedAccounts(ctx)
+		require.Equal(t, nil, err)
+		require.Equal(t, 0, len(purged))
+	})
+}
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"backend/privacy"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultInterval = 10 * time.Second
+)
+
+type Config struct {
+	PrivacyUcase privacy.Usecase
+	// Interval between the runs, every run builds the pending exports, deletes the expired ones
+	// and purges the accounts whose grace period is over.
+	Interval time.Duration
+}
+
+// Worker runs the privacy tasks in the background.
+type Worker struct {
+	cfg    Config
+	logrus *logrus.Entry
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(cfg Config) *Worker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	return &Worker{
+		cfg:    cfg,
+		logrus: logrus.WithField("package", "privacy/worker"),
+	}
+}
+
+func (w *Worker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			w.run(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Shutdown cancels the current run and waits until it returns or ctx is done.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	w.cancel()
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Worker) run(ctx context.Context) {
+	if err := w.cfg.PrivacyUcase.BuildPendingDataExports(ctx); err != nil {
+		w.logrus.Errorf("Cannot build data exports: %s", err.Error())
+	}
+	if err := w.cfg.PrivacyUcase.DeleteExpiredDataExports(ctx); err != nil {
+		w.logrus.Errorf("Cannot delete expired data exports: %s", err.Error())
+	}
+	if _, err := w.cfg.PrivacyUcase.PurgeDeletedAccounts(ctx); err != nil {
+		w.logrus.Errorf("Cannot purge deleted accounts: %s", err.Error())
+	}
+}
@@ -0,0 +1,81 @@
+package pubsub
+
+import (
+	"backend/postgres"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// maxNotifyPayloadSize is the limit of a NOTIFY payload in the default Postgres configuration.
+const maxNotifyPayloadSize = 8000
+
+type envelope struct {
+	Topic   string `json:"topic"`
+	Payload []byte `json:"payload"`
+}
+
+// postgrePubSub delivers messages to local subscribers through Postgres LISTEN/NOTIFY,
+// so every replica connected to the same database receives them.
+type postgrePubSub struct {
+	db       *pg.DB
+	channel  string
+	local    PubSub
+	listener *pg.Listener
+	logrus   *logrus.Entry
+}
+
+func NewPostgrePubSub(db *pg.DB, channel string) (PubSub, error) {
+	// db.Listen ignores the errors of LISTEN, the channel is subscribed to once by the listener
+	listener := db.Listen()
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	ps := &postgrePubSub{
+		db:       db,
+		channel:  channel,
+		local:    NewInMemoryPubSub(),
+		listener: listener,
+		logrus:   logrus.WithField("package", "pubsub").WithField("channel", channel),
+	}
+	go ps.listen()
+	return ps, nil
+}
+
+func (ps *postgrePubSub) listen() {
+	for notification := range ps.listener.Channel() {
+		e := envelope{}
+		if err := json.Unmarshal([]byte(notification.Payload), &e); err != nil {
+			ps.logrus.Debugf("listen - Cannot decode notification: %s", err.Error())
+			continue
+		}
+		ps.local.Publish(context.Background(), e.Topic, e.Payload)
+	}
+}
+
+func (ps *postgrePubSub) Publish(ctx context.Context, topic string, payload []byte) error {
+	b, err := json.Marshal(envelope{topic, payload})
+	if err != nil {
+		return err
+	}
+	if len(b) > maxNotifyPayloadSize {
+		return fmt.Errorf("pubsub: payload for topic %s is too large (%d bytes)", topic, len(b))
+	}
+	// the notification joins the transaction carried by ctx, so it is delivered only if the transaction is committed
+	_, err = postgres.TxConn(ctx, ps.db).ExecContext(ctx, "SELECT pg_notify(?, ?)", ps.channel, string(b))
+	return err
+}
+
+func (ps *postgrePubSub) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	return ps.local.Subscribe(ctx, topic)
+}
+
+func (ps *postgrePubSub) Close() error {
+	err := ps.listener.Close()
+	ps.local.Close()
+	return err
+}
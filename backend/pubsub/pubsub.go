@@ -0,0 +1,90 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const subscriberBufferSize = 16
+
+type PubSub interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	Subscribe(ctx context.Context, topic string) (<-chan []byte, error)
+	Close() error
+}
+
+type inMemory struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan []byte]struct{}
+	closed      bool
+	logrus      *logrus.Entry
+}
+
+func NewInMemoryPubSub() PubSub {
+	return &inMemory{
+		subscribers: make(map[string]map[chan []byte]struct{}),
+		logrus:      logrus.WithField("package", "pubsub"),
+	}
+}
+
+func (ps *inMemory) Publish(ctx context.Context, topic string, payload []byte) error {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	for ch := range ps.subscribers[topic] {
+		select {
+		case ch <- payload:
+		default:
+			ps.logrus.WithField("topic", topic).Debug("Publish - subscriber is too slow, message dropped")
+		}
+	}
+	return nil
+}
+
+func (ps *inMemory) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	ch := make(chan []byte, subscriberBufferSize)
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.closed {
+		close(ch)
+		return ch, nil
+	}
+	if _, ok := ps.subscribers[topic]; !ok {
+		ps.subscribers[topic] = make(map[chan []byte]struct{})
+	}
+	ps.subscribers[topic][ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		ps.unsubscribe(topic, ch)
+	}()
+
+	return ch, nil
+}
+
+func (ps *inMemory) unsubscribe(topic string, ch chan []byte) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if _, ok := ps.subscribers[topic][ch]; !ok {
+		return
+	}
+	delete(ps.subscribers[topic], ch)
+	if len(ps.subscribers[topic]) == 0 {
+		delete(ps.subscribers, topic)
+	}
+	close(ch)
+}
+
+func (ps *inMemory) Close() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for topic, subscribers := range ps.subscribers {
+		for ch := range subscribers {
+			close(ch)
+		}
+		delete(ps.subscribers, topic)
+	}
+	ps.closed = true
+	return nil
+}
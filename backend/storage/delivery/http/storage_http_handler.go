@@ -0,0 +1,107 @@
+package http
+
+import (
+	"backend/storage"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultCacheControl suits objects whose keys change with their content, e.g. avatars.
+const DefaultCacheControl = "public, max-age=31536000, immutable"
+
+type Config struct {
+	Storage storage.Storage
+	// Path is the route prefix, e.g. /uploads serves the key avatars/1/x/32.jpg at /uploads/avatars/1/x/32.jpg.
+	Path string
+	// Prefixes limit the served keys, e.g. avatars/, empty means all keys are public.
+	Prefixes     []string
+	CacheControl string
+}
+
+type handler struct {
+	storage      storage.Storage
+	prefixes     []string
+	cacheControl string
+	logrus       *logrus.Entry
+}
+
+// NewStorageHandler registers the route which serves the objects from the storage.
+func NewStorageHandler(g *echo.Group, cfg Config) error {
+	if cfg.Storage == nil {
+		return fmt.Errorf("Storage cannot be nil")
+	}
+	if cfg.CacheControl == "" {
+		cfg.CacheControl = DefaultCacheControl
+	}
+	h := &handler{cfg.Storage, cfg.Prefixes, cfg.CacheControl, logrus.WithField("package", "storage/delivery/http")}
+	g.GET(strings.TrimSuffix(cfg.Path, "/")+"/*", h.get)
+	return nil
+}
+
+func (h *handler) get(c echo.Context) error {
+	key := c.Param("*")
+	if !storage.ValidKey(key) || !h.public(key) {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	content, object, err := h.storage.Get(c.Request().Context(), key)
+	if err == storage.ErrNotFound {
+		return echo.NewHTTPError(http.StatusNotFound)
+	} else if err != nil {
+		h.logrus.WithField("key", key).Errorf("Cannot get object: %s", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError)
+	}
+	defer content.Close()
+
+	header := c.Response().Header()
+	etag := fmt.Sprintf(`"%x-%x"`, object.LastModified.UnixNano(), object.Size)
+	header.Set("Cache-Control", h.cacheControl)
+	header.Set("ETag", etag)
+	if !object.LastModified.IsZero() {
+		header.Set("Last-Modified", object.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(c.Request(), etag, object.LastModified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	if object.Size >= 0 {
+		header.Set(echo.HeaderContentLength, strconv.FormatInt(object.Size, 10))
+	}
+	header.Set(echo.HeaderContentType, object.ContentType)
+	c.Response().WriteHeader(http.StatusOK)
+	if c.Request().Method == http.MethodHead {
+		return nil
+	}
+	_, err = io.Copy(c.Response(), content)
+	return err
+}
+
+func (h *handler) public(key string) bool {
+	if len(h.prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range h.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			if candidate = strings.TrimSpace(candidate); candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !lastModified.IsZero() && !lastModified.Truncate(time.Second).After(since)
+}
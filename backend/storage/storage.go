@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the object doesn't exist.
+var ErrNotFound = errors.New("storage: object not found")
+
+// Object describes a stored blob.
+type Object struct {
+	Key          string
+	ContentType  string
+	Size         int64
+	LastModified time.Time
+}
+
+// Storage stores blobs under slash-separated keys, e.g. avatars/1/abc/128.jpg.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get returns the content of the object, the caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	// Delete removes the object and all objects under key/.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidKey reports whether the key is relative and contains no empty, "." or ".." segments.
+func ValidKey(key string) bool {
+	if key == "" || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
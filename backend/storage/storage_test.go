@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a stand-in for an S3-compatible service which keeps the objects of a bucket in memory.
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{bucket: bucket, objects: map[string][]byte{}, types: map[string]string{}}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), s3Algorithm+" Credential=access/") ||
+		r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	prefix := "/" + f.bucket
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	switch {
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+	case r.Method == http.MethodGet && key == "":
+		result := listBucketResult{}
+		keys := []string{}
+		for k := range f.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			result.Contents = append(result.Contents, struct{ Key string }{k})
+		}
+		xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodGet:
+		content, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[key])
+		w.Write(content)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+	content := []byte("\x89PNG\r\n\x1a\n")
+	for _, key := range []string{"avatars/1/a/32.png", "avatars/1/a/64.png", "avatars/1/b/32.png"} {
+		require.Equal(t, nil, s.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "image/png"))
+	}
+
+	r, object, err := s.Get(ctx, "avatars/1/a/32.png")
+	require.Equal(t, nil, err)
+	got, _ := ioutil.ReadAll(r)
+	r.Close()
+	require.Equal(t, content, got)
+	require.Equal(t, "image/png", object.ContentType)
+	require.Equal(t, int64(len(content)), object.Size)
+
+	_, _, err = s.Get(ctx, "avatars/1/c/32.png")
+	require.Equal(t, ErrNotFound, err)
+	require.NotEqual(t, nil, s.Put(ctx, "avatars/../secret", bytes.NewReader(content), 0, "image/png"))
+
+	require.Equal(t, nil, s.Delete(ctx, "avatars/1/a"))
+	_, _, err = s.Get(ctx, "avatars/1/a/64.png")
+	require.Equal(t, ErrNotFound, err)
+	r, _, err = s.Get(ctx, "avatars/1/b/32.png")
+	require.Equal(t, nil, err)
+	r.Close()
+}
+
+func TestLocalStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	require.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+	s, err := NewLocalStorage(dir)
+	require.Equal(t, nil, err)
+	testStorage(t, s)
+}
+
+func TestS3Storage(t *testing.T) {
+	fake := newFakeS3("uploads")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	s, err := NewS3Storage(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "uploads",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	require.Equal(t, nil, err)
+	testStorage(t, s)
+	require.Equal(t, 1, len(fake.objects))
+}
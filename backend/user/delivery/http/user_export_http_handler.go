@@ -0,0 +1,100 @@
+package http
+
+import (
+	"backend/middleware"
+	"backend/models"
+	"backend/user"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-pg/urlstruct"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type Config struct {
+	UserUcase user.Usecase
+}
+
+type handler struct {
+	userUcase user.Usecase
+	logrus    *logrus.Entry
+}
+
+// NewUserExportHandler registers the route which exports the users to the admins. The query contains the format
+// and the conditions of UserFilter in the snake case, e.g. /users/export?format=json&role=1&created_at__gt=2020-01-01T00:00:00Z.
+func NewUserExportHandler(g *echo.Group, cfg Config) error {
+	if cfg.UserUcase == nil {
+		return fmt.Errorf("UserUcase cannot be nil")
+	}
+	h := &handler{cfg.UserUcase, logrus.WithField("package", "user/delivery/http")}
+	g.GET("/users/export", h.export)
+	return nil
+}
+
+func (h *handler) export(c echo.Context) error {
+	ctx := c.Request().Context()
+	me, err := middleware.UserFromContext(ctx)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	} else if me.Role != models.UserAdminRole {
+		return echo.NewHTTPError(http.StatusForbidden)
+	}
+
+	query := c.QueryParams()
+	format := models.UserFileFormatCSV
+	if value := query.Get("format"); value != "" {
+		format = models.UserFileFormat(strings.ToUpper(value))
+	}
+	query.Del("format")
+	f := &models.UserFilter{}
+	if !format.IsValid() {
+		return echo.NewHTTPError(http.StatusBadRequest, "Unsupported format.")
+	} else if err := urlstruct.Unmarshal(ctx, query, f); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	// the filter of the accounts waiting for the deletion isn't exposed
+	f.DeletionScheduledAtLT = time.Time{}
+
+	w := &exportWriter{c.Response(), func(header http.Header) {
+		header.Set("Cache-Control", "private, no-store")
+		if format == models.UserFileFormatJSON {
+			header.Set(echo.HeaderContentType, "application/x-ndjson")
+			header.Set(echo.HeaderContentDisposition, `attachment; filename="users.jsonl"`)
+		} else {
+			header.Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+			header.Set(echo.HeaderContentDisposition, `attachment; filename="users.csv"`)
+		}
+	}}
+	if err := h.userUcase.Export(ctx, f, format, w); err != nil {
+		h.logrus.Errorf("Cannot export users: %s", err.Error())
+		if !c.Response().Committed {
+			return echo.NewHTTPError(http.StatusInternalServerError)
+		}
+		// the client gets a truncated file, the status is already sent
+		return nil
+	}
+	w.start()
+	return nil
+}
+
+// exportWriter sends the headers with the first bytes, so the errors which occur before the export starts
+// get an error response.
+type exportWriter struct {
+	res    *echo.Response
+	header func(header http.Header)
+}
+
+func (w *exportWriter) Write(b []byte) (int, error) {
+	w.start()
+	return w.res.Write(b)
+}
+
+func (w *exportWriter) start() {
+	if !w.res.Committed {
+		w.header(w.res.Header())
+		w.res.WriteHeader(http.StatusOK)
+	}
+}
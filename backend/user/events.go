@@ -0,0 +1,16 @@
+package user
+
+import (
+	"context"
+
+	"backend/models"
+)
+
+type Events interface {
+	PublishUserCreated(ctx context.Context, u *models.User) error
+	PublishUserUpdated(ctx context.Context, u *models.User) error
+	PublishAccountChanged(ctx context.Context, e *models.AccountEvent) error
+	UserCreated(ctx context.Context) (<-chan *models.User, error)
+	UserUpdated(ctx context.Context, id int) (<-chan *models.User, error)
+	AccountChanged(ctx context.Context, id int) (<-chan *models.AccountEvent, error)
+}
@@ -0,0 +1,120 @@
+package events
+
+import (
+	"backend/models"
+	"backend/pubsub"
+	"backend/user"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	topicUserCreated    = "user.created"
+	topicUserUpdated    = "user.updated.%d"
+	topicAccountChanged = "user.accountChanged.%d"
+)
+
+type userEvents struct {
+	ps     pubsub.PubSub
+	logrus *logrus.Entry
+}
+
+func NewUserEvents(ps pubsub.PubSub) user.Events {
+	return &userEvents{
+		ps,
+		logrus.WithField("package", "user/events"),
+	}
+}
+
+func (e *userEvents) PublishUserCreated(ctx context.Context, u *models.User) error {
+	e.logrus.WithField("id", u.ID).Debug("PublishUserCreated")
+	return e.publish(ctx, topicUserCreated, sanitize(u))
+}
+
+func (e *userEvents) PublishUserUpdated(ctx context.Context, u *models.User) error {
+	e.logrus.WithField("id", u.ID).Debug("PublishUserUpdated")
+	return e.publish(ctx, fmt.Sprintf(topicUserUpdated, u.ID), sanitize(u))
+}
+
+func (e *userEvents) PublishAccountChanged(ctx context.Context, event *models.AccountEvent) error {
+	e.logrus.WithField("id", event.User.ID).WithField("type", event.Type).Debug("PublishAccountChanged")
+	return e.publish(ctx, fmt.Sprintf(topicAccountChanged, event.User.ID), &models.AccountEvent{
+		Type: event.Type,
+		User: sanitize(event.User),
+	})
+}
+
+func (e *userEvents) UserCreated(ctx context.Context) (<-chan *models.User, error) {
+	return e.subscribeToUsers(ctx, topicUserCreated)
+}
+
+func (e *userEvents) UserUpdated(ctx context.Context, id int) (<-chan *models.User, error) {
+	return e.subscribeToUsers(ctx, fmt.Sprintf(topicUserUpdated, id))
+}
+
+func (e *userEvents) AccountChanged(ctx context.Context, id int) (<-chan *models.AccountEvent, error) {
+	messages, err := e.ps.Subscribe(ctx, fmt.Sprintf(topicAccountChanged, id))
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *models.AccountEvent, 1)
+	go func() {
+		defer close(ch)
+		for msg := range messages {
+			event := &models.AccountEvent{}
+			if err := json.Unmarshal(msg, event); err != nil {
+				e.logrus.Debugf("AccountChanged - Cannot decode event: %s", err.Error())
+				continue
+			}
+			select {
+			case ch <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (e *userEvents) subscribeToUsers(ctx context.Context, topic string) (<-chan *models.User, error) {
+	messages, err := e.ps.Subscribe(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *models.User, 1)
+	go func() {
+		defer close(ch)
+		for msg := range messages {
+			u := &models.User{}
+			if err := json.Unmarshal(msg, u); err != nil {
+				e.logrus.WithField("topic", topic).Debugf("Cannot decode user: %s", err.Error())
+				continue
+			}
+			select {
+			case ch <- u:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (e *userEvents) publish(ctx context.Context, topic string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return e.ps.Publish(ctx, topic, b)
+}
+
+// sanitize returns a copy of the user without the password hash, the events payload
+// can travel through the database and should not carry any secrets.
+func sanitize(u *models.User) *models.User {
+	copy := *u
+	copy.Password = ""
+	return &copy
+}
@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"io"
 
 	"backend/models"
 )
@@ -10,9 +11,20 @@ type Repository interface {
 	Fetch(ctx context.Context, f *models.UserFilter) (models.UserList, error)
 	GetByID(ctx context.Context, id int) (*models.User, error)
 	GetBySlug(ctx context.Context, slug string) (*models.User, error)
+	GetByIDs(ctx context.Context, ids []int) ([]*models.User, error)
+	GetBySlugs(ctx context.Context, slugs []string) ([]*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	GetByCredentials(ctx context.Context, login, password string) (*models.User, error)
 	Update(ctx context.Context, u *models.User) error
+	UpdateColumns(ctx context.Context, u *models.User, columns ...string) error
 	Store(ctx context.Context, u *models.User) error
 	Delete(ctx context.Context, f *models.UserFilter) ([]*models.User, error)
+	// GetByIdentities returns the users with any of the logins or emails, they are compared in the canonical form.
+	GetByIdentities(ctx context.Context, logins, emails []string) ([]*models.User, error)
+	// Import inserts all users or none of them. The users are inserted as they are, the passwords have to be
+	// hashed and the identities canonicalized, and their ids aren't set. The activation tokens are inserted too.
+	// Large files are imported with several calls in one transaction.
+	Import(ctx context.Context, users []*models.User) error
+	// Export writes the users matched by the filter, without their passwords and tokens, to w.
+	Export(ctx context.Context, f *models.UserFilter, format models.UserFileFormat, w io.Writer) error
 }
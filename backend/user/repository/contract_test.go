@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	_errors "backend/errors"
+	"backend/models"
+	"backend/user"
+	"backend/utils/testutil"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+func newContractUser(login string, role int, activated bool) *models.User {
+	return &models.User{
+		Login:     login,
+		Password:  "Password123",
+		Email:     login + "@example.com",
+		Role:      role,
+		Activated: &activated,
+	}
+}
+
+// testContract runs the tests which every user.Repository must pass, newRepo returns a repository
+// without the users of the other tests.
+func testContract(t *testing.T, newRepo func(t *testing.T) user.Repository) {
+	ctx := context.Background()
+
+	store := func(t *testing.T, repo user.Repository, users ...*models.User) []int {
+		ids := []int{}
+		for _, u := range users {
+			require.Equal(t, nil, repo.Store(ctx, u))
+			require.NotEqual(t, 0, u.ID)
+			ids = append(ids, u.ID)
+		}
+		return ids
+	}
+
+	t.Run("Store", func(t *testing.T) {
+		repo := newRepo(t)
+		u := &models.User{Login: "Contract_Jöhn", Password: "Password123", Email: "contract.john@example.com", Role: 1}
+		store(t, repo, u)
+		require.NotEqual(t, "Password123", u.Password)
+
+		stored, err := repo.GetByID(ctx, u.ID)
+		require.Equal(t, nil, err)
+		require.Equal(t, "Contract_Jöhn", stored.Login)
+		require.Equal(t, u.Password, stored.Password)
+		require.Equal(t, nil, stored.CompareHashAndPassword("Password123"))
+		require.Equal(t, false, *stored.Activated)
+		require.Equal(t, false, stored.CreatedAt.IsZero())
+		require.Equal(t, false, stored.ActivationTokenGeneratedAt.IsZero())
+		require.Equal(t, fmt.Sprintf("%d-contract_john", u.ID), stored.Slug)
+
+		bySlug, err := repo.GetBySlug(ctx, stored.Slug)
+		require.Equal(t, nil, err)
+		require.Equal(t, u.ID, bySlug.ID)
+
+		byEmail, err := repo.GetByEmail(ctx, "Contract.John@EXAMPLE.com")
+		require.Equal(t, nil, err)
+		require.Equal(t, u.ID, byEmail.ID)
+	})
+
+	t.Run("Unique login and email", func(t *testing.T) {
+		repo := newRepo(t)
+		store(t, repo, newContractUser("contract-unique", 1, true))
+
+		err := repo.Store(ctx, &models.User{Login: "Contract-Unique", Password: "Password123", Email: "other@example.com", Role: 1})
+		require.Equal(t, _errors.ErrLoginMustBeUnique, testutil.ErrorCode(err))
+		require.Equal(t, "login", _errors.ToGqlError(err).Extensions["field"])
+
+		err = repo.Store(ctx, &models.User{Login: "contract-other", Password: "Password123", Email: "CONTRACT-UNIQUE@example.com", Role: 1})
+		require.Equal(t, _errors.ErrEmailMustBeUnique, testutil.ErrorCode(err))
+		require.Equal(t, "email", _errors.ToGqlError(err).Extensions["field"])
+	})
+
+	t.Run("Login and email of other users", func(t *testing.T) {
+		repo := newRepo(t)
+		victim := newContractUser("contract-victim", 1, true)
+		store(t, repo, victim)
+
+		// the users created by the previous versions can have @ in the login
+		err := repo.Store(ctx, &models.User{Login: "Contract-Victim@example.com", Password: "Password123", Email: "contract-attacker@example.com", Role: 1})
+		require.Equal(t, _errors.ErrLoginMustBeUnique, testutil.ErrorCode(err))
+		require.Equal(t, "login", _errors.ToGqlError(err).Extensions["field"])
+
+		attacker := newContractUser("contract-attacker", 1, true)
+		store(t, repo, attacker)
+		err = repo.UpdateColumns(ctx, &models.User{ID: attacker.ID, Login: "contract-victim@example.com"}, "login")
+		require.Equal(t, _errors.ErrLoginMustBeUnique, testutil.ErrorCode(err))
+		err = repo.Update(ctx, &models.User{ID: attacker.ID, Login: "contract-victim@example.com"})
+		require.Equal(t, _errors.ErrLoginMustBeUnique, testutil.ErrorCode(err))
+
+		legacy := &models.User{Login: "contract-legacy@example.org", Password: "Password123", Email: "contract-legacy@example.com", Role: 1}
+		store(t, repo, legacy)
+		err = repo.UpdateColumns(ctx, &models.User{ID: attacker.ID, Email: "Contract-Legacy@example.org"}, "email")
+		require.Equal(t, _errors.ErrEmailMustBeUnique, testutil.ErrorCode(err))
+		require.Equal(t, "email", _errors.ToGqlError(err).Extensions["field"])
+
+		// the user can keep its own identifiers
+		require.Equal(t, nil, repo.Update(ctx, &models.User{ID: legacy.ID, Login: "contract-legacy@example.org", Bio: "bio"}))
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		repo := newRepo(t)
+		ids := store(t, repo, newContractUser("contract-missing", 1, true))
+
+		_, err := repo.GetByID(ctx, ids[0]+1000)
+		require.Equal(t, _errors.ErrUserNotFound, testutil.ErrorCode(err))
+		_, err = repo.GetBySlug(ctx, "contract-missing-slug")
+		require.Equal(t, _errors.ErrUserNotFound, testutil.ErrorCode(err))
+		_, err = repo.GetByEmail(ctx, "contract-missing@example.org")
+		require.Equal(t, _errors.ErrUserNotFound, testutil.ErrorCode(err))
+		err = repo.Update(ctx, &models.User{ID: ids[0] + 1000, Bio: "bio"})
+		require.Equal(t, _errors.ErrUserNotFound, testutil.ErrorCode(err))
+	})
+
+	t.Run("GetByCredentials", func(t *testing.T) {
+		repo := newRepo(t)
+		u := newContractUser("contract-credentials", 1, true)
+		store(t, repo, u)
+
+		for _, login := range []string{"contract-credentials", "Contract-Credentials", "CONTRACT-CREDENTIALS@example.com"} {
+			found, err := repo.GetByCredentials(ctx, login, "Password123")
+			require.Equal(t, nil, err, login)
+			require.Equal(t, u.ID, found.ID)
+		}
+		_, err := repo.GetByCredentials(ctx, "contract-credentials", "Password1234")
+		require.Equal(t, _errors.ErrInvalidCredentials, testutil.ErrorCode(err))
+		_, err = repo.GetByCredentials(ctx, "contract-unknown", "Password123")
+		require.Equal(t, _errors.ErrInvalidCredentials, testutil.ErrorCode(err))
+
+		// the identifiers with @ are compared only with the emails
+		legacy := &models.User{Login: "contract-credentials@example.org", Password: "Password123", Email: "contract-legacy@example.com", Role: 1}
+		store(t, repo, legacy)
+		_, err = repo.GetByCredentials(ctx, "contract-credentials@example.org", "Password123")
+		require.Equal(t, _errors.ErrInvalidCredentials, testutil.ErrorCode(err))
+		found, err := repo.GetByCredentials(ctx, "contract-legacy@example.com", "Password123")
+		require.Equal(t, nil, err)
+		require.Equal(t, legacy.ID, found.ID)
+	})
+
+	t.Run("Fetch", func(t *testing.T) {
+		repo := newRepo(t)
+		ids := store(t, repo,
+			newContractUser("contract-fetch-a", models.UserAdminRole, true),
+			newContractUser("contract-fetch-b", models.UserDefaultRole, false),
+			newContractUser("contract-fetch-c", models.UserDefaultRole, true),
+			newContractUser("contract-fetch-d", models.UserDefaultRole, true),
+		)
+		logins := func(users []*models.User) []string {
+			logins := []string{}
+			for _, u := range users {
+				logins = append(logins, u.Login)
+			}
+			return logins
+		}
+
+		list, err := repo.Fetch(ctx, &models.UserFilter{ID: ids, Order: []string{"login DESC"}, Offset: 1, Limit: 2})
+		require.Equal(t, nil, err)
+		require.Equal(t, 4, list.Total)
+		require.Equal(t, []string{"contract-fetch-c", "contract-fetch-b"}, logins(list.Items))
+
+		list, err = repo.Fetch(ctx, &models.UserFilter{ID: ids, IdNEQ: ids[:1], Role: []int{models.UserDefaultRole}, Activated: "true", Order: []string{"id"}})
+		require.Equal(t, nil, err)
+		require.Equal(t, []string{"contract-fetch-c", "contract-fetch-d"}, logins(list.Items))
+
+		list, err = repo.Fetch(ctx, &models.UserFilter{ID: ids, LoginMATCH: "contract-fetch-(a|d)", EmailNEQ: []string{"contract-fetch-a@example.com"}})
+		require.Equal(t, nil, err)
+		require.Equal(t, []string{"contract-fetch-d"}, logins(list.Items))
+
+		list, err = repo.Fetch(ctx, &models.UserFilter{ID: ids, Login: []string{"contract-fetch-b"}, Activated: "false"})
+		require.Equal(t, nil, err)
+		require.Equal(t, []string{"contract-fetch-b"}, logins(list.Items))
+
+		users, err := repo.GetByIDs(ctx, ids[1:3])
+		require.Equal(t, nil, err)
+		require.ElementsMatch(t, []string{"contract-fetch-b", "contract-fetch-c"}, logins(users))
+
+		users, err = repo.GetBySlugs(ctx, []string{fmt.Sprintf("%d-contract-fetch-d", ids[3])})
+		require.Equal(t, nil, err)
+		require.Equal(t, []string{"contract-fetch-d"}, logins(users))
+	})
+
+	t.Run("Fetch the scheduled deletions", func(t *testing.T) {
+		repo := newRepo(t)
+		ids := store(t, repo,
+			newContractUser("contract-deletion-past", 1, true),
+			newContractUser("contract-deletion-future", 1, true),
+			newContractUser("contract-deletion-none", 1, true),
+		)
+		now := time.Now()
+		past, future := now.Add(-time.Hour), now.Add(time.Hour)
+		require.Equal(t, nil, repo.UpdateColumns(ctx, &models.User{ID: ids[0], DeletionScheduledAt: &past}, "deletion_scheduled_at"))
+		require.Equal(t, nil, repo.UpdateColumns(ctx, &models.User{ID: ids[1], DeletionScheduledAt: &future}, "deletion_scheduled_at"))
+
+		// the users without a scheduled deletion don't match
+		list, err := repo.Fetch(ctx, &models.UserFilter{ID: ids, DeletionScheduledAtLT: now})
+		require.Equal(t, nil, err)
+		require.Equal(t, 1, list.Total)
+		require.Equal(t, ids[0], list.Items[0].ID)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		repo := newRepo(t)
+		u := newContractUser("contract-update", 1, true)
+		u.Bio = "bio"
+		store(t, repo, u)
+		store(t, repo, newContractUser("contract-taken", 1, true))
+
+		changes := &models.User{ID: u.ID, Login: "contract-updated"}
+		require.Equal(t, nil, repo.Update(ctx, changes))
+		// the whole row is returned
+		require.Equal(t, "contract-update@example.com", changes.Email)
+		require.Equal(t, "bio", changes.Bio)
+		require.Equal(t, fmt.Sprintf("%d-contract-updated", u.ID), changes.Slug)
+
+		found, err := repo.GetByCredentials(ctx, "Contract-Updated", "Password123")
+		require.Equal(t, nil, err)
+		require.Equal(t, u.ID, found.ID)
+
+		err = repo.Update(ctx, &models.User{ID: u.ID, Login: "CONTRACT-TAKEN"})
+		require.Equal(t, _errors.ErrLoginMustBeUnique, testutil.ErrorCode(err))
+	})
+
+	t.Run("UpdateColumns", func(t *testing.T) {
+		repo := newRepo(t)
+		u := newContractUser("contract-columns", 1, true)
+		u.Bio = "bio"
+		u.DisplayName = "John"
+		store(t, repo, u)
+
+		require.Equal(t, nil, repo.UpdateColumns(ctx, &models.User{ID: u.ID}, "bio"))
+		found, err := repo.GetByID(ctx, u.ID)
+		require.Equal(t, nil, err)
+		require.Equal(t, "", found.Bio)
+		require.Equal(t, "John", found.DisplayName)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepo(t)
+		ids := store(t, repo,
+			newContractUser("contract-delete-a", 1, true),
+			newContractUser("contract-delete-b", 1, true),
+		)
+
+		users, err := repo.Delete(ctx, &models.UserFilter{ID: ids[:1]})
+		require.Equal(t, nil, err)
+		require.Equal(t, 1, len(users))
+		require.Equal(t, "contract-delete-a", users[0].Login)
+		_, err = repo.GetByID(ctx, ids[0])
+		require.Equal(t, _errors.ErrUserNotFound, testutil.ErrorCode(err))
+		_, err = repo.GetByID(ctx, ids[1])
+		require.Equal(t, nil, err)
+	})
+
+	t.Run("Import and export", func(t *testing.T) {
+		repo := newRepo(t)
+		store(t, repo, newContractUser("contract-import-a", 1, true))
+		b := newContractUser("contract-import-b", 1, false)
+		b.Bio = "line\n\"quoted\", bio"
+		b.ActivationToken = "contract-import-token"
+		b.Canonicalize()
+		c := newContractUser("contract-import-c", 1, true)
+		c.Canonicalize()
+		require.Equal(t, nil, repo.Import(ctx, []*models.User{b, c}))
+
+		users, err := repo.GetByIdentities(ctx, []string{"Contract-Import-B"}, []string{"CONTRACT-IMPORT-A@example.com"})
+		require.Equal(t, nil, err)
+		require.Equal(t, 2, len(users))
+
+		// a single duplicate rejects the whole import
+		d := newContractUser("contract-import-d", 1, true)
+		d.Canonicalize()
+		duplicate := newContractUser("contract-import-a", 1, true)
+		duplicate.Canonicalize()
+		err = repo.Import(ctx, []*models.User{d, duplicate})
+		require.Equal(t, _errors.ErrLoginMustBeUnique, testutil.ErrorCode(err))
+		_, err = repo.GetByEmail(ctx, "contract-import-d@example.com")
+		require.Equal(t, _errors.ErrUserNotFound, testutil.ErrorCode(err))
+
+		found, err := repo.GetByEmail(ctx, "contract-import-b@example.com")
+		require.Equal(t, nil, err)
+		require.Equal(t, "contract-import-token", found.ActivationToken)
+		f := &models.UserFilter{LoginMATCH: "contract-import-%", Activated: "true", Order: []string{"login DESC"}}
+		buf := bytes.Buffer{}
+		require.Equal(t, nil, repo.Export(ctx, f, models.UserFileFormatJSON, &buf))
+		logins := []string{}
+		decoder := json.NewDecoder(&buf)
+		for decoder.More() {
+			e := map[string]interface{}{}
+			require.Equal(t, nil, decoder.Decode(&e))
+			require.Equal(t, nil, e["password"])
+			logins = append(logins, e["login"].(string))
+		}
+		require.Equal(t, []string{"contract-import-c", "contract-import-a"}, logins)
+
+		buf.Reset()
+		f = &models.UserFilter{ID: []int{found.ID}}
+		require.Equal(t, nil, repo.Export(ctx, f, models.UserFileFormatCSV, &buf))
+		require.Contains(t, buf.String(), "id,slug,login,email,role,activated,displayName,bio,locale,timezone,createdAt,updatedAt\n")
+		require.Contains(t, buf.String(), fmt.Sprintf("%d,%s,contract-import-b,contract-import-b@example.com,1,f,,\"line\n\"\"quoted\"\", bio\",", found.ID, found.Slug))
+	})
+}
@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"backend/cache"
+	"backend/models"
+	"backend/postgres"
+	"backend/pubsub"
+	"backend/user"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	topicUsersInvalidated = "user.cache.invalidated"
+	userIDCacheKey        = "user:id:%d"
+	userSlugCacheKey      = "user:slug:%s"
+)
+
+// rehashedKey carries the flag which GetByCredentials of the repositories sets when it rehashes the password.
+type rehashedKey struct{}
+
+// reportRehash tells the caller of GetByCredentials that the stored password changed.
+func reportRehash(ctx context.Context) {
+	if rehashed, ok := ctx.Value(rehashedKey{}).(*bool); ok {
+		*rehashed = true
+	}
+}
+
+type CacheConfig struct {
+	Repo  user.Repository
+	Cache cache.Cache
+	// PubSub delivers the invalidations to the other replicas, with the Postgres backend
+	// they are delivered after the transaction which changed the users is committed.
+	PubSub  pubsub.PubSub
+	Metrics *cache.Metrics
+}
+
+// cachedRepository caches the users found by GetByID and GetBySlug, the other methods go to the repository.
+// The slugs are cached as the ids of the users, so an invalidated user is never found by its old slug.
+type cachedRepository struct {
+	user.Repository
+	cache   cache.Cache
+	ps      pubsub.PubSub
+	metrics *cache.Metrics
+	logrus  *logrus.Entry
+}
+
+// NewCachedUserRepository decorates cfg.Repo with the cache, the users changed by Update, UpdateColumns,
+// Delete and GetByCredentials, when it rehashes the password, are invalidated on every replica. The changes
+// made around the decorator are visible after the entries expire.
+func NewCachedUserRepository(cfg CacheConfig) (user.Repository, error) {
+	repo := &cachedRepository{
+		Repository: cfg.Repo,
+		cache:      cfg.Cache,
+		ps:         cfg.PubSub,
+		metrics:    cfg.Metrics,
+		logrus:     logrus.WithField("package", "user/repository"),
+	}
+	if repo.metrics == nil {
+		repo.metrics = &cache.Metrics{}
+	}
+	if repo.ps != nil {
+		invalidations, err := repo.ps.Subscribe(context.Background(), topicUsersInvalidated)
+		if err != nil {
+			return nil, err
+		}
+		go repo.listen(invalidations)
+	}
+	return repo, nil
+}
+
+func (repo *cachedRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
+	// the transactions can see the changes which aren't committed yet
+	if postgres.InTx(ctx) {
+		return repo.Repository.GetByID(ctx, id)
+	}
+	if u, ok := repo.get(ctx, id); ok {
+		repo.metrics.Hit()
+		return u, nil
+	}
+	repo.metrics.Miss()
+	// a lagging replica could return the user invalidated a moment ago
+	u, err := repo.Repository.GetByID(postgres.WithPrimary(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	repo.set(ctx, u)
+	return u, nil
+}
+
+func (repo *cachedRepository) GetBySlug(ctx context.Context, slug string) (*models.User, error) {
+	if postgres.InTx(ctx) {
+		return repo.Repository.GetBySlug(ctx, slug)
+	}
+	if b, ok := repo.cache.Get(ctx, fmt.Sprintf(userSlugCacheKey, slug)); ok {
+		id, _ := strconv.Atoi(string(b))
+		// the login and so the slug of the user could have changed
+		if u, ok := repo.get(ctx, id); ok && u.Slug == slug {
+			repo.metrics.Hit()
+			return u, nil
+		}
+	}
+	repo.metrics.Miss()
+	u, err := repo.Repository.GetBySlug(postgres.WithPrimary(ctx), slug)
+	if err != nil {
+		return nil, err
+	}
+	repo.set(ctx, u)
+	return u, nil
+}
+
+func (repo *cachedRepository) GetByCredentials(ctx context.Context, login, password string) (*models.User, error) {
+	rehashed := false
+	u, err := repo.Repository.GetByCredentials(context.WithValue(ctx, rehashedKey{}, &rehashed), login, password)
+	if err != nil {
+		return nil, err
+	}
+	if rehashed {
+		repo.invalidate(ctx, u.ID)
+	}
+	return u, nil
+}
+
+func (repo *cachedRepository) Update(ctx context.Context, u *models.User) error {
+	if err := repo.Repository.Update(ctx, u); err != nil {
+		return err
+	}
+	repo.invalidate(ctx, u.ID)
+	return nil
+}
+
+func (repo *cachedRepository) UpdateColumns(ctx context.Context, u *models.User, columns ...string) error {
+	if err := repo.Repository.UpdateColumns(ctx, u, columns...); err != nil {
+		return err
+	}
+	repo.invalidate(ctx, u.ID)
+	return nil
+}
+
+func (repo *cachedRepository) Delete(ctx context.Context, f *models.UserFilter) ([]*models.User, error) {
+	users, err := repo.Repository.Delete(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int{}
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	repo.invalidate(ctx, ids...)
+	return users, nil
+}
+
+func (repo *cachedRepository) get(ctx context.Context, id int) (*models.User, bool) {
+	b, ok := repo.cache.Get(ctx, fmt.Sprintf(userIDCacheKey, id))
+	if !ok {
+		return nil, false
+	}
+	// every caller gets its own copy, the users are modified before they are updated
+	u := &models.User{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(u); err != nil {
+		repo.logrus.WithField("id", id).Debugf("get - Cannot decode user: %s", err.Error())
+		return nil, false
+	}
+	return u, true
+}
+
+func (repo *cachedRepository) set(ctx context.Context, u *models.User) {
+	// the password and tokens are cached as well, json would skip them
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(u); err != nil {
+		repo.logrus.WithField("id", u.ID).Debugf("set - Cannot encode user: %s", err.Error())
+		return
+	}
+	repo.cache.Set(ctx, fmt.Sprintf(userIDCacheKey, u.ID), buf.Bytes())
+	repo.cache.Set(ctx, fmt.Sprintf(userSlugCacheKey, u.Slug), []byte(strconv.Itoa(u.ID)))
+}
+
+// invalidate removes the users from the local cache at once and publishes the invalidation. The message joins
+// the transaction carried by ctx, so the users cached again before the commit are removed after it.
+func (repo *cachedRepository) invalidate(ctx context.Context, ids ...int) {
+	if len(ids) == 0 {
+		return
+	}
+	repo.delete(ctx, ids)
+	if repo.ps == nil {
+		return
+	}
+	b, err := json.Marshal(ids)
+	if err == nil {
+		err = repo.ps.Publish(ctx, topicUsersInvalidated, b)
+	}
+	if err != nil {
+		repo.logrus.WithField("ids", ids).Errorf("invalidate - Cannot publish invalidation: %s", err.Error())
+	}
+}
+
+func (repo *cachedRepository) listen(invalidations <-chan []byte) {
+	for b := range invalidations {
+		ids := []int{}
+		if err := json.Unmarshal(b, &ids); err != nil {
+			repo.logrus.Debugf("listen - Cannot decode invalidation: %s", err.Error())
+			continue
+		}
+		repo.delete(context.Background(), ids)
+	}
+}
+
+func (repo *cachedRepository) delete(ctx context.Context, ids []int) {
+	keys := []string{}
+	for _, id := range ids {
+		keys = append(keys, fmt.Sprintf(userIDCacheKey, id))
+	}
+	repo.cache.Delete(ctx, keys...)
+	repo.metrics.Invalidate(len(keys))
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/cache"
+	"backend/hasher"
+	"backend/models"
+	"backend/pubsub"
+	"backend/user"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCachedRepository(t *testing.T) {
+	testContract(t, func(t *testing.T) user.Repository {
+		repo, err := NewCachedUserRepository(CacheConfig{
+			Repo:  NewMemoryUserRepository(),
+			Cache: cache.NewLRU(100, time.Minute),
+		})
+		require.Equal(t, nil, err)
+		return repo
+	})
+
+	t.Run("Invalidation", func(t *testing.T) {
+		ctx := context.Background()
+		ps := pubsub.NewInMemoryPubSub()
+		defer ps.Close()
+		db := NewMemoryUserRepository()
+		metrics := &cache.Metrics{}
+		// the replicas share the database and the pubsub, but not the caches
+		replica, err := NewCachedUserRepository(CacheConfig{Repo: db, Cache: cache.NewLRU(100, time.Minute), PubSub: ps, Metrics: metrics})
+		require.Equal(t, nil, err)
+		other, err := NewCachedUserRepository(CacheConfig{Repo: db, Cache: cache.NewLRU(100, time.Minute), PubSub: ps})
+		require.Equal(t, nil, err)
+
+		u := newContractUser("cached", 1, true)
+		require.Equal(t, nil, db.Store(ctx, u))
+		var slug string
+		for i := 0; i < 2; i++ {
+			found, err := replica.GetByID(ctx, u.ID)
+			require.Equal(t, nil, err)
+			require.Equal(t, "cached", found.Login)
+			slug = found.Slug
+		}
+		_, err = replica.GetBySlug(ctx, slug)
+		require.Equal(t, nil, err)
+		require.Equal(t, cache.Stats{Hits: 2, Misses: 1}, metrics.Stats())
+
+		// the cached users are copies
+		found, _ := replica.GetByID(ctx, u.ID)
+		found.Bio = "changed"
+		found, _ = replica.GetByID(ctx, u.ID)
+		require.Equal(t, "", found.Bio)
+
+		require.Equal(t, nil, other.Update(ctx, &models.User{ID: u.ID, Login: "renamed"}))
+		require.Eventually(t, func() bool {
+			return metrics.Stats().Invalidations == 1
+		}, time.Second, time.Millisecond)
+		found, err = replica.GetByID(ctx, u.ID)
+		require.Equal(t, nil, err)
+		require.Equal(t, "renamed", found.Login)
+		// the old slug belongs to nobody
+		_, err = replica.GetBySlug(ctx, slug)
+		require.NotEqual(t, nil, err)
+	})
+
+	t.Run("Invalidation of the changes only", func(t *testing.T) {
+		ctx := context.Background()
+		db := NewMemoryUserRepository()
+		metrics := &cache.Metrics{}
+		repo, err := NewCachedUserRepository(CacheConfig{Repo: db, Cache: cache.NewLRU(100, time.Minute), Metrics: metrics})
+		require.Equal(t, nil, err)
+		u := newContractUser("cached-sign-in", 1, true)
+		require.Equal(t, nil, db.Store(ctx, u))
+		taken := newContractUser("cached-taken", 1, true)
+		require.Equal(t, nil, db.Store(ctx, taken))
+
+		_, err = repo.GetByCredentials(ctx, "cached-sign-in", "Password123")
+		require.Equal(t, nil, err)
+		require.NotEqual(t, nil, repo.Update(ctx, &models.User{ID: u.ID, Login: "Cached-Taken"}))
+		require.NotEqual(t, nil, repo.UpdateColumns(ctx, &models.User{ID: u.ID, Login: "Cached-Taken"}, "login"))
+		require.Equal(t, int64(0), metrics.Stats().Invalidations)
+
+		// the password hashed with other parameters is rehashed by the sign in
+		hash, err := hasher.NewBcrypt(bcrypt.MinCost + 1).Hash("Password123")
+		require.Equal(t, nil, err)
+		db.(*memoryRepository).users[u.ID].Password = hash
+		_, err = repo.GetByCredentials(ctx, "cached-sign-in", "Password123")
+		require.Equal(t, nil, err)
+		require.Equal(t, int64(1), metrics.Stats().Invalidations)
+		_, err = repo.GetByCredentials(ctx, "cached-sign-in", "Password123")
+		require.Equal(t, nil, err)
+		require.Equal(t, int64(1), metrics.Stats().Invalidations)
+
+		require.Equal(t, nil, repo.UpdateColumns(ctx, &models.User{ID: u.ID, Bio: "bio"}, "bio"))
+		require.Equal(t, int64(2), metrics.Stats().Invalidations)
+	})
+}
@@ -0,0 +1,722 @@
+package repository
+
+import (
+	"backend/user"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+
+	_errors "backend/errors"
+	"backend/hasher"
+	"backend/identity"
+	"backend/models"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/go-pg/urlstruct"
+	"golang.org/x/text/unicode/norm"
+)
+
+// postgresTimeFormat is the format of the timestamps in the CSV files exported by Postgres.
+const postgresTimeFormat = "2006-01-02 15:04:05.999999-07"
+
+var (
+	userTable  = orm.GetTable(reflect.TypeOf(models.User{}))
+	filterInfo = urlstruct.DescribeStruct(reflect.TypeOf(models.UserFilter{}))
+)
+
+// uniqueColumns are checked in this order, like the constraints of the users table.
+var uniqueColumns = []struct {
+	column string
+	code   string
+	field  string
+}{
+	{"login", _errors.ErrLoginMustBeUnique, "login"},
+	{"login_canonical", _errors.ErrLoginMustBeUnique, "login"},
+	{"email", _errors.ErrEmailMustBeUnique, "email"},
+	{"email_canonical", _errors.ErrEmailMustBeUnique, "email"},
+	{"slug", _errors.ErrInternalServerError, ""},
+}
+
+type memoryRepository struct {
+	mutex  sync.RWMutex
+	users  map[int]*models.User
+	nextID int
+	logrus *logrus.Entry
+}
+
+// NewMemoryUserRepository returns the repository which keeps the users in memory, it is meant for the unit tests.
+// It follows the semantics of the Postgres repository: the filters, the zero values stored as NULLs, the unique
+// constraints, the slugs generated by the trigger and the model hooks. The transactions aren't supported
+// and the strings are ordered by their bytes instead of the database collation.
+func NewMemoryUserRepository() user.Repository {
+	return &memoryRepository{
+		users:  map[int]*models.User{},
+		nextID: 1,
+		logrus: logrus.WithField("package", "user/repository"),
+	}
+}
+
+func (repo *memoryRepository) Fetch(ctx context.Context, f *models.UserFilter) (models.UserList, error) {
+	repo.logrus.WithField("filter", f).Debug("Fetch")
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	pagination := models.UserList{}
+	users := repo.filter(f)
+	if f != nil {
+		if err := orderUsers(users, f.Order); err != nil {
+			return pagination, _errors.Wrap(_errors.ErrInternalServerError, err)
+		}
+	}
+	pagination.Total = len(users)
+	if f != nil {
+		users = paginate(users, f.Offset, f.Limit)
+	}
+	pagination.Items = users
+	return pagination, nil
+}
+
+func (repo *memoryRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
+	repo.logrus.WithField("id", id).Debug("GetByID")
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	return repo.find(func(u *models.User) bool {
+		return u.ID == id
+	}, _errors.ErrUserNotFound)
+}
+
+func (repo *memoryRepository) GetBySlug(ctx context.Context, slug string) (*models.User, error) {
+	repo.logrus.WithField("slug", slug).Debug("GetBySlug")
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	return repo.find(func(u *models.User) bool {
+		return u.Slug == slug
+	}, _errors.ErrUserNotFound)
+}
+
+func (repo *memoryRepository) GetByIDs(ctx context.Context, ids []int) ([]*models.User, error) {
+	repo.logrus.WithField("ids", ids).Debug("GetByIDs")
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	return repo.findAll(func(u *models.User) bool {
+		for _, id := range ids {
+			if u.ID == id {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (repo *memoryRepository) GetBySlugs(ctx context.Context, slugs []string) ([]*models.User, error) {
+	repo.logrus.WithField("slugs", slugs).Debug("GetBySlugs")
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	return repo.findAll(func(u *models.User) bool {
+		for _, slug := range slugs {
+			if u.Slug == slug {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (repo *memoryRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	repo.logrus.WithField("email", email).Debug("GetByEmail")
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	canonical := identity.Canonical(email)
+	return repo.find(func(u *models.User) bool {
+		return u.EmailCanonical != "" && u.EmailCanonical == canonical
+	}, _errors.ErrUserNotFound)
+}
+
+func (repo *memoryRepository) GetByCredentials(ctx context.Context, login, password string) (*models.User, error) {
+	repo.logrus.WithField("login", login).Debug("GetByCredentials")
+	canonical := identity.Canonical(login)
+	isEmail := identity.IsEmail(login)
+	repo.mutex.RLock()
+	u, err := repo.find(func(u *models.User) bool {
+		if isEmail {
+			return u.EmailCanonical != "" && u.EmailCanonical == canonical
+		}
+		return u.LoginCanonical == canonical
+	}, _errors.ErrInvalidCredentials)
+	repo.mutex.RUnlock()
+	if err != nil {
+		return u, err
+	}
+	if err := u.CompareHashAndPassword(password); err != nil {
+		return nil, err
+	}
+	if hasher.Default().NeedsRehash(u.Password) {
+		if hash, err := hasher.Default().Hash(password); err == nil {
+			repo.mutex.Lock()
+			if stored, ok := repo.users[u.ID]; ok {
+				stored.Password = hash
+			}
+			repo.mutex.Unlock()
+			u.Password = hash
+			reportRehash(ctx)
+		}
+	}
+	return u, nil
+}
+
+func (repo *memoryRepository) Update(ctx context.Context, u *models.User) error {
+	repo.logrus.WithField("user", u).Debug("Update")
+	if _, err := u.BeforeUpdate(ctx); err != nil {
+		return _errors.Wrap(_errors.ErrInternalServerError, err)
+	}
+	columns := []string{}
+	value := reflect.ValueOf(u).Elem()
+	for _, f := range userTable.DataFields {
+		if !f.HasZeroValue(value) {
+			columns = append(columns, f.SQLName)
+		}
+	}
+	return repo.update(u, columns)
+}
+
+func (repo *memoryRepository) UpdateColumns(ctx context.Context, u *models.User, columns ...string) error {
+	repo.logrus.WithField("user", u).WithField("columns", columns).Debug("UpdateColumns")
+	if _, err := u.BeforeUpdate(ctx); err != nil {
+		return _errors.Wrap(_errors.ErrInternalServerError, err)
+	}
+	columns = append(columns, "updated_at")
+	for _, column := range columns {
+		if column == "login" || column == "email" {
+			columns = append(columns, column+"_canonical")
+		}
+	}
+	return repo.update(u, columns)
+}
+
+func (repo *memoryRepository) Store(ctx context.Context, u *models.User) error {
+	repo.logrus.WithField("user", u).Debug("Store")
+	if _, err := u.BeforeInsert(ctx); err != nil {
+		return _errors.Wrap(_errors.ErrInternalServerError, err)
+	}
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	stored, err := repo.insert(u)
+	if err != nil {
+		return err
+	}
+	// the insert returns only the primary key
+	u.ID = stored.ID
+	return nil
+}
+
+func (repo *memoryRepository) GetByIdentities(ctx context.Context, logins, emails []string) ([]*models.User, error) {
+	repo.logrus.WithField("logins", len(logins)).WithField("emails", len(emails)).Debug("GetByIdentities")
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	canonicalLogins := map[string]bool{}
+	for _, login := range logins {
+		canonicalLogins[identity.Canonical(login)] = true
+	}
+	canonicalEmails := map[string]bool{}
+	for _, email := range emails {
+		canonicalEmails[identity.Canonical(email)] = true
+	}
+	return repo.findAll(func(u *models.User) bool {
+		return canonicalLogins[u.LoginCanonical] || canonicalEmails[u.EmailCanonical]
+	}), nil
+}
+
+func (repo *memoryRepository) Import(ctx context.Context, users []*models.User) error {
+	repo.logrus.WithField("users", len(users)).Debug("Import")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	nextID := repo.nextID
+	inserted := []int{}
+	for _, u := range users {
+		stored, err := repo.insert(&models.User{
+			Login:           u.Login,
+			Password:        u.Password,
+			Email:           u.Email,
+			LoginCanonical:  u.LoginCanonical,
+			EmailCanonical:  u.EmailCanonical,
+			Role:            u.Role,
+			Activated:       u.Activated,
+			ActivationToken: u.ActivationToken,
+			DisplayName:     u.DisplayName,
+			Bio:             u.Bio,
+			Locale:          u.Locale,
+			Timezone:        u.Timezone,
+		})
+		if err != nil {
+			// COPY is a single statement
+			for _, id := range inserted {
+				delete(repo.users, id)
+			}
+			repo.nextID = nextID
+			return err
+		}
+		inserted = append(inserted, stored.ID)
+	}
+	return nil
+}
+
+// exportedUser has the fields of the users exported by Postgres.
+type exportedUser struct {
+	ID          int       `json:"id"`
+	Slug        string    `json:"slug"`
+	Login       string    `json:"login"`
+	Email       string    `json:"email"`
+	Role        int       `json:"role"`
+	Activated   bool      `json:"activated"`
+	DisplayName string    `json:"displayName"`
+	Bio         string    `json:"bio"`
+	Locale      string    `json:"locale"`
+	Timezone    string    `json:"timezone"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func (repo *memoryRepository) Export(ctx context.Context, f *models.UserFilter, format models.UserFileFormat, w io.Writer) error {
+	repo.logrus.WithField("filter", f).WithField("format", format).Debug("Export")
+	repo.mutex.RLock()
+	users := repo.filter(f)
+	repo.mutex.RUnlock()
+	order := []string{"id"}
+	if f != nil {
+		order = append(append([]string{}, f.Order...), order...)
+	}
+	if err := orderUsers(users, order); err != nil {
+		return _errors.Wrap(_errors.ErrInternalServerError, err)
+	}
+	if f != nil {
+		users = paginate(users, f.Offset, f.Limit)
+	}
+
+	encoder := json.NewEncoder(w)
+	csvWriter := csv.NewWriter(w)
+	if format != models.UserFileFormatJSON {
+		csvWriter.Write([]string{"id", "slug", "login", "email", "role", "activated", "displayName", "bio", "locale", "timezone", "createdAt", "updatedAt"})
+	}
+	for _, u := range users {
+		e := exportedUser{u.ID, u.Slug, u.Login, u.Email, u.Role, u.Activated != nil && *u.Activated,
+			u.DisplayName, u.Bio, u.Locale, u.Timezone, u.CreatedAt, u.UpdatedAt}
+		if format == models.UserFileFormatJSON {
+			if err := encoder.Encode(e); err != nil {
+				return _errors.Wrap(_errors.ErrInternalServerError, err)
+			}
+			continue
+		}
+		// the output of Postgres
+		activated := "f"
+		if e.Activated {
+			activated = "t"
+		}
+		csvWriter.Write([]string{strconv.Itoa(e.ID), e.Slug, e.Login, e.Email, strconv.Itoa(e.Role), activated,
+			e.DisplayName, e.Bio, e.Locale, e.Timezone, e.CreatedAt.Format(postgresTimeFormat), e.UpdatedAt.Format(postgresTimeFormat)})
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return _errors.Wrap(_errors.ErrInternalServerError, err)
+	}
+	return nil
+}
+
+// insert stores a copy of u like the INSERT statement, the caller holds the lock.
+func (repo *memoryRepository) insert(u *models.User) (*models.User, error) {
+	stored := copyUser(u)
+	if stored.ID == 0 {
+		stored.ID = repo.nextID
+	}
+	if _, ok := repo.users[stored.ID]; ok {
+		return nil, _errors.Wrap(_errors.ErrInternalServerError, fmt.Errorf("duplicate key value violates unique constraint \"users_pkey\""))
+	}
+	setDefaults(stored)
+	// set_slug_user trigger
+	if stored.Slug == "" && stored.Login != "" {
+		stored.Slug = slugify(stored.ID, stored.Login)
+	}
+	if err := repo.checkUnique(stored); err != nil {
+		return nil, err
+	}
+	if stored.ID >= repo.nextID {
+		repo.nextID = stored.ID + 1
+	}
+	repo.users[stored.ID] = stored
+	return stored, nil
+}
+
+func (repo *memoryRepository) Delete(ctx context.Context, f *models.UserFilter) ([]*models.User, error) {
+	repo.logrus.WithField("filter", f).Debug("Delete")
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	users := repo.filter(f)
+	for _, u := range users {
+		delete(repo.users, u.ID)
+	}
+	return users, nil
+}
+
+// update sets the columns of the stored user and copies the whole row back to u, like RETURNING *.
+func (repo *memoryRepository) update(u *models.User, columns []string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	stored, ok := repo.users[u.ID]
+	if !ok {
+		return _errors.Wrap(_errors.ErrUserNotFound, pg.ErrNoRows)
+	}
+	updated := copyUser(stored)
+	src := reflect.ValueOf(copyUser(u)).Elem()
+	dst := reflect.ValueOf(updated).Elem()
+	for _, column := range columns {
+		f, ok := userTable.FieldsMap[column]
+		if !ok {
+			return _errors.Wrap(_errors.ErrInternalServerError, fmt.Errorf("column %q does not exist", column))
+		}
+		f.Value(dst).Set(f.Value(src))
+	}
+	// update_slug_user trigger
+	if updated.Login != stored.Login {
+		updated.Slug = slugify(updated.ID, updated.Login)
+	}
+	if err := repo.checkUnique(updated); err != nil {
+		return err
+	}
+	repo.users[u.ID] = updated
+	*u = *copyUser(updated)
+	return nil
+}
+
+// checkUnique ignores the zero values, they are NULLs in the database.
+func (repo *memoryRepository) checkUnique(u *models.User) error {
+	value := reflect.ValueOf(u).Elem()
+	for _, unique := range uniqueColumns {
+		f := userTable.FieldsMap[unique.column]
+		if f.NullZero() && f.HasZeroValue(value) {
+			continue
+		}
+		for _, other := range repo.users {
+			if other.ID != u.ID && f.Value(reflect.ValueOf(other).Elem()).Interface() == f.Value(value).Interface() {
+				err := fmt.Errorf("duplicate key value violates unique constraint \"users_%s_key\"", unique.column)
+				return _errors.WrapField(unique.code, unique.field, nil, err)
+			}
+		}
+	}
+	// the login which is the email of another user and the other way round, like checkIdentities
+	// of the Postgres repository
+	for _, other := range repo.users {
+		if other.ID == u.ID {
+			continue
+		}
+		if u.LoginCanonical != "" && u.LoginCanonical == other.EmailCanonical {
+			return _errors.WrapField(_errors.ErrLoginMustBeUnique, "login", nil, fmt.Errorf("login is the email_canonical of another user"))
+		}
+		if u.EmailCanonical != "" && u.EmailCanonical == other.LoginCanonical {
+			return _errors.WrapField(_errors.ErrEmailMustBeUnique, "email", nil, fmt.Errorf("email is the login_canonical of another user"))
+		}
+	}
+	return nil
+}
+
+func (repo *memoryRepository) find(match func(u *models.User) bool, notFound string) (*models.User, error) {
+	users := repo.findAll(match)
+	if len(users) == 0 {
+		return &models.User{}, _errors.Wrap(notFound, pg.ErrNoRows)
+	}
+	return users[0], nil
+}
+
+// findAll returns the copies of the matching users ordered by the id, the callers hold the mutex.
+func (repo *memoryRepository) findAll(match func(u *models.User) bool) []*models.User {
+	users := []*models.User{}
+	for _, u := range repo.users {
+		if match(u) {
+			users = append(users, copyUser(u))
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	return users
+}
+
+func (repo *memoryRepository) filter(f *models.UserFilter) []*models.User {
+	return repo.findAll(func(u *models.User) bool {
+		return f == nil || matchesFilter(u, f)
+	})
+}
+
+// matchesFilter evaluates the filter like WhereStruct and the activated condition of the Postgres repository.
+func matchesFilter(u *models.User, f *models.UserFilter) bool {
+	userValue := reflect.ValueOf(u).Elem()
+	filterValue := reflect.ValueOf(f).Elem()
+	for _, field := range filterInfo.Fields {
+		fv := field.Value(filterValue)
+		if field.Omit(fv) {
+			continue
+		}
+		column, ok := userTable.FieldsMap[field.Column]
+		if !ok {
+			return false
+		}
+		// NULL doesn't match any condition
+		if column.NullZero() && column.HasZeroValue(userValue) {
+			return false
+		}
+		if !matchesCondition(column.Value(userValue), field.Op, fv) {
+			return false
+		}
+	}
+	switch f.Activated {
+	case "true":
+		return u.Activated != nil && *u.Activated
+	case "false":
+		return u.Activated != nil && !*u.Activated
+	}
+	return true
+}
+
+func matchesCondition(v reflect.Value, op urlstruct.OpCode, param reflect.Value) bool {
+	if param.Kind() == reflect.Slice {
+		for i := 0; i < param.Len(); i++ {
+			equal := compareValues(v, param.Index(i)) == 0
+			if op == urlstruct.OpEq && equal {
+				return true
+			}
+			if op == urlstruct.OpNotEq && equal {
+				return false
+			}
+		}
+		return op == urlstruct.OpNotEq
+	}
+	switch op {
+	case urlstruct.OpEq:
+		return compareValues(v, param) == 0
+	case urlstruct.OpNotEq:
+		return compareValues(v, param) != 0
+	case urlstruct.OpLT:
+		return compareValues(v, param) < 0
+	case urlstruct.OpLTE:
+		return compareValues(v, param) <= 0
+	case urlstruct.OpGT:
+		return compareValues(v, param) > 0
+	case urlstruct.OpGTE:
+		return compareValues(v, param) >= 0
+	case urlstruct.OpIEq:
+		return likeRegexp(param.String(), true).MatchString(v.String())
+	case urlstruct.OpMatch:
+		return similarToRegexp(param.String()).MatchString(v.String())
+	}
+	return false
+}
+
+func compareValues(a, b reflect.Value) int {
+	for a.Kind() == reflect.Ptr {
+		a = a.Elem()
+	}
+	for b.Kind() == reflect.Ptr {
+		b = b.Elem()
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareInts(a.Int(), b.Int())
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Bool:
+		return compareInts(boolToInt(a.Bool()), boolToInt(b.Bool()))
+	}
+	if t, ok := a.Interface().(time.Time); ok {
+		other := b.Interface().(time.Time)
+		if t.Before(other) {
+			return -1
+		} else if t.After(other) {
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+}
+
+func compareInts(a, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// orderUsers accepts the orders of Query.Order: "column" or "column ASC|DESC", the NULLs are last
+// in the ascending order and first in the descending one.
+func orderUsers(users []*models.User, orders []string) error {
+	type order struct {
+		field *orm.Field
+		desc  bool
+	}
+	parsed := []order{}
+	for _, o := range orders {
+		if o == "" {
+			continue
+		}
+		parts := strings.Fields(o)
+		f, ok := userTable.FieldsMap[strings.Trim(parts[0], `"`)]
+		if !ok || len(parts) > 2 || (len(parts) == 2 && !strings.EqualFold(parts[1], "ASC") && !strings.EqualFold(parts[1], "DESC")) {
+			return fmt.Errorf("unsupported order %q", o)
+		}
+		parsed = append(parsed, order{f, len(parts) == 2 && strings.EqualFold(parts[1], "DESC")})
+	}
+	sort.SliceStable(users, func(i, j int) bool {
+		a := reflect.ValueOf(users[i]).Elem()
+		b := reflect.ValueOf(users[j]).Elem()
+		for _, o := range parsed {
+			aNull := o.field.NullZero() && o.field.HasZeroValue(a)
+			bNull := o.field.NullZero() && o.field.HasZeroValue(b)
+			var cmp int
+			switch {
+			case aNull && bNull:
+				cmp = 0
+			case aNull:
+				cmp = 1
+			case bNull:
+				cmp = -1
+			default:
+				cmp = compareValues(o.field.Value(a), o.field.Value(b))
+			}
+			if o.desc {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+func paginate(users []*models.User, offset, limit int) []*models.User {
+	if offset >= len(users) {
+		return []*models.User{}
+	}
+	users = users[offset:]
+	if limit > 0 && limit < len(users) {
+		users = users[:limit]
+	}
+	return users
+}
+
+// similarToRegexp converts the pattern of SIMILAR TO, which must match the whole string.
+func similarToRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^(?s:")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		case '|', '*', '+', '?', '{', '}', '(', ')', '[', ']':
+			b.WriteRune(r)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString(")$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return regexp.MustCompile(`^\b$`)
+	}
+	return re
+}
+
+func likeRegexp(pattern string, insensitive bool) *regexp.Regexp {
+	var b strings.Builder
+	if insensitive {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^(?s:")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString(")$")
+	return regexp.MustCompile(b.String())
+}
+
+var (
+	unaccentReplacer = strings.NewReplacer("ß", "ss", "æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE", "ø", "o", "Ø", "O", "ł", "l", "Ł", "L", "đ", "d", "Đ", "D")
+	slugQuotes       = regexp.MustCompile(`['"]+`)
+	// the class of the database function is a-z, 0-9 and the range from the backslash to the underscore
+	slugSeparators = regexp.MustCompile(`[^a-z0-9\\\]^_]+`)
+)
+
+// slugify follows the slugify function of the database.
+func slugify(id int, value string) string {
+	value = unaccentReplacer.Replace(value)
+	value = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, norm.NFD.String(value))
+	value = strings.ToLower(value)
+	value = slugQuotes.ReplaceAllString(value, "")
+	value = slugSeparators.ReplaceAllString(value, "-")
+	value = strings.TrimPrefix(strings.TrimRight(value, "-"), "-")
+	return fmt.Sprintf("%d-%s", id, value)
+}
+
+// setDefaults sets the zero values of the columns with the defaults, the insert uses DEFAULT for them.
+func setDefaults(u *models.User) {
+	value := reflect.ValueOf(u).Elem()
+	for _, f := range userTable.DataFields {
+		if f.Default == "" || !f.HasZeroValue(value) {
+			continue
+		}
+		switch f.Default {
+		case "now()":
+			f.Value(value).Set(reflect.ValueOf(time.Now()))
+		case "false":
+			activated := false
+			f.Value(value).Set(reflect.ValueOf(&activated))
+		}
+	}
+}
+
+// copyUser copies the pointers too, so the stored users can't be changed by the callers.
+func copyUser(u *models.User) *models.User {
+	c := *u
+	if u.Activated != nil {
+		activated := *u.Activated
+		c.Activated = &activated
+	}
+	if u.DeletionScheduledAt != nil {
+		t := *u.DeletionScheduledAt
+		c.DeletionScheduledAt = &t
+	}
+	return &c
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"testing"
+
+	"backend/user"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	testContract(t, func(t *testing.T) user.Repository {
+		return NewMemoryUserRepository()
+	})
+}
+
+func TestSlugify(t *testing.T) {
+	require.Equal(t, "1-john-doe", slugify(1, "John Doe"))
+	require.Equal(t, "2-strasse-francois", slugify(2, "  Straße François!"))
+	require.Equal(t, "3-obrien", slugify(3, "O'Brien"))
+	require.Equal(t, "4-john_doe", slugify(4, "-john_doe--"))
+}
@@ -2,12 +2,19 @@ package repository
 
 import (
 	"backend/user"
+	"bytes"
 	"context"
-	"strings"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
 
 	"github.com/sirupsen/logrus"
 
 	_errors "backend/errors"
+	"backend/hasher"
+	"backend/identity"
 	"backend/models"
 	"backend/postgres"
 
@@ -15,12 +22,74 @@ import (
 	"github.com/go-pg/pg/v9/orm"
 )
 
+// migrations add the columns which are missing in the tables created by the previous versions
+const migrations = `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS locale text;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name text;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS bio text;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone text;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar text;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at timestamptz;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_token text;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS login_canonical text;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS email_canonical text;
+`
+
+// canonicalIndexes are created after the canonical identifiers of the existing users are filled in,
+// the tables created by this version already have them as the unique constraints.
+const canonicalIndexes = `
+	CREATE UNIQUE INDEX IF NOT EXISTS users_login_canonical_key ON users (login_canonical);
+	CREATE UNIQUE INDEX IF NOT EXISTS users_email_canonical_key ON users (email_canonical);
+`
+
+const (
+	importUsers = `
+		COPY users (login, password, email, login_canonical, email_canonical, role, activated, activation_token, display_name, bio, locale, timezone)
+		FROM STDIN WITH (FORMAT csv)
+	`
+	exportCSV = `COPY (?) TO STDOUT WITH (FORMAT csv, HEADER)`
+	// the CSV format doesn't escape the backslashes of JSON, the quote and delimiter characters never appear in it
+	exportJSON = `COPY (SELECT row_to_json(u) FROM (?) u) TO STDOUT WITH (FORMAT csv, QUOTE E'\x01', DELIMITER E'\x02')`
+
+	lockIdentity = `SELECT pg_advisory_xact_lock(?, hashtext(?))`
+	// identitiesLockKey is the first key of the advisory locks of the canonical identities, so they
+	// don't collide with the other advisory locks.
+	identitiesLockKey = 0x75736572
+)
+
+// exportFields are named like the fields of the imported files. The passwords and tokens aren't exported,
+// so the exported files can be imported again only with an added password or passwordHash column.
+var exportFields = []struct {
+	name string
+	expr string
+}{
+	{"id", "id"},
+	{"slug", "slug"},
+	{"login", "login"},
+	{"email", "email"},
+	{"role", "role"},
+	{"activated", "COALESCE(activated, false)"},
+	{"displayName", "COALESCE(display_name, '')"},
+	{"bio", "COALESCE(bio, '')"},
+	{"locale", "COALESCE(locale, '')"},
+	{"timezone", "COALESCE(timezone, '')"},
+	{"createdAt", "created_at"},
+	{"updatedAt", "updated_at"},
+}
+
+var constraintErrors = postgres.NewErrorRegistry().
+	Unique("users_login_key", _errors.ErrLoginMustBeUnique, "login").
+	Unique("users_login_canonical_key", _errors.ErrLoginMustBeUnique, "login").
+	Unique("users_email_key", _errors.ErrEmailMustBeUnique, "email").
+	Unique("users_email_canonical_key", _errors.ErrEmailMustBeUnique, "email")
+
 type postgreRepository struct {
 	postgres.DB
-	logrus *logrus.Entry
+	timeouts postgres.Timeouts
+	logrus   *logrus.Entry
 }
 
-func NewPostgreUserRepository(conn postgres.DB) (user.Repository, error) {
+func NewPostgreUserRepository(conn postgres.DB, timeouts postgres.Timeouts) (user.Repository, error) {
 	log := logrus.WithField("package", "user/repository")
 	if err := conn.CreateTable((*models.User)(nil), &orm.CreateTableOptions{
 		IfNotExists: true,
@@ -28,40 +97,70 @@ func NewPostgreUserRepository(conn postgres.DB) (user.Repository, error) {
 		log.Debugf("Cannot create user table: %s", err.Error())
 		return nil, err
 	}
+	if _, err := conn.Exec(migrations); err != nil {
+		log.Debugf("Cannot migrate user table: %s", err.Error())
+		return nil, err
+	}
+	if err := canonicalizeUsers(conn); err != nil {
+		log.Debugf("Cannot canonicalize the users: %s", err.Error())
+		return nil, err
+	}
+	if _, err := conn.Exec(canonicalIndexes); err != nil {
+		log.Errorf("Cannot create the canonical indexes, the users with the same canonical login or email must be renamed: %s", err.Error())
+		return nil, err
+	}
 	return &postgreRepository{conn,
+		timeouts,
 		log,
 	}, nil
 }
 
+// canonicalizeUsers fills in the canonical identifiers of the users created by the previous versions.
+func canonicalizeUsers(conn postgres.DB) error {
+	users := []*models.User{}
+	if err := conn.
+		Model(&users).
+		Column("id", "login", "email").
+		Where("login_canonical IS NULL").
+		Select(); err != nil && err != pg.ErrNoRows {
+		return err
+	}
+	for _, u := range users {
+		if _, err := conn.
+			Model(u).
+			Column("login_canonical", "email_canonical").
+			WherePK().
+			Update(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (repo *postgreRepository) Fetch(ctx context.Context, f *models.UserFilter) (models.UserList, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.Fetch")
+	defer cancel()
 	var err error
 	users := []*models.User{}
 	pagination := models.UserList{}
-	query := repo.Model(&users)
+	query := postgres.ReadConn(ctx, repo.DB).ModelContext(ctx, &users)
 	log := repo.logrus.WithField("filter", f)
 	log.Debug("Fetch")
 
 	if f != nil {
-		query = query.
-			WhereStruct(f).
+		query = whereUserFilter(query, f).
 			Limit(f.Limit).
 			Offset(f.Offset)
 
 		if len(f.Order) > 0 {
 			query = query.Order(f.Order...)
 		}
-
-		if f.Activated == "true" {
-			query = query.Where("activated = true")
-		} else if f.Activated == "false" {
-			query = query.Where("activated = false")
-		}
 	}
 
 	if pagination.Total, err = query.
 		SelectAndCount(); err != nil && err != pg.ErrNoRows {
 		log.Debugf("Fetch err: %s", err.Error())
-		return pagination, _errors.Wrap(_errors.ErrInternalServerError, err)
+		return pagination, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
 	}
 	pagination.Items = users
 
@@ -69,129 +168,410 @@ func (repo *postgreRepository) Fetch(ctx context.Context, f *models.UserFilter)
 }
 
 func (repo *postgreRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.GetByID")
+	defer cancel()
 	user := &models.User{
 		ID: id,
 	}
 	log := repo.logrus.WithField("id", id)
 	log.Debug("GetByID")
-	if err := repo.Select(user); err != nil {
+	if err := postgres.ReadConn(ctx, repo.DB).ModelContext(ctx, user).WherePK().Select(); err != nil {
 		log.Debugf("GetByID err: %s", err.Error())
-		return user, _errors.Wrap(_errors.ErrUserNotFound, err)
+		return user, postgres.WrapNoRows(ctx, err, _errors.ErrUserNotFound)
 	}
 	return user, nil
 }
 
 func (repo *postgreRepository) GetBySlug(ctx context.Context, slug string) (*models.User, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.GetBySlug")
+	defer cancel()
 	user := &models.User{}
 	log := repo.logrus.WithField("slug", slug)
 	log.Debug("GetBySlug")
-	if err := repo.
-		Model(user).
+	if err := postgres.ReadConn(ctx, repo.DB).
+		ModelContext(ctx, user).
 		Where("slug = ?", slug).
 		Limit(1).
 		Select(); err != nil {
 		log.Debugf("GetBySlug err: %s", err.Error())
-		return user, _errors.Wrap(_errors.ErrUserNotFound, err)
+		return user, postgres.WrapNoRows(ctx, err, _errors.ErrUserNotFound)
 	}
 	return user, nil
 }
 
+func (repo *postgreRepository) GetByIDs(ctx context.Context, ids []int) ([]*models.User, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.GetByIDs")
+	defer cancel()
+	users := []*models.User{}
+	log := repo.logrus.WithField("ids", ids)
+	log.Debug("GetByIDs")
+	if len(ids) == 0 {
+		return users, nil
+	}
+	if err := postgres.ReadConn(ctx, repo.DB).
+		ModelContext(ctx, &users).
+		Where("id IN (?)", pg.In(ids)).
+		Select(); err != nil && err != pg.ErrNoRows {
+		log.Debugf("GetByIDs err: %s", err.Error())
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return users, nil
+}
+
+func (repo *postgreRepository) GetBySlugs(ctx context.Context, slugs []string) ([]*models.User, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.GetBySlugs")
+	defer cancel()
+	users := []*models.User{}
+	log := repo.logrus.WithField("slugs", slugs)
+	log.Debug("GetBySlugs")
+	if len(slugs) == 0 {
+		return users, nil
+	}
+	if err := postgres.ReadConn(ctx, repo.DB).
+		ModelContext(ctx, &users).
+		Where("slug IN (?)", pg.In(slugs)).
+		Select(); err != nil && err != pg.ErrNoRows {
+		log.Debugf("GetBySlugs err: %s", err.Error())
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return users, nil
+}
+
 func (repo *postgreRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.GetByEmail")
+	defer cancel()
 	user := &models.User{}
 	log := repo.logrus.WithField("email", email)
 	log.Debug("GetByEmail")
-	if err := repo.
-		Model(user).
-		Where("email = ?", email).
+	if err := postgres.ReadConn(ctx, repo.DB).
+		ModelContext(ctx, user).
+		Where("email_canonical = ?", identity.Canonical(email)).
 		Limit(1).
 		Select(); err != nil {
 		log.Debugf("GetByEmail err: %s", err.Error())
-		return user, _errors.Wrap(_errors.ErrUserNotFound, err)
+		return user, postgres.WrapNoRows(ctx, err, _errors.ErrUserNotFound)
 	}
 	return user, nil
 }
 
-//we assume that the password is hashed
+// GetByCredentials finds the user by the email when the identifier contains @ and by the login otherwise,
+// compares the plaintext password with the hash and rehashes it when the hash was created with other parameters
+// or algorithm than the current ones.
 func (repo *postgreRepository) GetByCredentials(ctx context.Context, login, password string) (*models.User, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.GetByCredentials")
+	defer cancel()
 	u := &models.User{}
-	log := repo.logrus.WithField("login", login).WithField("password", password)
+	log := repo.logrus.WithField("login", login)
 	log.Debug("GetByCredentials")
-	if err := repo.
-		Model(u).
-		Where("login = ?", login).
+	column := "login_canonical"
+	if identity.IsEmail(login) {
+		column = "email_canonical"
+	}
+	if err := postgres.ReadConn(ctx, repo.DB).
+		ModelContext(ctx, u).
+		Where("? = ?", pg.Ident(column), identity.Canonical(login)).
 		Limit(1).
 		Select(); err != nil {
 		log.Debugf("GetByCredentials err: %s", err.Error())
-		return u, _errors.Wrap(_errors.ErrInvalidCredentials, err)
+		return u, postgres.WrapNoRows(ctx, err, _errors.ErrInvalidCredentials)
 	}
 	if err := u.CompareHashAndPassword(password); err != nil {
 		log.Debugf("GetByCredentials err: %s", err.Error())
 		return nil, err
 	}
+	if hasher.Default().NeedsRehash(u.Password) {
+		repo.rehash(ctx, u, password)
+	}
 	return u, nil
 }
 
+// rehash doesn't fail the sign in, the password is rehashed on the next one.
+func (repo *postgreRepository) rehash(ctx context.Context, u *models.User, password string) {
+	log := repo.logrus.WithField("id", u.ID)
+	hash, err := hasher.Default().Hash(password)
+	if err != nil {
+		log.Errorf("Cannot rehash the password: %s", err.Error())
+		return
+	}
+	if _, err := postgres.Conn(ctx, repo.DB).
+		ModelContext(ctx, &models.User{ID: u.ID, Password: hash}).
+		Column("password").
+		WherePK().
+		Update(); err != nil {
+		log.Errorf("Cannot rehash the password: %s", err.Error())
+		return
+	}
+	u.Password = hash
+	reportRehash(ctx)
+}
+
 func (repo *postgreRepository) Update(ctx context.Context, u *models.User) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.Update")
+	defer cancel()
 	log := repo.logrus.WithField("user", u)
 	log.Debug("Update")
-	if _, err := repo.
-		Model(u).
-		WherePK().
-		Returning("*").
-		UpdateNotZero(); err != nil {
-		log.Debugf("Update err: %s", err.Error())
-		if err == pg.ErrNoRows {
-			return _errors.Wrap(_errors.ErrUserNotFound, err)
-		}
+	return repo.withIdentities(ctx, u, u.Login != "", u.Email != "", func(ctx context.Context) error {
+		if _, err := postgres.Conn(ctx, repo.DB).
+			ModelContext(ctx, u).
+			WherePK().
+			Returning("*").
+			UpdateNotZero(); err != nil {
+			log.Debugf("Update err: %s", err.Error())
+			if err == pg.ErrNoRows {
+				return _errors.Wrap(_errors.ErrUserNotFound, err)
+			}
 
-		if strings.Contains(err.Error(), "login") {
-			return _errors.Wrap(_errors.ErrLoginMustBeUnique, err)
-		} else if strings.Contains(err.Error(), "email") {
-			return _errors.Wrap(_errors.ErrEmailMustBeUnique, err)
+			return constraintErrors.Translate(ctx, err, _errors.ErrInternalServerError)
 		}
+		return nil
+	})
+}
 
-		return _errors.Wrap(_errors.ErrInternalServerError, err)
+// UpdateColumns updates only the given columns, so they can be set to zero values.
+func (repo *postgreRepository) UpdateColumns(ctx context.Context, u *models.User, columns ...string) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.UpdateColumns")
+	defer cancel()
+	log := repo.logrus.WithField("user", u).WithField("columns", columns)
+	log.Debug("UpdateColumns")
+	columns = append(columns, "updated_at")
+	login, email := false, false
+	for _, column := range columns {
+		if column == "login" || column == "email" {
+			columns = append(columns, column+"_canonical")
+			login, email = login || column == "login", email || column == "email"
+		}
 	}
-	return nil
+	return repo.withIdentities(ctx, u, login, email, func(ctx context.Context) error {
+		if _, err := postgres.Conn(ctx, repo.DB).
+			ModelContext(ctx, u).
+			Column(columns...).
+			WherePK().
+			Returning("*").
+			Update(); err != nil {
+			log.Debugf("UpdateColumns err: %s", err.Error())
+			if err == pg.ErrNoRows {
+				return _errors.Wrap(_errors.ErrUserNotFound, err)
+			}
+
+			return constraintErrors.Translate(ctx, err, _errors.ErrInternalServerError)
+		}
+		return nil
+	})
 }
 
 func (repo *postgreRepository) Store(ctx context.Context, u *models.User) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.Store")
+	defer cancel()
 	log := repo.logrus.WithField("user", u)
 	log.Debug("Store")
-	if _, err := repo.Model(u).Insert(); err != nil {
-		log.Debugf("Store err: %s", err.Error())
-		if strings.Contains(err.Error(), "login") {
-			return _errors.Wrap(_errors.ErrLoginMustBeUnique, err)
-		} else if strings.Contains(err.Error(), "email") {
-			return _errors.Wrap(_errors.ErrEmailMustBeUnique, err)
+	return repo.withIdentities(ctx, u, true, true, func(ctx context.Context) error {
+		if _, err := postgres.Conn(ctx, repo.DB).ModelContext(ctx, u).Insert(); err != nil {
+			log.Debugf("Store err: %s", err.Error())
+			return constraintErrors.Translate(ctx, err, _errors.ErrInternalServerError)
+		}
+		return nil
+	})
+}
+
+// withIdentities runs the write of the login or the email after checkIdentities in one transaction,
+// the transaction carried by ctx or a new one, which holds the locks taken by the check.
+func (repo *postgreRepository) withIdentities(ctx context.Context, u *models.User, login, email bool, write func(ctx context.Context) error) error {
+	fn := func(ctx context.Context) error {
+		if err := repo.checkIdentities(ctx, u, login, email); err != nil {
+			return err
 		}
+		return write(ctx)
+	}
+	// the repository built on a transaction runs in it, its RunInTransaction would commit it
+	if _, ok := repo.DB.(*pg.Tx); ok || !(login || email) || postgres.InTx(ctx) {
+		return fn(ctx)
+	}
+	return postgres.NewTransactor(repo.DB).WithinTx(ctx, fn)
+}
 
-		return _errors.Wrap(_errors.ErrInternalServerError, err)
+// checkIdentities rejects the login which is the email of another user and the email which is the login
+// of another user, the unique constraints compare only the same columns. The logins with @ are rejected
+// by the validation, but the users created by the previous versions can have them.
+// The canonical values are locked until the transaction ends, so the concurrent writes of the same
+// value to the login of one user and the email of another are serialized and the second one fails.
+func (repo *postgreRepository) checkIdentities(ctx context.Context, u *models.User, login, email bool) error {
+	values := []string{}
+	if login && u.Login != "" {
+		values = append(values, identity.Canonical(u.Login))
+	}
+	if email && u.Email != "" {
+		values = append(values, identity.Canonical(u.Email))
+	}
+	// the same order in every transaction, so two of them can't wait for each other
+	sort.Strings(values)
+	for _, value := range values {
+		if _, err := postgres.TxConn(ctx, repo.DB).ExecContext(ctx, lockIdentity, identitiesLockKey, value); err != nil {
+			repo.logrus.Debugf("checkIdentities err: %s", err.Error())
+			return postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+		}
+	}
+	checks := []struct {
+		check  bool
+		column string
+		value  string
+		code   string
+		field  string
+	}{
+		{login && u.Login != "", "email_canonical", u.Login, _errors.ErrLoginMustBeUnique, "login"},
+		{email && u.Email != "", "login_canonical", u.Email, _errors.ErrEmailMustBeUnique, "email"},
+	}
+	for _, c := range checks {
+		if !c.check {
+			continue
+		}
+		exists, err := postgres.TxConn(ctx, repo.DB).
+			ModelContext(ctx, (*models.User)(nil)).
+			Where("? = ?", pg.Ident(c.column), identity.Canonical(c.value)).
+			Where("id != ?", u.ID).
+			Exists()
+		if err != nil {
+			repo.logrus.Debugf("checkIdentities err: %s", err.Error())
+			return _errors.Wrap(_errors.ErrInternalServerError, err)
+		}
+		if exists {
+			return _errors.WrapField(c.code, c.field, nil, fmt.Errorf("%s is the %s of another user", c.field, c.column))
+		}
 	}
 	return nil
 }
 
 func (repo *postgreRepository) Delete(ctx context.Context, f *models.UserFilter) ([]*models.User, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.Delete")
+	defer cancel()
 	users := []*models.User{}
-	query := repo.Model(&users)
+	query := postgres.Conn(ctx, repo.DB).ModelContext(ctx, &users)
 	log := repo.logrus.WithField("filter", f)
 	log.Debug("Delete")
 	if f != nil {
-		query = query.
-			WhereStruct(f)
-
-		if f.Activated == "true" {
-			query = query.Where("activated = true")
-		} else if f.Activated == "false" {
-			query = query.Where("activated = false")
-		}
+		query = whereUserFilter(query, f)
 	}
 	_, err := query.
 		Returning("*").
 		Delete()
 	if err != nil && err != pg.ErrNoRows {
 		log.Debugf("Delete err: %s", err.Error())
-		return nil, _errors.Wrap(_errors.ErrInternalServerError, err)
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
 	}
 	return users, err
 }
+
+func (repo *postgreRepository) GetByIdentities(ctx context.Context, logins, emails []string) ([]*models.User, error) {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.GetByIdentities")
+	defer cancel()
+	users := []*models.User{}
+	log := repo.logrus.WithField("logins", len(logins)).WithField("emails", len(emails))
+	log.Debug("GetByIdentities")
+	if len(logins) == 0 && len(emails) == 0 {
+		return users, nil
+	}
+	query := postgres.ReadConn(ctx, repo.DB).ModelContext(ctx, &users)
+	if len(logins) > 0 {
+		query = query.WhereOr("login_canonical IN (?)", pg.In(canonicalize(logins)))
+	}
+	if len(emails) > 0 {
+		query = query.WhereOr("email_canonical IN (?)", pg.In(canonicalize(emails)))
+	}
+	if err := query.Select(); err != nil && err != pg.ErrNoRows {
+		log.Debugf("GetByIdentities err: %s", err.Error())
+		return nil, postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return users, nil
+}
+
+// Import copies the users in a single statement, so a violated constraint rejects all of them.
+// The usecase calls it with the chunks of the file in one transaction. COPY doesn't take a context,
+// the timeout is its statement_timeout.
+func (repo *postgreRepository) Import(ctx context.Context, users []*models.User) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.Import")
+	defer cancel()
+	log := repo.logrus.WithField("users", len(users))
+	log.Debug("Import")
+	if len(users) == 0 {
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	for _, u := range users {
+		activated := u.Activated != nil && *u.Activated
+		// the unquoted empty values are NULLs, like the zero values inserted by go-pg
+		w.Write([]string{
+			u.Login,
+			u.Password,
+			u.Email,
+			u.LoginCanonical,
+			u.EmailCanonical,
+			strconv.Itoa(u.Role),
+			strconv.FormatBool(activated),
+			u.ActivationToken,
+			u.DisplayName,
+			u.Bio,
+			u.Locale,
+			u.Timezone,
+		})
+	}
+	w.Flush()
+	if err := postgres.WithStatementTimeout(ctx, postgres.Conn(ctx, repo.DB), func(conn postgres.DB) error {
+		_, err := conn.CopyFrom(buf, importUsers)
+		return err
+	}); err != nil {
+		log.Debugf("Import err: %s", err.Error())
+		return constraintErrors.Translate(ctx, err, _errors.ErrInternalServerError)
+	}
+	return nil
+}
+
+// Export streams the users with COPY, the timeout is its statement_timeout.
+func (repo *postgreRepository) Export(ctx context.Context, f *models.UserFilter, format models.UserFileFormat, w io.Writer) error {
+	ctx, cancel := repo.timeouts.WithTimeout(ctx, "user.Export")
+	defer cancel()
+	log := repo.logrus.WithField("filter", f).WithField("format", format)
+	log.Debug("Export")
+	conn := postgres.ReadConn(ctx, repo.DB)
+	query := conn.ModelContext(ctx, (*models.User)(nil))
+	for _, field := range exportFields {
+		query = query.ColumnExpr("? AS ?", pg.SafeQuery(field.expr), pg.Ident(field.name))
+	}
+	if f != nil {
+		query = whereUserFilter(query, f).
+			Limit(f.Limit).
+			Offset(f.Offset).
+			Order(f.Order...)
+	}
+	query = query.Order("id")
+	copyQuery := exportCSV
+	if format == models.UserFileFormatJSON {
+		copyQuery = exportJSON
+	}
+	if err := postgres.WithStatementTimeout(ctx, conn, func(conn postgres.DB) error {
+		_, err := conn.CopyTo(w, copyQuery, query)
+		return err
+	}); err != nil {
+		log.Debugf("Export err: %s", err.Error())
+		return postgres.WrapError(ctx, err, _errors.ErrInternalServerError)
+	}
+	return nil
+}
+
+// whereUserFilter applies the conditions of the filter, without the order and the pagination.
+func whereUserFilter(query *orm.Query, f *models.UserFilter) *orm.Query {
+	query = query.WhereStruct(f)
+	if f.Activated == "true" {
+		query = query.Where("activated = true")
+	} else if f.Activated == "false" {
+		query = query.Where("activated = false")
+	}
+	return query
+}
+
+func canonicalize(identities []string) []string {
+	canonical := make([]string, len(identities))
+	for i, s := range identities {
+		canonical[i] = identity.Canonical(s)
+	}
+	return canonical
+}
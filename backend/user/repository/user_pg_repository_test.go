@@ -2,16 +2,20 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"backend/user"
 
 	_errors "backend/errors"
 	"backend/models"
+	"backend/postgres"
 	"backend/utils"
 	"backend/utils/seed"
+	"backend/utils/testutil"
 
 	"github.com/go-pg/urlstruct"
 	"github.com/stretchr/testify/require"
@@ -24,7 +28,7 @@ func TestPgRepository(t *testing.T) {
 	defer tx.Rollback()
 	defer conn.Close()
 	require.Equal(t, nil, err)
-	repo, err := NewPostgreUserRepository(tx)
+	repo, err := NewPostgreUserRepository(tx, postgres.Timeouts{})
 	require.Equal(t, nil, err)
 	seedUsers := seed.Users(5)
 	err = seedDatabase(repo)
@@ -93,6 +97,26 @@ func TestPgRepository(t *testing.T) {
 		})
 	})
 
+	t.Run("GetByIDs", func(t *testing.T) {
+		users, err := repo.GetByIDs(context.Background(), []int{
+			seedUsers[0].ID,
+			seedUsers[1].ID,
+			seedUsers[len(seedUsers)-1].ID + 1,
+		})
+		require.Equal(t, nil, err)
+		require.Equal(t, 2, len(users))
+	})
+
+	t.Run("GetBySlugs", func(t *testing.T) {
+		users, err := repo.GetBySlugs(context.Background(), []string{
+			seedUsers[0].Slug,
+			seedUsers[1].Slug,
+			seedUsers[0].Slug + "asdf123",
+		})
+		require.Equal(t, nil, err)
+		require.Equal(t, 2, len(users))
+	})
+
 	t.Run("GetByEmail", func(t *testing.T) {
 		t.Run("User not found in database", func(t *testing.T) {
 			_, err := repo.GetByEmail(context.Background(), seedUsers[0].Email+"asdf123")
@@ -123,15 +147,22 @@ func TestPgRepository(t *testing.T) {
 			require.Equal(t, seedUsers[0].Role, user.Role)
 			require.Equal(t, seedUsers[0].Activated, user.Activated)
 		})
+
+		t.Run("Email in other case", func(t *testing.T) {
+			user, err := repo.GetByCredentials(context.Background(), strings.ToUpper(seedUsers[0].Email), seedUsers[0].Password)
+			require.Equal(t, nil, err)
+			require.Equal(t, seedUsers[0].Login, user.Login)
+		})
 	})
 
 	t.Run("Store", func(t *testing.T) {
+		activated := true
 		newUser := models.User{
 			Login:     "NewUser",
 			Password:  "Password123",
 			Email:     "newUserEmail@gmail.com",
 			Role:      models.UserDefaultRole,
-			Activated: true,
+			Activated: &activated,
 			Slug:      "NewUser2-slug",
 		}
 
@@ -140,6 +171,16 @@ func TestPgRepository(t *testing.T) {
 			require.Equal(t, nil, err)
 			seedUsers = append(seedUsers, newUser)
 		})
+
+		t.Run("Login differs only in case", func(t *testing.T) {
+			u := newUser
+			u.ID = 0
+			u.Login = "newuser"
+			u.Email = "otherEmail@gmail.com"
+			u.Slug = "newuser3-slug"
+			err := repo.Store(context.Background(), &u)
+			require.Equal(t, true, strings.Contains(err.Error(), _errors.ErrLoginMustBeUnique))
+		})
 	})
 
 	t.Run("Update", func(t *testing.T) {
@@ -178,6 +219,24 @@ func TestPgRepository(t *testing.T) {
 	})
 }
 
+func TestPgRepositoryContract(t *testing.T) {
+	conn := utils.ConnectToPostgreTestDB(false)
+	defer conn.Close()
+	repo, err := NewPostgreUserRepository(conn, postgres.Timeouts{})
+	require.Equal(t, nil, err)
+	require.Equal(t, nil, postgres.LoadFunctionsAndTriggers(conn))
+	// not a transaction, the violated constraints would abort it
+	deleteContractUsers := func() {
+		_, err := conn.Exec(`DELETE FROM users WHERE login_canonical LIKE 'contract%'`)
+		require.Equal(t, nil, err)
+	}
+	testContract(t, func(t *testing.T) user.Repository {
+		deleteContractUsers()
+		t.Cleanup(deleteContractUsers)
+		return repo
+	})
+}
+
 func seedDatabase(repo user.Repository) error {
 	errgrp, ctx := errgroup.WithContext(context.Background())
 	for _, user := range seed.Users(5) {
@@ -188,3 +247,142 @@ func seedDatabase(repo user.Repository) error {
 	}
 	return errgrp.Wait()
 }
+
+func TestPgRepositoryConcurrentIdentities(t *testing.T) {
+	conn := utils.ConnectToPostgreTestDB(false)
+	defer conn.Close()
+	// not a transaction, the stores run in their own transactions on other connections of the pool
+	repo, err := NewPostgreUserRepository(conn, postgres.Timeouts{})
+	require.Equal(t, nil, err)
+	ctx := context.Background()
+	stored := []int{}
+	defer func() {
+		_, err := repo.Delete(ctx, &models.UserFilter{ID: stored})
+		require.Equal(t, nil, err)
+	}()
+
+	activated := true
+	for i := 0; i < 20; i++ {
+		// the login of one user is the email of the other
+		shared := fmt.Sprintf("race%d@example.com", i)
+		users := []*models.User{
+			{Login: shared, Email: fmt.Sprintf("login%d@example.com", i), Slug: fmt.Sprintf("race-login-%d", i)},
+			{Login: fmt.Sprintf("race-email-%d", i), Email: shared, Slug: fmt.Sprintf("race-email-%d", i)},
+		}
+		var g errgroup.Group
+		errs := make([]error, len(users))
+		for j, u := range users {
+			j, u := j, u
+			u.Password = "Password123"
+			u.Role = models.UserDefaultRole
+			u.Activated = &activated
+			g.Go(func() error {
+				errs[j] = repo.Store(ctx, u)
+				return nil
+			})
+		}
+		g.Wait()
+		failed := 0
+		for j, err := range errs {
+			if err != nil {
+				failed++
+			} else {
+				stored = append(stored, users[j].ID)
+			}
+		}
+		require.Equal(t, 1, failed, shared)
+	}
+}
+
+// TestPgRepositoryConstraints raises every registered violation, so the names of the constraints
+// are checked against the schema.
+func TestPgRepositoryConstraints(t *testing.T) {
+	conn := utils.ConnectToPostgreTestDB(false)
+	defer conn.Close()
+	ctx := context.Background()
+	activated := true
+	newUser := func(login, email string) *models.User {
+		return &models.User{
+			Login:     login,
+			Password:  "Password123",
+			Email:     email,
+			Role:      models.UserDefaultRole,
+			Activated: &activated,
+			// the slug is checked first, it must not be the violated constraint
+			Slug: login + "-" + email,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		constraint string
+		code       string
+		field      string
+	}{
+		{"login", newUser("constraint", "other@example.com"), "users_login_key", _errors.ErrLoginMustBeUnique, "login"},
+		{"canonical login", newUser("CONSTRAINT", "other@example.com"), "users_login_canonical_key", _errors.ErrLoginMustBeUnique, "login"},
+		{"email", newUser("other", "constraint@example.com"), "users_email_key", _errors.ErrEmailMustBeUnique, "email"},
+		{"canonical email", newUser("other", "CONSTRAINT@example.com"), "users_email_canonical_key", _errors.ErrEmailMustBeUnique, "email"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the failed statement aborts the transaction, every violation has its own
+			tx, err := conn.Begin()
+			require.Equal(t, nil, err)
+			defer tx.Rollback()
+			repo, err := NewPostgreUserRepository(tx, postgres.Timeouts{})
+			require.Equal(t, nil, err)
+			require.Equal(t, nil, repo.Store(ctx, newUser("constraint", "constraint@example.com")))
+
+			err = repo.Store(ctx, test.user)
+			require.Equal(t, test.constraint, testutil.Violation(err))
+			gqlErr := _errors.ToGqlError(err)
+			require.Equal(t, test.code, _errors.Code(gqlErr))
+			require.Equal(t, test.field, gqlErr.Extensions["field"])
+		})
+	}
+}
+
+func TestPgRepositoryTimeouts(t *testing.T) {
+	conn := utils.ConnectToPostgreTestDB(false)
+	defer conn.Close()
+	timeouts := postgres.Timeouts{
+		Default: time.Minute,
+		Operations: map[string]time.Duration{
+			"user.fetch":   100 * time.Millisecond,
+			"user.getbyid": 100 * time.Millisecond,
+		},
+	}
+	repo, err := NewPostgreUserRepository(conn, timeouts)
+	require.Equal(t, nil, err)
+	// the queries of the repository wait for the lock until they are canceled
+	lock, err := conn.Begin()
+	require.Equal(t, nil, err)
+	defer lock.Rollback()
+	_, err = lock.Exec("LOCK TABLE users IN ACCESS EXCLUSIVE MODE")
+	require.Equal(t, nil, err)
+
+	t.Run("Fetch exceeds the timeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := repo.Fetch(context.Background(), &models.UserFilter{})
+		require.True(t, time.Since(start) < 5*time.Second)
+		require.Equal(t, _errors.ErrTimeout, _errors.Code(_errors.ToGqlError(err)))
+	})
+
+	t.Run("GetByID exceeds the timeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := repo.GetByID(context.Background(), 1)
+		require.True(t, time.Since(start) < 5*time.Second)
+		require.Equal(t, _errors.ErrTimeout, _errors.Code(_errors.ToGqlError(err)))
+	})
+
+	t.Run("Fetch stops when the request is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := repo.Fetch(ctx, &models.UserFilter{})
+		require.True(t, time.Since(start) < 5*time.Second)
+		require.NotEqual(t, nil, err)
+	})
+}
@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"io"
 
 	"backend/models"
 )
@@ -10,7 +11,20 @@ type Usecase interface {
 	Fetch(ctx context.Context, f *models.UserFilter) (models.UserList, error)
 	GetByID(ctx context.Context, id int) (*models.User, error)
 	GetBySlug(ctx context.Context, slug string) (*models.User, error)
+	GetByIDs(ctx context.Context, ids []int) ([]*models.User, error)
+	GetBySlugs(ctx context.Context, slugs []string) ([]*models.User, error)
 	Update(ctx context.Context, id int, input models.UserInput) (*models.User, error)
+	UpdateProfile(ctx context.Context, id int, input models.ProfileInput) (*models.User, error)
+	// UploadAvatar resizes the image and replaces the previous avatar, size is the declared size of the file.
+	UploadAvatar(ctx context.Context, id int, file io.Reader, size int64) (*models.User, error)
 	Store(ctx context.Context, input models.UserInput) (*models.User, error)
 	Delete(ctx context.Context, ids ...int) ([]*models.User, error)
+	// CheckPasswordStrength estimates the strength of the password and checks it against the password policy,
+	// login and email are optional.
+	CheckPasswordStrength(ctx context.Context, password, login, email string) (*models.PasswordStrength, error)
+	// Import validates every row of the file and imports the users only if no row has errors,
+	// the returned error means that the file cannot be read.
+	Import(ctx context.Context, r io.Reader, format models.UserFileFormat, dryRun bool) (*models.UserImport, error)
+	// Export writes the users matched by the filter to w, the filter isn't limited.
+	Export(ctx context.Context, f *models.UserFilter, format models.UserFileFormat, w io.Writer) error
 }
@@ -0,0 +1,419 @@
+package usecase
+
+import (
+	"backend/breach"
+	_errors "backend/errors"
+	"backend/hasher"
+	"backend/identity"
+	"backend/models"
+	"backend/user/validation"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"golang.org/x/sync/errgroup"
+)
+
+// importFields are the fields of the imported files. The plain password is validated by the password policy,
+// passwordHash is a bcrypt or Argon2id hash from the previous system which is upgraded when the user signs in.
+var importFields = []string{"login", "email", "password", "passwordHash", "role", "activated", "displayName", "bio", "locale", "timezone"}
+
+// exportedFields are ignored, so the exported files can be imported.
+var exportedFields = []string{"id", "slug", "createdAt", "updatedAt"}
+
+// importChunkSize is the number of the rows which are checked, hashed and inserted at once, the chunks
+// of a file are inserted in one transaction. It is a variable for the tests.
+var importChunkSize = 1000
+
+// errImportRejected rolls back the chunks inserted before a row failed.
+var errImportRejected = errors.New("the import is rejected")
+
+// importRow maps the import fields to their values, the missing values are empty.
+type importRow map[string]string
+
+// importedUser is a valid row of the imported file.
+type importedUser struct {
+	*models.User
+	row int
+	// hashed is set when the password is the passwordHash field, the plain passwords are hashed before the insert
+	hashed bool
+}
+
+func (ucase *usecase) Import(ctx context.Context, r io.Reader, format models.UserFileFormat, dryRun bool) (*models.UserImport, error) {
+	entry := ucase.logrus.WithField("format", format).WithField("dryRun", dryRun)
+	entry.Debug("Import")
+	var next func() (importRow, error)
+	var err error
+	if format == models.UserFileFormatJSON {
+		next = jsonRows(r)
+	} else if next, err = csvRows(r); err != nil {
+		entry.Debugf("Import - Cannot read the header: %s", err.Error())
+		return nil, err
+	}
+
+	result := &models.UserImport{DryRun: dryRun, Errors: []*models.UserImportError{}}
+	chunkSize := importChunkSize
+	if ucase.transactor == nil {
+		// the chunks couldn't be rolled back
+		chunkSize = 0
+	}
+	seen := importedIdentities{map[string]bool{}, map[string]bool{}}
+	valid := 0
+	err = ucase.withinTx(ctx, func(ctx context.Context) error {
+		users := []importedUser{}
+		// flush inserts the chunk until a row fails, the next chunks are only checked to report all errors
+		flush := func() error {
+			if err := ucase.checkImportedIdentities(ctx, result, seen, users); err != nil {
+				return err
+			}
+			valid += len(users)
+			if len(result.Errors) == 0 && !dryRun {
+				if err := ucase.importUsers(ctx, users); err != nil {
+					return err
+				}
+			}
+			users = users[:0]
+			return nil
+		}
+		for row := 1; ; row++ {
+			values, err := next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				entry.Debugf("Import - Cannot read row %d: %s", row, err.Error())
+				if _, ok := err.(gqlerror.List); ok {
+					addImportErrors(result, row, err)
+					continue
+				}
+				return _errors.WrapWithParams(_errors.ErrImportFile, _errors.Params{"row": row}, err)
+			}
+			u, err := ucase.parseRow(ctx, row, values)
+			if err != nil {
+				addImportErrors(result, row, err)
+				continue
+			}
+			if users = append(users, u); len(users) == chunkSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if len(result.Errors) > 0 {
+			return errImportRejected
+		}
+		return nil
+	})
+	if err == errImportRejected {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+	result.Imported = valid
+	if !dryRun {
+		entry.Infof("Imported %d users", valid)
+	}
+	return result, nil
+}
+
+// importUsers hashes the passwords of the chunk and inserts it.
+func (ucase *usecase) importUsers(ctx context.Context, users []importedUser) error {
+	if err := hashPasswords(ctx, users); err != nil {
+		return _errors.Wrap(_errors.ErrInternalServerError, err)
+	}
+	imported := make([]*models.User, len(users))
+	for i := range users {
+		imported[i] = users[i].User
+	}
+	return ucase.userRepo.Import(ctx, imported)
+}
+
+func (ucase *usecase) Export(ctx context.Context, f *models.UserFilter, format models.UserFileFormat, w io.Writer) error {
+	ucase.logrus.WithField("filter", f).WithField("format", format).Debug("Export")
+	return ucase.userRepo.Export(ctx, f, format, w)
+}
+
+// parseRow validates the row like Store, the password of the returned user isn't hashed yet.
+// The users which aren't activated get an activation token, they can request the activation email
+// after they sign in.
+func (ucase *usecase) parseRow(ctx context.Context, row int, values importRow) (importedUser, error) {
+	var errs gqlerror.List
+	u := &models.User{
+		Login:       values["login"],
+		Email:       values["email"],
+		Password:    values["password"],
+		Role:        models.UserDefaultRole,
+		DisplayName: values["displayName"],
+		Bio:         values["bio"],
+		Locale:      values["locale"],
+		Timezone:    values["timezone"],
+	}
+	if role := values["role"]; role != "" {
+		var err error
+		if u.Role, err = strconv.Atoi(role); err != nil {
+			errs = append(errs, _errors.ToGqlError(_errors.WrapField(_errors.ErrInvalidUserRole, "role", nil)))
+		}
+	}
+	activated := false
+	if value := values["activated"]; value != "" {
+		var err error
+		if activated, err = strconv.ParseBool(value); err != nil {
+			errs = append(errs, _errors.ToGqlError(_errors.WrapField(_errors.ErrInvalidPayload, "activated", nil)))
+		}
+	}
+	u.Activated = &activated
+	if !activated {
+		u.ActivationToken = uuid.New().String()
+	}
+
+	cfg := validation.NewConfig()
+	cfg.PasswordPolicy = &ucase.passwordPolicy
+	hashed := u.Password == "" && values["passwordHash"] != ""
+	if hashed {
+		cfg.Password = false
+		u.Password = values["passwordHash"]
+		if !hasher.Default().Recognizes(u.Password) {
+			errs = append(errs, _errors.ToGqlError(_errors.WrapField(_errors.ErrPasswordHash, "passwordHash", nil)))
+		}
+	}
+	if err := cfg.Validate(*u); err != nil {
+		errs = append(errs, err.(gqlerror.List)...)
+	}
+	if len(errs) > 0 {
+		return importedUser{}, errs
+	}
+	if !hashed {
+		if err := breach.Validate(ctx, ucase.breachChecker, u.Password); err != nil {
+			return importedUser{}, err
+		}
+	}
+	u.Canonicalize()
+	return importedUser{u, row, hashed}, nil
+}
+
+// importedIdentities are the canonical logins and emails of the previous rows of the imported file.
+type importedIdentities struct {
+	logins map[string]bool
+	emails map[string]bool
+}
+
+// checkImportedIdentities reports the logins and emails used by the previous rows or by the existing users.
+func (ucase *usecase) checkImportedIdentities(ctx context.Context, result *models.UserImport, seen importedIdentities, users []importedUser) error {
+	logins := make([]string, len(users))
+	emails := make([]string, len(users))
+	for i, u := range users {
+		logins[i], emails[i] = u.Login, u.Email
+	}
+	existing, err := ucase.userRepo.GetByIdentities(ctx, logins, emails)
+	if err != nil {
+		return err
+	}
+	takenLogins := map[string]bool{}
+	takenEmails := map[string]bool{}
+	for _, u := range existing {
+		takenLogins[identity.Canonical(u.Login)] = true
+		takenEmails[identity.Canonical(u.Email)] = true
+	}
+	for _, u := range users {
+		if takenLogins[u.LoginCanonical] || seen.logins[u.LoginCanonical] {
+			addImportErrors(result, u.row, _errors.WrapField(_errors.ErrLoginMustBeUnique, "login", nil))
+		}
+		if takenEmails[u.EmailCanonical] || seen.emails[u.EmailCanonical] {
+			addImportErrors(result, u.row, _errors.WrapField(_errors.ErrEmailMustBeUnique, "email", nil))
+		}
+		seen.logins[u.LoginCanonical] = true
+		seen.emails[u.EmailCanonical] = true
+	}
+	return nil
+}
+
+// hashPasswords hashes the plain passwords in parallel, the hashes are slow on purpose.
+// It stops when ctx is canceled, e.g. when the upload is interrupted.
+func hashPasswords(ctx context.Context, users []importedUser) error {
+	h := hasher.Default()
+	indexes := make(chan int)
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		defer close(indexes)
+		for i := range users {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	for i := 0; i < runtime.NumCPU(); i++ {
+		g.Go(func() error {
+			for i := range indexes {
+				// the hashes imported from the previous system
+				if users[i].hashed {
+					continue
+				}
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				hashed, err := h.Hash(users[i].Password)
+				if err != nil {
+					return err
+				}
+				users[i].Password = hashed
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+func addImportErrors(result *models.UserImport, row int, err error) {
+	errs, ok := err.(gqlerror.List)
+	if !ok {
+		errs = gqlerror.List{_errors.ToGqlError(err)}
+	}
+	for _, e := range errs {
+		field, _ := e.Extensions["field"].(string)
+		result.Errors = append(result.Errors, &models.UserImportError{
+			Row:    row,
+			Field:  field,
+			Code:   _errors.Code(e),
+			Params: _errors.GetParams(e),
+		})
+	}
+}
+
+// csvRows reads the header, the names of the fields are case-insensitive.
+func csvRows(r io.Reader) (func() (importRow, error), error) {
+	reader := csv.NewReader(skipBOM(r))
+	header, err := reader.Read()
+	if err != nil {
+		return nil, _errors.WrapWithParams(_errors.ErrImportFile, _errors.Params{"row": 0}, err)
+	}
+	fields := make([]string, len(header))
+	for i, name := range header {
+		if fields[i] = importField(strings.TrimSpace(name)); fields[i] == "" && !isExportedField(name) {
+			return nil, unknownImportField(name)
+		}
+	}
+	return func() (importRow, error) {
+		record, err := reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		values := importRow{}
+		for i, value := range record {
+			if fields[i] != "" {
+				values[fields[i]] = value
+			}
+		}
+		return values, nil
+	}, nil
+}
+
+// jsonRows reads either an array of objects or objects separated by white space, e.g. JSON lines.
+func jsonRows(r io.Reader) func() (importRow, error) {
+	reader := skipBOM(r)
+	decoder := json.NewDecoder(reader)
+	started, array := false, false
+	return func() (importRow, error) {
+		if !started {
+			started = true
+			if b, err := peekNonSpace(reader); err == nil && b == '[' {
+				array = true
+				if _, err := decoder.Token(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if array && !decoder.More() {
+			return nil, io.EOF
+		}
+		object := map[string]interface{}{}
+		if err := decoder.Decode(&object); err != nil {
+			return nil, err
+		}
+		values := importRow{}
+		var errs gqlerror.List
+		for name, value := range object {
+			field := importField(name)
+			if field == "" {
+				if !isExportedField(name) {
+					errs = append(errs, _errors.ToGqlError(unknownImportField(name)))
+				}
+				continue
+			}
+			switch value := value.(type) {
+			case nil:
+			case string:
+				values[field] = value
+			case float64:
+				values[field] = strconv.FormatFloat(value, 'f', -1, 64)
+			case bool:
+				values[field] = strconv.FormatBool(value)
+			default:
+				errs = append(errs, _errors.ToGqlError(_errors.WrapField(_errors.ErrInvalidPayload, field, nil)))
+			}
+		}
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		return values, nil
+	}
+}
+
+func importField(name string) string {
+	for _, field := range importFields {
+		if strings.EqualFold(field, name) {
+			return field
+		}
+	}
+	return ""
+}
+
+func isExportedField(name string) bool {
+	for _, field := range exportedFields {
+		if strings.EqualFold(field, strings.TrimSpace(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+func unknownImportField(name string) error {
+	return _errors.WrapWithParams(_errors.ErrUnknownImportField, _errors.Params{
+		"name":   name,
+		"fields": strings.Join(importFields, ", "),
+	})
+}
+
+// skipBOM drops the byte order mark which spreadsheets put at the beginning of the files.
+func skipBOM(r io.Reader) *bufio.Reader {
+	reader := bufio.NewReader(r)
+	if b, err := reader.Peek(3); err == nil && string(b) == "\xef\xbb\xbf" {
+		reader.Discard(3)
+	}
+	return reader
+}
+
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for i := 1; ; i++ {
+		b, err := reader.Peek(i)
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(rune(b[i-1])) {
+			return b[i-1], nil
+		}
+	}
+}
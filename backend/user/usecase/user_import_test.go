@@ -0,0 +1,195 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	_errors "backend/errors"
+	"backend/hasher"
+	"backend/models"
+	"backend/user"
+	"backend/user/repository"
+	"backend/utils/testutil"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	newUcase := func() (user.Usecase, user.Repository) {
+		repo := repository.NewMemoryUserRepository()
+		activated := true
+		require.Equal(t, nil, repo.Store(ctx, &models.User{Login: "existing", Password: "Password123", Email: "existing@example.com", Role: 1, Activated: &activated}))
+		return NewUserUsecase(Config{UserRepo: repo}), repo
+	}
+	codes := func(result *models.UserImport) []string {
+		codes := []string{}
+		for _, e := range result.Errors {
+			codes = append(codes, e.Code)
+		}
+		return codes
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		ucase, repo := newUcase()
+		hash, err := hasher.NewBcrypt(bcrypt.MinCost).Hash("Legacy123")
+		require.Equal(t, nil, err)
+		file := "\xef\xbb\xbfLogin,email,password,passwordHash,role,activated,bio,id\n" +
+			"john,john@example.com,Password123,,2,true,\"multi\nline\",7\n" +
+			"jane,jane@example.com,," + hash + ",,,,\n"
+
+		result, err := ucase.Import(ctx, strings.NewReader(file), models.UserFileFormatCSV, false)
+		require.Equal(t, nil, err)
+		require.Equal(t, []string{}, codes(result))
+		require.Equal(t, 2, result.Imported)
+
+		john, err := repo.GetByCredentials(ctx, "john", "Password123")
+		require.Equal(t, nil, err)
+		require.Equal(t, models.UserAdminRole, john.Role)
+		require.Equal(t, true, *john.Activated)
+		require.Equal(t, "multi\nline", john.Bio)
+		jane, err := repo.GetByCredentials(ctx, "JANE", "Legacy123")
+		require.Equal(t, nil, err)
+		require.Equal(t, models.UserDefaultRole, jane.Role)
+		require.Equal(t, false, *jane.Activated)
+		// the activation can't be done without the token
+		require.NotEqual(t, "", jane.ActivationToken)
+		require.Equal(t, "", john.ActivationToken)
+	})
+
+	t.Run("Plain passwords which look like hashes", func(t *testing.T) {
+		ucase, repo := newUcase()
+		hash, err := hasher.NewBcrypt(bcrypt.MinCost).Hash("Legacy123")
+		require.Equal(t, nil, err)
+		file := "login,email,password\njohn,john@example.com," + hash + "\n"
+
+		result, err := ucase.Import(ctx, strings.NewReader(file), models.UserFileFormatCSV, false)
+		require.Equal(t, nil, err)
+		require.Equal(t, 1, result.Imported)
+		_, err = repo.GetByCredentials(ctx, "john", "Legacy123")
+		require.Equal(t, _errors.ErrInvalidCredentials, _errors.Code(_errors.ToGqlError(err)))
+		_, err = repo.GetByCredentials(ctx, "john", hash)
+		require.Equal(t, nil, err)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		ucase, repo := newUcase()
+		file := `[
+			{"login": "john", "email": "john@example.com", "password": "Password123"},
+			{"login": "Existing", "email": "other@example.com", "password": "Password123"},
+			{"login": "John", "email": "john2@example.com", "password": "Password123"},
+			{"login": "jane", "email": "jane@example.com", "password": "short", "role": 5},
+			{"login": "jim", "email": "jim@example.com", "passwordHash": "plain"},
+			{"login": "joe", "email": "joe@example.com", "password": "Password123", "unknown": 1}
+		]`
+
+		result, err := ucase.Import(ctx, strings.NewReader(file), models.UserFileFormatJSON, false)
+		require.Equal(t, nil, err)
+		require.Equal(t, 0, result.Imported)
+		rows := map[int][]string{}
+		for _, e := range result.Errors {
+			rows[e.Row] = append(rows[e.Row], e.Field)
+		}
+		require.Equal(t, map[int][]string{2: {"login"}, 3: {"login"}, 4: {"password", "role"}, 5: {"passwordHash"}, 6: {""}}, sortFields(rows))
+		require.Contains(t, codes(result), _errors.ErrUnknownImportField)
+
+		// nothing is imported when a row fails
+		_, err = repo.GetByEmail(ctx, "john@example.com")
+		require.Equal(t, _errors.ErrUserNotFound, _errors.Code(_errors.ToGqlError(err)))
+
+		_, err = ucase.Import(ctx, strings.NewReader("login,phone\n"), models.UserFileFormatCSV, false)
+		require.Equal(t, _errors.ErrUnknownImportField, _errors.Code(_errors.ToGqlError(err)))
+	})
+
+	t.Run("Chunks", func(t *testing.T) {
+		defer func(size int) { importChunkSize = size }(importChunkSize)
+		importChunkSize = 2
+		repo := repository.NewMemoryUserRepository()
+		tx := &stubTransactor{}
+		ucase := NewUserUsecase(Config{UserRepo: repo, Transactor: tx})
+		file := ""
+		for i := 1; i <= 5; i++ {
+			file += fmt.Sprintf("{\"login\": \"user%d\", \"email\": \"user%d@example.com\", \"password\": \"Password123\"}\n", i, i)
+		}
+
+		result, err := ucase.Import(ctx, strings.NewReader(file), models.UserFileFormatJSON, false)
+		require.Equal(t, nil, err)
+		require.Equal(t, 5, result.Imported)
+		require.Equal(t, 1, tx.calls)
+		require.Equal(t, nil, tx.err)
+		list, err := repo.Fetch(ctx, &models.UserFilter{LoginMATCH: "user%"})
+		require.Equal(t, nil, err)
+		require.Equal(t, 5, list.Total)
+
+		// the duplicate of a row of the previous chunk rolls back the transaction
+		file = "{\"login\": \"other1\", \"email\": \"other1@example.com\", \"password\": \"Password123\"}\n" +
+			"{\"login\": \"other2\", \"email\": \"other2@example.com\", \"password\": \"Password123\"}\n" +
+			"{\"login\": \"OTHER1\", \"email\": \"other3@example.com\", \"password\": \"Password123\"}\n"
+		result, err = ucase.Import(ctx, strings.NewReader(file), models.UserFileFormatJSON, false)
+		require.Equal(t, nil, err)
+		require.Equal(t, 0, result.Imported)
+		require.Equal(t, 1, len(result.Errors))
+		require.Equal(t, 3, result.Errors[0].Row)
+		require.Equal(t, "login", result.Errors[0].Field)
+		require.Equal(t, errImportRejected, tx.err)
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		ucase, repo := newUcase()
+		file := "{\"login\": \"john\", \"email\": \"john@example.com\", \"password\": \"Password123\"}\n"
+
+		result, err := ucase.Import(ctx, strings.NewReader(file), models.UserFileFormatJSON, true)
+		require.Equal(t, nil, err)
+		require.Equal(t, &models.UserImport{Imported: 1, DryRun: true, Errors: []*models.UserImportError{}}, result)
+		_, err = repo.GetByEmail(ctx, "john@example.com")
+		require.Equal(t, _errors.ErrUserNotFound, _errors.Code(_errors.ToGqlError(err)))
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		ucase, _ := newUcase()
+		buf := bytes.Buffer{}
+		require.Equal(t, nil, ucase.Export(ctx, &models.UserFilter{}, models.UserFileFormatCSV, &buf))
+
+		// the exported fields are ignored, the passwords aren't exported
+		result, err := ucase.Import(ctx, &buf, models.UserFileFormatCSV, true)
+		require.Equal(t, nil, err)
+		require.Equal(t, []string{_errors.ErrPasswordPolicy}, codes(result))
+		require.Equal(t, "password", result.Errors[0].Field)
+	})
+
+	t.Run("Canceled upload", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		users := []importedUser{{User: &models.User{Password: "Password123"}}}
+		require.Equal(t, context.Canceled, hashPasswords(ctx, users))
+		require.Equal(t, "Password123", users[0].Password)
+	})
+}
+
+// stubTransactor runs the functions without a transaction, the memory repository doesn't support them.
+type stubTransactor struct {
+	calls int
+	err   error
+}
+
+func (tx *stubTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx.calls++
+	tx.err = fn(ctx)
+	return tx.err
+}
+
+func sortFields(rows map[int][]string) map[int][]string {
+	for _, fields := range rows {
+		sort.Strings(fields)
+	}
+	return rows
+}
@@ -1,26 +1,72 @@
 package usecase
 
 import (
+	"backend/avatar"
+	"backend/breach"
+	_errors "backend/errors"
 	"backend/models"
+	"backend/postgres"
+	"backend/storage"
 	"backend/user"
 	"backend/user/validation"
+	"backend/utils"
+	"bytes"
 	"context"
+	"fmt"
+	"io"
 
+	"github.com/google/uuid"
+	_bytes "github.com/labstack/gommon/bytes"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	DefaultLimit = 100
+)
+
 type Config struct {
-	UserRepo user.Repository
+	UserRepo   user.Repository
+	UserEvents user.Events
+	// MaxLimit caps UserFilter.Limit, 0 means no cap.
+	MaxLimit int
+	// Storage keeps the avatars.
+	Storage storage.Storage
+	// MaxAvatarSize is the maximum size of the uploaded avatar in bytes, 0 means no limit.
+	MaxAvatarSize int64
+	// PasswordPolicy is validation.DefaultPasswordPolicy when nil.
+	PasswordPolicy *validation.PasswordPolicy
+	// BreachChecker rejects the breached passwords, it is optional.
+	BreachChecker breach.Checker
+	// Transactor makes the updates and the published events atomic, it is optional.
+	Transactor postgres.Transactor
 }
 
 type usecase struct {
-	userRepo user.Repository
-	logrus   *logrus.Entry
+	userRepo       user.Repository
+	userEvents     user.Events
+	maxLimit       int
+	storage        storage.Storage
+	maxAvatarSize  int64
+	passwordPolicy validation.PasswordPolicy
+	breachChecker  breach.Checker
+	transactor     postgres.Transactor
+	logrus         *logrus.Entry
 }
 
 func NewUserUsecase(cfg Config) user.Usecase {
+	passwordPolicy := validation.DefaultPasswordPolicy()
+	if cfg.PasswordPolicy != nil {
+		passwordPolicy = *cfg.PasswordPolicy
+	}
 	return &usecase{
 		cfg.UserRepo,
+		cfg.UserEvents,
+		cfg.MaxLimit,
+		cfg.Storage,
+		cfg.MaxAvatarSize,
+		passwordPolicy,
+		cfg.BreachChecker,
+		cfg.Transactor,
 		logrus.WithField("package", "user/usecase"),
 	}
 }
@@ -28,10 +74,9 @@ func NewUserUsecase(cfg Config) user.Usecase {
 func (ucase *usecase) Fetch(ctx context.Context, f *models.UserFilter) (models.UserList, error) {
 	ucase.logrus.WithField("filter", f).Debug("Fetch")
 	if f == nil {
-		f = &models.UserFilter{
-			Limit: 100,
-		}
+		f = &models.UserFilter{}
 	}
+	f.Limit = utils.NormalizeLimit(f.Limit, DefaultLimit, ucase.maxLimit)
 	return ucase.userRepo.Fetch(ctx, f)
 }
 
@@ -45,12 +90,23 @@ func (ucase *usecase) GetBySlug(ctx context.Context, slug string) (*models.User,
 	return ucase.userRepo.GetBySlug(ctx, slug)
 }
 
+func (ucase *usecase) GetByIDs(ctx context.Context, ids []int) ([]*models.User, error) {
+	ucase.logrus.WithField("ids", ids).Debug("GetByIDs")
+	return ucase.userRepo.GetByIDs(ctx, ids)
+}
+
+func (ucase *usecase) GetBySlugs(ctx context.Context, slugs []string) ([]*models.User, error) {
+	ucase.logrus.WithField("slugs", slugs).Debug("GetBySlugs")
+	return ucase.userRepo.GetBySlugs(ctx, slugs)
+}
+
 func (ucase *usecase) Update(ctx context.Context, id int, input models.UserInput) (*models.User, error) {
 	entry := ucase.logrus.WithField("id", id).WithField("input", input)
 	entry.Debug("Update")
 	user := input.ToUser()
 	user.ID = id
 	cfg := validation.NewConfig()
+	cfg.PasswordPolicy = &ucase.passwordPolicy
 	if user.Login == "" {
 		cfg.Login = false
 	}
@@ -63,27 +119,184 @@ func (ucase *usecase) Update(ctx context.Context, id int, input models.UserInput
 	if user.Email == "" {
 		cfg.Email = false
 	}
-	if err := cfg.Validate(user); err != nil {
+	checked := user
+	if user.Password != "" && (user.Login == "" || user.Email == "") {
+		// the password must not contain the login or the email which the user keeps
+		stored, err := ucase.userRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if checked.Login == "" {
+			checked.Login = stored.Login
+		}
+		if checked.Email == "" {
+			checked.Email = stored.Email
+		}
+	}
+	if err := cfg.Validate(checked); err != nil {
 		entry.Debugf("Update - Validation error: %s", err.Error())
 		return nil, err
 	}
-	if err := ucase.userRepo.Update(ctx, &user); err != nil {
+	if err := breach.Validate(ctx, ucase.breachChecker, user.Password); err != nil {
+		entry.Debugf("Update - Validation error: %s", err.Error())
+		return nil, err
+	}
+	if user.Password != "" {
+		if err := user.SetPassword(user.Password); err != nil {
+			return nil, err
+		}
+	}
+	if err := ucase.withinTx(ctx, func(ctx context.Context) error {
+		var before *models.User
+		if ucase.userEvents != nil {
+			var err error
+			if before, err = ucase.userRepo.GetByID(ctx, id); err != nil {
+				return err
+			}
+		}
+		if err := ucase.userRepo.Update(ctx, &user); err != nil {
+			return err
+		}
+		if before != nil {
+			ucase.publishUpdateEvents(ctx, before, &user, input.Password != "")
+		}
+		return nil
+	}); err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
+// UpdateProfile updates the fields which users can change themselves.
+func (ucase *usecase) UpdateProfile(ctx context.Context, id int, input models.ProfileInput) (*models.User, error) {
+	entry := ucase.logrus.WithField("id", id).WithField("input", input)
+	entry.Debug("UpdateProfile")
+	user := input.ToUser()
+	user.ID = id
+	cfg := validation.Config{
+		Login:       input.Login != nil,
+		DisplayName: input.DisplayName != nil,
+		Bio:         input.Bio != nil,
+		Locale:      input.Locale != nil,
+		Timezone:    input.Timezone != nil,
+	}
+	if err := cfg.Validate(user); err != nil {
+		entry.Debugf("UpdateProfile - Validation error: %s", err.Error())
+		return nil, err
+	}
+	columns := input.Columns()
+	if len(columns) == 0 {
+		return ucase.GetByID(ctx, id)
+	}
+	if err := ucase.userRepo.UpdateColumns(ctx, &user, columns...); err != nil {
+		return nil, err
+	}
+	if ucase.userEvents != nil {
+		if err := ucase.userEvents.PublishUserUpdated(ctx, &user); err != nil {
+			entry.Debugf("UpdateProfile - Cannot publish event: %s", err.Error())
+		}
+	}
+	return &user, nil
+}
+
+func (ucase *usecase) UploadAvatar(ctx context.Context, id int, file io.Reader, size int64) (*models.User, error) {
+	entry := ucase.logrus.WithField("id", id).WithField("size", size)
+	entry.Debug("UploadAvatar")
+	if ucase.storage == nil {
+		return nil, _errors.Wrap(_errors.ErrInternalServerError, fmt.Errorf("storage is not configured"))
+	}
+	tooLarge := _errors.WrapField(_errors.ErrAvatarTooLarge, "file", _errors.Params{
+		"maxSize": _bytes.Format(ucase.maxAvatarSize),
+	})
+	if ucase.maxAvatarSize > 0 {
+		if size > ucase.maxAvatarSize {
+			return nil, tooLarge
+		}
+		// the declared size may be wrong
+		file = io.LimitReader(file, ucase.maxAvatarSize+1)
+	}
+	counter := &countingReader{r: file}
+	images, err := avatar.Process(counter)
+	if ucase.maxAvatarSize > 0 && counter.n > ucase.maxAvatarSize {
+		return nil, tooLarge
+	}
+	switch err {
+	case nil:
+	case avatar.ErrUnsupportedFormat:
+		return nil, _errors.WrapField(_errors.ErrUnsupportedImage, "file", nil, err)
+	case avatar.ErrTooManyPixels:
+		return nil, _errors.WrapField(_errors.ErrAvatarDimensions, "file", nil, err)
+	default:
+		return nil, _errors.Wrap(_errors.ErrInternalServerError, err)
+	}
+
+	before, err := ucase.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// every upload gets a new prefix, so the avatars can be cached forever
+	prefix := fmt.Sprintf("avatars/%d/%s", id, uuid.New().String())
+	for size, content := range images {
+		if err := ucase.storage.Put(ctx, avatar.Key(prefix, size), bytes.NewReader(content), int64(len(content)), avatar.ContentType); err != nil {
+			ucase.deleteAvatar(ctx, prefix)
+			return nil, _errors.Wrap(_errors.ErrInternalServerError, err)
+		}
+	}
+	u := &models.User{ID: id, Avatar: prefix}
+	if err := ucase.userRepo.UpdateColumns(ctx, u, "avatar"); err != nil {
+		ucase.deleteAvatar(ctx, prefix)
+		return nil, err
+	}
+	if before.Avatar != "" {
+		ucase.deleteAvatar(ctx, before.Avatar)
+	}
+	if ucase.userEvents != nil {
+		if err := ucase.userEvents.PublishUserUpdated(ctx, u); err != nil {
+			entry.Debugf("UploadAvatar - Cannot publish event: %s", err.Error())
+		}
+	}
+	return u, nil
+}
+
+func (ucase *usecase) deleteAvatar(ctx context.Context, prefix string) {
+	if err := ucase.storage.Delete(ctx, prefix); err != nil {
+		ucase.logrus.WithField("prefix", prefix).Errorf("Cannot delete avatar: %s", err.Error())
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
 func (ucase *usecase) Store(ctx context.Context, input models.UserInput) (*models.User, error) {
 	entry := ucase.logrus.WithField("input", input)
 	entry.Debug("Store")
 	user := input.ToUser()
-	if err := validation.NewConfig().Validate(user); err != nil {
+	cfg := validation.NewConfig()
+	cfg.PasswordPolicy = &ucase.passwordPolicy
+	if err := cfg.Validate(user); err != nil {
+		entry.Debugf("Store - Validation error: %s", err.Error())
+		return nil, err
+	}
+	if err := breach.Validate(ctx, ucase.breachChecker, user.Password); err != nil {
 		entry.Debugf("Store - Validation error: %s", err.Error())
 		return nil, err
 	}
 	if err := ucase.userRepo.Store(ctx, &user); err != nil {
 		return nil, err
 	}
+	if ucase.userEvents != nil {
+		if err := ucase.userEvents.PublishUserCreated(ctx, &user); err != nil {
+			entry.Debugf("Store - Cannot publish event: %s", err.Error())
+		}
+	}
 	return &user, nil
 }
 
@@ -93,9 +306,81 @@ func (ucase *usecase) Delete(ctx context.Context, ids ...int) ([]*models.User, e
 	f := &models.UserFilter{
 		ID: ids,
 	}
-	users, err := ucase.userRepo.Delete(ctx, f)
-	if err != nil {
+	var users []*models.User
+	if err := ucase.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if users, err = ucase.userRepo.Delete(ctx, f); err != nil {
+			return err
+		}
+		for _, u := range users {
+			ucase.publishAccountChanged(ctx, u, models.AccountEventTypeLoggedOut)
+		}
+		return nil
+	}); err != nil {
 		return nil, err
 	}
 	return users, nil
 }
+
+func (ucase *usecase) CheckPasswordStrength(ctx context.Context, password, login, email string) (*models.PasswordStrength, error) {
+	ucase.logrus.WithField("login", login).WithField("email", email).Debug("CheckPasswordStrength")
+	u := models.User{Login: login, Email: email, Password: password}
+	strength := ucase.passwordPolicy.Strength(password, u)
+	result := &models.PasswordStrength{
+		Score:      strength.Score,
+		Entropy:    strength.Entropy,
+		Violations: []*models.PasswordViolation{},
+	}
+	errs := ucase.passwordPolicy.Validate(u)
+	if err := breach.Validate(ctx, ucase.breachChecker, password); err != nil {
+		gqlErr := _errors.ToGqlError(err)
+		if _errors.Code(gqlErr) != _errors.ErrPasswordBreached {
+			return nil, err
+		}
+		errs = append(errs, gqlErr)
+	}
+	for _, err := range errs {
+		result.Violations = append(result.Violations, &models.PasswordViolation{
+			Code:   _errors.Code(err),
+			Params: _errors.GetParams(err),
+		})
+	}
+	result.Valid = len(result.Violations) == 0
+	return result, nil
+}
+
+func (ucase *usecase) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ucase.transactor == nil {
+		return fn(ctx)
+	}
+	return ucase.transactor.WithinTx(ctx, fn)
+}
+
+func (ucase *usecase) publishUpdateEvents(ctx context.Context, before, after *models.User, passwordChanged bool) {
+	if err := ucase.userEvents.PublishUserUpdated(ctx, after); err != nil {
+		ucase.logrus.WithField("id", after.ID).Debugf("Cannot publish user updated event: %s", err.Error())
+	}
+	wasActivated := before.Activated != nil && *before.Activated
+	isActivated := after.Activated != nil && *after.Activated
+	if !wasActivated && isActivated {
+		ucase.publishAccountChanged(ctx, after, models.AccountEventTypeActivated)
+	}
+	if before.Role != after.Role {
+		ucase.publishAccountChanged(ctx, after, models.AccountEventTypeRoleChanged)
+	}
+	if passwordChanged || (wasActivated && !isActivated) {
+		ucase.publishAccountChanged(ctx, after, models.AccountEventTypeLoggedOut)
+	}
+}
+
+func (ucase *usecase) publishAccountChanged(ctx context.Context, u *models.User, t models.AccountEventType) {
+	if ucase.userEvents == nil {
+		return
+	}
+	if err := ucase.userEvents.PublishAccountChanged(ctx, &models.AccountEvent{
+		Type: t,
+		User: u,
+	}); err != nil {
+		ucase.logrus.WithField("id", u.ID).WithField("type", t).Debugf("Cannot publish account changed event: %s", err.Error())
+	}
+}
@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	_errors "backend/errors"
+	"backend/hasher"
+	"backend/models"
+	"backend/user"
+	"backend/user/repository"
+	"backend/user/validation"
+	"backend/utils/testutil"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserUsecase(t *testing.T) {
+	ctx := context.Background()
+	newUcase := func(t *testing.T) (user.Usecase, user.Repository, *models.User) {
+		repo := repository.NewMemoryUserRepository()
+		u := &models.User{Login: "john", Password: "Password123", Email: "john@example.com", Role: 1}
+		require.Equal(t, nil, repo.Store(ctx, u))
+		require.Equal(t, nil, repo.Store(ctx, &models.User{Login: "jane", Password: "Password123", Email: "jane@example.com", Role: 1}))
+		return NewUserUsecase(Config{UserRepo: repo}), repo, u
+	}
+	str := func(s string) *string {
+		return &s
+	}
+
+	t.Run("Update", func(t *testing.T) {
+		ucase, repo, u := newUcase(t)
+		updated, err := ucase.Update(ctx, u.ID, models.UserInput{Login: "johnny", Password: "Other123", Role: models.UserAdminRole})
+		require.Equal(t, nil, err)
+		require.Equal(t, "johnny", updated.Login)
+		require.Equal(t, "john@example.com", updated.Email)
+		require.Equal(t, models.UserAdminRole, updated.Role)
+
+		_, err = repo.GetByCredentials(ctx, "johnny", "Password123")
+		require.Equal(t, _errors.ErrInvalidCredentials, testutil.ErrorCode(err))
+		_, err = repo.GetByCredentials(ctx, "johnny", "Other123")
+		require.Equal(t, nil, err)
+
+		_, err = ucase.Update(ctx, u.ID, models.UserInput{Email: "invalid"})
+		require.Equal(t, _errors.ErrEmailPolicy, testutil.ErrorCode(err))
+		_, err = ucase.Update(ctx, u.ID, models.UserInput{Password: "weak"})
+		require.Equal(t, _errors.ErrPasswordPolicy, testutil.ErrorCode(err))
+		_, err = ucase.Update(ctx, u.ID, models.UserInput{Login: "Jane"})
+		require.Equal(t, _errors.ErrLoginMustBeUnique, testutil.ErrorCode(err))
+		_, err = ucase.Update(ctx, u.ID+100, models.UserInput{Login: "nobody"})
+		require.Equal(t, _errors.ErrUserNotFound, testutil.ErrorCode(err))
+	})
+
+	t.Run("Update hashes a password which looks like a hash", func(t *testing.T) {
+		ucase, repo, u := newUcase(t)
+		hash, err := hasher.NewBcrypt(bcrypt.MinCost).Hash("Other123")
+		require.Equal(t, nil, err)
+		_, err = ucase.Update(ctx, u.ID, models.UserInput{Password: hash})
+		require.Equal(t, nil, err)
+
+		_, err = repo.GetByCredentials(ctx, "john", "Other123")
+		require.NotEqual(t, nil, err)
+		_, err = repo.GetByCredentials(ctx, "john", hash)
+		require.Equal(t, nil, err)
+	})
+
+	t.Run("Update checks the password against the stored login and email", func(t *testing.T) {
+		repo := repository.NewMemoryUserRepository()
+		u := &models.User{Login: "johnny", Password: "Password123", Email: "john.smith@example.com", Role: 1}
+		require.Equal(t, nil, repo.Store(ctx, u))
+		policy := validation.DefaultPasswordPolicy()
+		policy.DisallowUserData = true
+		ucase := NewUserUsecase(Config{UserRepo: repo, PasswordPolicy: &policy})
+
+		// an admin sets only the password
+		_, err := ucase.Update(ctx, u.ID, models.UserInput{Password: "Johnny2024"})
+		require.Equal(t, _errors.ErrPasswordUserData, testutil.ErrorCode(err))
+		_, err = ucase.Update(ctx, u.ID, models.UserInput{Password: "Smith.John.2024", Login: "other"})
+		require.Equal(t, nil, err)
+		_, err = ucase.Update(ctx, u.ID, models.UserInput{Password: "John.Smith.2024", Login: "other"})
+		require.Equal(t, _errors.ErrPasswordUserData, testutil.ErrorCode(err))
+		// the new login replaces the stored one
+		_, err = ucase.Update(ctx, u.ID, models.UserInput{Password: "Johnny2024", Login: "jane"})
+		require.Equal(t, nil, err)
+		_, err = ucase.Update(ctx, u.ID+100, models.UserInput{Password: "Other123"})
+		require.Equal(t, _errors.ErrUserNotFound, testutil.ErrorCode(err))
+	})
+
+	t.Run("UpdateProfile", func(t *testing.T) {
+		ucase, repo, u := newUcase(t)
+		updated, err := ucase.UpdateProfile(ctx, u.ID, models.ProfileInput{
+			DisplayName: str("John Doe"),
+			Bio:         str("Bio"),
+			Timezone:    str("Europe/Warsaw"),
+		})
+		require.Equal(t, nil, err)
+		require.Equal(t, "John Doe", updated.DisplayName)
+		require.Equal(t, "john", updated.Login)
+
+		// the empty strings clear the fields, the omitted ones are kept
+		updated, err = ucase.UpdateProfile(ctx, u.ID, models.ProfileInput{Bio: str("")})
+		require.Equal(t, nil, err)
+		require.Equal(t, "", updated.Bio)
+		require.Equal(t, "John Doe", updated.DisplayName)
+		stored, err := repo.GetByID(ctx, u.ID)
+		require.Equal(t, nil, err)
+		require.Equal(t, "", stored.Bio)
+		require.Equal(t, "Europe/Warsaw", stored.Timezone)
+
+		updated, err = ucase.UpdateProfile(ctx, u.ID, models.ProfileInput{})
+		require.Equal(t, nil, err)
+		require.Equal(t, "John Doe", updated.DisplayName)
+
+		_, err = ucase.UpdateProfile(ctx, u.ID, models.ProfileInput{DisplayName: str("J")})
+		require.Equal(t, _errors.ErrDisplayNamePolicy, testutil.ErrorCode(err))
+		_, err = ucase.UpdateProfile(ctx, u.ID, models.ProfileInput{Timezone: str("Local")})
+		require.Equal(t, _errors.ErrInvalidTimezone, testutil.ErrorCode(err))
+		_, err = ucase.UpdateProfile(ctx, u.ID, models.ProfileInput{Login: str("jane@example.com")})
+		require.Equal(t, _errors.ErrLoginContainsAt, testutil.ErrorCode(err))
+		_, err = ucase.UpdateProfile(ctx, u.ID, models.ProfileInput{Login: str("JANE")})
+		require.Equal(t, _errors.ErrLoginMustBeUnique, testutil.ErrorCode(err))
+		_, err = ucase.UpdateProfile(ctx, u.ID+100, models.ProfileInput{Bio: str("Bio")})
+		require.Equal(t, _errors.ErrUserNotFound, testutil.ErrorCode(err))
+	})
+}
@@ -0,0 +1,236 @@
+package validation
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	_errors "backend/errors"
+	"backend/models"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	DefaultMinimumPasswordLength = 6
+	DefaultMaximumPasswordLength = 64
+	// MaximumPasswordScore is the score of the strongest passwords.
+	MaximumPasswordScore = 4
+	// minimumUserDataLength is the length from which the login or the email cannot be a part of the password
+	minimumUserDataLength = 3
+)
+
+// PasswordPolicy describes the passwords which can be set, the lengths are counted in characters.
+type PasswordPolicy struct {
+	MinLength        int
+	MaxLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	// Denylist contains common passwords in lowercase, see LoadDenylist.
+	Denylist map[string]struct{}
+	// DisallowUserData rejects passwords which contain the login or the local part of the email.
+	DisallowUserData bool
+	// MinScore is the minimum score of Strength, from 0 to MaximumPasswordScore.
+	MinScore int
+}
+
+// DefaultPasswordPolicy is used when the policy isn't configured.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:        DefaultMinimumPasswordLength,
+		MaxLength:        DefaultMaximumPasswordLength,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+	}
+}
+
+// LoadDenylist reads the file with one password per line, empty lines and lines starting with # are skipped.
+func LoadDenylist(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	denylist := map[string]struct{}{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+	return denylist, scanner.Err()
+}
+
+// Validate returns all violations of the policy by the password of the user.
+func (p PasswordPolicy) Validate(u models.User) gqlerror.List {
+	var errs gqlerror.List
+	length := utf8.RuneCountInString(u.Password)
+	classes := passwordClasses(u.Password)
+	if length < p.MinLength || length > p.MaxLength ||
+		(p.RequireUppercase && !classes.uppercase) ||
+		(p.RequireLowercase && !classes.lowercase) ||
+		(p.RequireDigit && !classes.digit) ||
+		(p.RequireSymbol && !classes.symbol) {
+		errs = append(errs, fieldError(_errors.ErrPasswordPolicy, "password", _errors.Params{
+			"minLength": p.MinLength,
+			"maxLength": p.MaxLength,
+			"uppercase": p.RequireUppercase,
+			"lowercase": p.RequireLowercase,
+			"digit":     p.RequireDigit,
+			"symbol":    p.RequireSymbol,
+		}))
+	}
+	if p.isDenied(u.Password) {
+		errs = append(errs, fieldError(_errors.ErrPasswordTooCommon, "password", nil))
+	}
+	if p.DisallowUserData && containsUserData(u.Password, u) {
+		errs = append(errs, fieldError(_errors.ErrPasswordUserData, "password", nil))
+	}
+	if p.MinScore > 0 {
+		if score := p.Strength(u.Password, u).Score; score < p.MinScore {
+			errs = append(errs, fieldError(_errors.ErrPasswordTooWeak, "password", _errors.Params{
+				"score":    score,
+				"minScore": p.MinScore,
+			}))
+		}
+	}
+	return errs
+}
+
+// Strength is the estimated resistance of a password to guessing.
+type Strength struct {
+	// Entropy is the estimated number of bits.
+	Entropy float64
+	// Score ranges from 0 (too guessable) to MaximumPasswordScore (very unguessable).
+	Score int
+}
+
+// Strength estimates the entropy of the password from the size of the alphabet it uses and its length.
+// Repeated and sequential characters barely count, the login and the email of the user count as one character
+// and the passwords from the denylist are worthless.
+func (p PasswordPolicy) Strength(password string, u models.User) Strength {
+	if password == "" || p.isDenied(password) {
+		return Strength{}
+	}
+	lower := strings.ToLower(password)
+	for _, data := range userData(u) {
+		lower = strings.Replace(lower, data, "\x00", -1)
+	}
+
+	runes := []rune(lower)
+	effectiveLength := 0.0
+	for i, r := range runes {
+		if i > 0 && (r == runes[i-1] || r == runes[i-1]+1 || r == runes[i-1]-1) {
+			effectiveLength += 0.25
+			continue
+		}
+		effectiveLength++
+	}
+
+	classes := passwordClasses(password)
+	alphabet := 0
+	if classes.lowercase {
+		alphabet += 26
+	}
+	if classes.uppercase {
+		alphabet += 26
+	}
+	if classes.digit {
+		alphabet += 10
+	}
+	if classes.symbol {
+		alphabet += 33
+	}
+	if classes.other {
+		alphabet += 100
+	}
+	if alphabet < 2 {
+		alphabet = 2
+	}
+	entropy := effectiveLength * math.Log2(float64(alphabet))
+	return Strength{
+		Entropy: entropy,
+		Score:   score(entropy),
+	}
+}
+
+func (p PasswordPolicy) isDenied(password string) bool {
+	_, denied := p.Denylist[strings.ToLower(password)]
+	return denied
+}
+
+// score maps the entropy to the guesses an attacker needs, from online to offline attacks.
+func score(entropy float64) int {
+	switch {
+	case entropy < 28:
+		return 0
+	case entropy < 36:
+		return 1
+	case entropy < 60:
+		return 2
+	case entropy < 80:
+		return 3
+	}
+	return MaximumPasswordScore
+}
+
+type classes struct {
+	uppercase bool
+	lowercase bool
+	digit     bool
+	symbol    bool
+	// other are the letters without case, e.g. Chinese characters
+	other bool
+}
+
+func passwordClasses(password string) classes {
+	c := classes{}
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			c.uppercase = true
+		case unicode.IsLower(r):
+			c.lowercase = true
+		case unicode.IsDigit(r):
+			c.digit = true
+		case unicode.IsLetter(r):
+			c.other = true
+		default:
+			c.symbol = true
+		}
+	}
+	return c
+}
+
+// userData returns the lowercase login and local part of the email, the short ones are skipped.
+func userData(u models.User) []string {
+	data := []string{}
+	candidates := []string{u.Login}
+	if i := strings.LastIndex(u.Email, "@"); i > 0 {
+		candidates = append(candidates, u.Email[:i])
+	}
+	for _, candidate := range candidates {
+		if utf8.RuneCountInString(candidate) >= minimumUserDataLength {
+			data = append(data, strings.ToLower(candidate))
+		}
+	}
+	return data
+}
+
+func containsUserData(password string, u models.User) bool {
+	lower := strings.ToLower(password)
+	for _, data := range userData(u) {
+		if strings.Contains(lower, data) {
+			return true
+		}
+	}
+	return false
+}
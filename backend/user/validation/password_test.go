@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"testing"
+
+	_errors "backend/errors"
+	"backend/models"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func codes(errs gqlerror.List) []string {
+	result := []string{}
+	for _, err := range errs {
+		result = append(result, _errors.Code(err))
+	}
+	return result
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+
+	t.Run("classes are Unicode-aware", func(t *testing.T) {
+		require.Equal(t, 0, len(policy.Validate(models.User{Password: "ŻÓŁWIE żółwie 1"})))
+		require.Equal(t, 0, len(policy.Validate(models.User{Password: "ΣΟΦΙΑ σοφια 2"})))
+		require.Equal(t, []string{_errors.ErrPasswordPolicy}, codes(policy.Validate(models.User{Password: "zzzzzz1"})))
+		require.Equal(t, []string{_errors.ErrPasswordPolicy}, codes(policy.Validate(models.User{Password: "ŻÓŁWIE1"})))
+	})
+
+	t.Run("length is counted in characters", func(t *testing.T) {
+		require.Equal(t, 0, len(policy.Validate(models.User{Password: "Żółwi1"})))
+		policy := policy
+		policy.MinLength = 7
+		require.Equal(t, []string{_errors.ErrPasswordPolicy}, codes(policy.Validate(models.User{Password: "Żółwi1"})))
+	})
+
+	t.Run("symbols", func(t *testing.T) {
+		policy := policy
+		policy.RequireSymbol = true
+		require.Equal(t, []string{_errors.ErrPasswordPolicy}, codes(policy.Validate(models.User{Password: "Abcdef12"})))
+		require.Equal(t, 0, len(policy.Validate(models.User{Password: "Abc def12"})))
+	})
+
+	t.Run("denylist, user data and score", func(t *testing.T) {
+		policy := policy
+		policy.Denylist = map[string]struct{}{"password1": {}}
+		policy.DisallowUserData = true
+		policy.MinScore = 2
+		u := models.User{Login: "johndoe", Email: "john.smith@example.com"}
+
+		u.Password = "PassWord1"
+		require.Equal(t, []string{_errors.ErrPasswordTooCommon, _errors.ErrPasswordTooWeak}, codes(policy.Validate(u)))
+		u.Password = "JohnDoe1983"
+		require.Equal(t, []string{_errors.ErrPasswordUserData, _errors.ErrPasswordTooWeak}, codes(policy.Validate(u)))
+		u.Password = "xJohn.Smith9"
+		require.Contains(t, codes(policy.Validate(u)), _errors.ErrPasswordUserData)
+		u.Password = "Abcdef12"
+		require.Equal(t, []string{_errors.ErrPasswordTooWeak}, codes(policy.Validate(u)))
+		u.Password = "kT9vW2qLx7Rm"
+		require.Equal(t, 0, len(policy.Validate(u)))
+	})
+
+	t.Run("strength", func(t *testing.T) {
+		require.Equal(t, 0, policy.Strength("", models.User{}).Score)
+		require.Equal(t, 0, policy.Strength("aaaaaaaaaaaa", models.User{}).Score)
+		require.Equal(t, 0, policy.Strength("abcdefghijkl", models.User{}).Score)
+		weak := policy.Strength("Tr0ub4dor", models.User{})
+		strong := policy.Strength("correct horse battery staple", models.User{})
+		require.Equal(t, true, weak.Entropy < strong.Entropy)
+		require.Equal(t, MaximumPasswordScore, strong.Score)
+	})
+}
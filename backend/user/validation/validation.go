@@ -2,20 +2,25 @@ package validation
 
 import (
 	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	_errors "backend/errors"
+	_i18n "backend/i18n"
+	"backend/identity"
 	"backend/models"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 const (
-	MinimumPasswordLength = 6
-	MaximumPasswordLength = 64
-	MinimumLoginLength    = 2
-	MaximumLoginLength    = 128
-	emailRegex            = "^(((([a-zA-Z]|\\d|[!#\\$%&'\\*\\+\\-\\/=\\?\\^_`{\\|}~]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])+(\\.([a-zA-Z]|\\d|[!#\\$%&'\\*\\+\\-\\/=\\?\\^_`{\\|}~]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])+)*)|((\\x22)((((\\x20|\\x09)*(\\x0d\\x0a))?(\\x20|\\x09)+)?(([\\x01-\\x08\\x0b\\x0c\\x0e-\\x1f\\x7f]|\\x21|[\\x23-\\x5b]|[\\x5d-\\x7e]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])|(\\([\\x01-\\x09\\x0b\\x0c\\x0d-\\x7f]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}]))))*(((\\x20|\\x09)*(\\x0d\\x0a))?(\\x20|\\x09)+)?(\\x22)))@((([a-zA-Z]|\\d|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])|(([a-zA-Z]|\\d|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])([a-zA-Z]|\\d|-|\\.|_|~|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])*([a-zA-Z]|\\d|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])))\\.)+(([a-zA-Z]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])|(([a-zA-Z]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])([a-zA-Z]|\\d|-|_|~|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])*([a-zA-Z]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])))\\.?$"
-	containUppercaseRegex = "[A-ZŻŹĆĄŚĘŁÓŃ]+"
-	containLowercaseRegex = "[a-zzżźćńółęąś]+"
-	containDigitRegex     = `\d+`
+	MinimumLoginLength       = 2
+	MaximumLoginLength       = 128
+	MinimumDisplayNameLength = 2
+	MaximumDisplayNameLength = 64
+	MaximumBioLength         = 500
+	emailRegex               = "^(((([a-zA-Z]|\\d|[!#\\$%&'\\*\\+\\-\\/=\\?\\^_`{\\|}~]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])+(\\.([a-zA-Z]|\\d|[!#\\$%&'\\*\\+\\-\\/=\\?\\^_`{\\|}~]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])+)*)|((\\x22)((((\\x20|\\x09)*(\\x0d\\x0a))?(\\x20|\\x09)+)?(([\\x01-\\x08\\x0b\\x0c\\x0e-\\x1f\\x7f]|\\x21|[\\x23-\\x5b]|[\\x5d-\\x7e]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])|(\\([\\x01-\\x09\\x0b\\x0c\\x0d-\\x7f]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}]))))*(((\\x20|\\x09)*(\\x0d\\x0a))?(\\x20|\\x09)+)?(\\x22)))@((([a-zA-Z]|\\d|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])|(([a-zA-Z]|\\d|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])([a-zA-Z]|\\d|-|\\.|_|~|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])*([a-zA-Z]|\\d|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])))\\.)+(([a-zA-Z]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])|(([a-zA-Z]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])([a-zA-Z]|\\d|-|_|~|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])*([a-zA-Z]|[\\x{00A0}-\\x{D7FF}\\x{F900}-\\x{FDCF}\\x{FDF0}-\\x{FFEF}])))\\.?$"
 )
 
 type Config struct {
@@ -23,46 +28,102 @@ type Config struct {
 	Password bool
 	Email    bool
 	Role     bool
+	Locale   bool
+	// The profile fields are optional, empty values are valid.
+	DisplayName bool
+	Bio         bool
+	Timezone    bool
+	// PasswordPolicy is DefaultPasswordPolicy when nil.
+	PasswordPolicy *PasswordPolicy
 }
 
 func NewConfig() Config {
 	return Config{
-		true, true, true, true,
+		true, true, true, true, true, true, true, true, nil,
 	}
 }
 
+// Validate returns gqlerror.List with all violations.
 func (c Config) Validate(u models.User) error {
+	var errs gqlerror.List
 	if c.Login && (len(u.Login) < MinimumLoginLength || len(u.Login) > MaximumLoginLength) {
-		return _errors.Wrap(_errors.ErrLoginPolicy)
+		errs = append(errs, fieldError(_errors.ErrLoginPolicy, "login", _errors.Params{
+			"minLength": MinimumLoginLength,
+			"maxLength": MaximumLoginLength,
+		}))
+	}
+	// the identifiers with @ sign in by the email, so a login can't take over another user's email
+	if c.Login && identity.IsEmail(u.Login) {
+		errs = append(errs, fieldError(_errors.ErrLoginContainsAt, "login", nil))
 	}
 
 	if c.Password {
-		if len(u.Password) < MinimumPasswordLength || len(u.Password) > MaximumPasswordLength {
-			return _errors.Wrap(_errors.ErrPasswordPolicy)
-		} else if matched, _ := regexp.Match(containUppercaseRegex, []byte(u.Password)); !matched {
-			return _errors.Wrap(_errors.ErrPasswordPolicy)
-
-		} else if matched, _ := regexp.Match(containLowercaseRegex, []byte(u.Password)); !matched {
-			return _errors.Wrap(_errors.ErrPasswordPolicy)
-
-		} else if matched, _ := regexp.Match(containDigitRegex, []byte(u.Password)); !matched {
-			return _errors.Wrap(_errors.ErrPasswordPolicy)
+		policy := DefaultPasswordPolicy()
+		if c.PasswordPolicy != nil {
+			policy = *c.PasswordPolicy
 		}
+		errs = append(errs, policy.Validate(u)...)
 	}
 
 	if c.Email {
 		if u.Email == "" {
-			return _errors.Wrap(_errors.ErrEmailPolicy)
+			errs = append(errs, fieldError(_errors.ErrEmailPolicy, "email", nil))
 		} else if matched, _ := regexp.Match(emailRegex, []byte(u.Email)); !matched {
-			return _errors.Wrap(_errors.ErrEmailPolicy)
+			errs = append(errs, fieldError(_errors.ErrEmailPolicy, "email", nil))
 		}
 	}
 
 	if c.Role {
 		if u.Role != models.UserDefaultRole && u.Role != models.UserAdminRole {
-			return _errors.Wrap(_errors.ErrInvalidUserRole)
+			errs = append(errs, fieldError(_errors.ErrInvalidUserRole, "role", nil))
+		}
+	}
+
+	if c.Locale && u.Locale != "" && !_i18n.IsSupported(u.Locale) {
+		locales := []string{}
+		for _, tag := range _i18n.Bundle.LanguageTags() {
+			locales = append(locales, tag.String())
+		}
+		errs = append(errs, fieldError(_errors.ErrUnsupportedLocale, "locale", _errors.Params{
+			"locales": strings.Join(locales, ", "),
+		}))
+	}
+
+	if c.DisplayName && u.DisplayName != "" {
+		length := utf8.RuneCountInString(u.DisplayName)
+		if length < MinimumDisplayNameLength || length > MaximumDisplayNameLength {
+			errs = append(errs, fieldError(_errors.ErrDisplayNamePolicy, "displayName", _errors.Params{
+				"minLength": MinimumDisplayNameLength,
+				"maxLength": MaximumDisplayNameLength,
+			}))
 		}
 	}
 
+	if c.Bio && utf8.RuneCountInString(u.Bio) > MaximumBioLength {
+		errs = append(errs, fieldError(_errors.ErrBioPolicy, "bio", _errors.Params{
+			"maxLength": MaximumBioLength,
+		}))
+	}
+
+	if c.Timezone && u.Timezone != "" && !isValidTimezone(u.Timezone) {
+		errs = append(errs, fieldError(_errors.ErrInvalidTimezone, "timezone", nil))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
+
+// isValidTimezone accepts only IANA names, "Local" depends on the server.
+func isValidTimezone(name string) bool {
+	if name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+func fieldError(code, field string, params _errors.Params) *gqlerror.Error {
+	return _errors.ToGqlError(_errors.WrapField(code, field, params))
+}
@@ -8,6 +8,7 @@ import (
 	"backend/utils/seed"
 
 	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 func TestValidate(t *testing.T) {
@@ -33,6 +34,13 @@ func TestValidate(t *testing.T) {
 			err := cfg.Validate(copy)
 			require.Equal(t, true, strings.Contains(err.Error(), _errors.ErrLoginPolicy))
 		})
+		t.Run("login contains @", func(t *testing.T) {
+			for _, login := range []string{"victim@example.com", "victim＠example.com"} {
+				copy.Login = login
+				err := cfg.Validate(copy)
+				require.Equal(t, true, strings.Contains(err.Error(), _errors.ErrLoginContainsAt), login)
+			}
+		})
 	})
 
 	t.Run("password policy test", func(t *testing.T) {
@@ -43,7 +51,7 @@ func TestValidate(t *testing.T) {
 			require.Equal(t, true, strings.Contains(err.Error(), _errors.ErrPasswordPolicy))
 		})
 		t.Run("password is too long", func(t *testing.T) {
-			for i := 0; i < MaximumPasswordLength+1; i++ {
+			for i := 0; i < DefaultMaximumPasswordLength+1; i++ {
 				copy.Password += "s"
 			}
 			err := cfg.Validate(copy)
@@ -80,10 +88,49 @@ func TestValidate(t *testing.T) {
 		})
 	})
 
+	t.Run("all violations are returned at once", func(t *testing.T) {
+		copy := u
+		copy.Login = "a"
+		copy.Email = "asdsd"
+		err := cfg.Validate(copy)
+		list, ok := err.(gqlerror.List)
+		require.Equal(t, true, ok)
+		require.Equal(t, 2, len(list))
+		require.Equal(t, _errors.ErrLoginPolicy, _errors.Code(list[0]))
+		require.Equal(t, "login", list[0].Extensions["field"])
+		require.Equal(t, _errors.ErrEmailPolicy, _errors.Code(list[1]))
+		require.Equal(t, "email", list[1].Extensions["field"])
+	})
+
 	t.Run("role is invalid", func(t *testing.T) {
 		copy := u
 		copy.Role = 125
 		err := cfg.Validate(copy)
 		require.Equal(t, true, strings.Contains(err.Error(), _errors.ErrInvalidUserRole))
 	})
+
+	t.Run("profile", func(t *testing.T) {
+		copy := u
+		copy.DisplayName = "Żółw"
+		copy.Bio = strings.Repeat("ż", MaximumBioLength)
+		copy.Timezone = "Europe/Warsaw"
+		require.Equal(t, nil, cfg.Validate(copy))
+		copy.DisplayName = "a"
+		copy.Bio += "a"
+		copy.Timezone = "Local"
+		list := cfg.Validate(copy).(gqlerror.List)
+		require.Equal(t, 3, len(list))
+		require.Equal(t, _errors.ErrDisplayNamePolicy, _errors.Code(list[0]))
+		require.Equal(t, _errors.ErrBioPolicy, _errors.Code(list[1]))
+		require.Equal(t, _errors.ErrInvalidTimezone, _errors.Code(list[2]))
+	})
+
+	t.Run("locale", func(t *testing.T) {
+		copy := u
+		copy.Locale = "en"
+		require.Equal(t, nil, cfg.Validate(copy))
+		copy.Locale = "de"
+		err := cfg.Validate(copy)
+		require.Equal(t, true, strings.Contains(err.Error(), _errors.ErrUnsupportedLocale))
+	})
 }
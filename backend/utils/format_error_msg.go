@@ -2,48 +2,85 @@ package utils
 
 import (
 	"backend/errors"
+	_i18n "backend/i18n"
 	"backend/middleware"
-	"backend/user/validation"
 	"context"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
+// FormatErrorMsg translates the error to the language of the request and hides its internal details.
+// Every error of gqlerror.List is sent to the client, the first one is returned.
 func FormatErrorMsg(ctx context.Context, err2 error) error {
+	localizer := localizerFromContext(ctx)
+	if list, ok := err2.(gqlerror.List); ok && len(list) > 0 {
+		for _, e := range list[1:] {
+			graphql.AddError(ctx, localize(localizer, e))
+		}
+		return localize(localizer, list[0])
+	}
+	return localize(localizer, errors.ToGqlError(err2))
+}
+
+// PresentError is the error presenter of the GraphQL handler. Every outgoing error goes through it, including
+// the errors which skip FormatErrorMsg, e.g. of the parser, the validation and the directives, so the internal
+// errors are never sent. The errors not created by the errors package become internal server errors.
+func PresentError(ctx context.Context, err error) *gqlerror.Error {
+	var gqlErr *gqlerror.Error
+	switch e := err.(type) {
+	case *gqlerror.Error:
+		gqlErr = e
+		if errors.HasCode(gqlErr) {
+			gqlErr = localize(localizerFromContext(ctx), gqlErr)
+		}
+	case gqlerror.List:
+		// graphql.AddError holds the lock of the response, so only the first error can be sent
+		if len(e) > 0 {
+			return PresentError(ctx, e[0])
+		}
+		gqlErr = localize(localizerFromContext(ctx), errors.ToGqlError(err))
+	default:
+		gqlErr = localize(localizerFromContext(ctx), errors.ToGqlError(err))
+	}
+	gqlErr = graphql.DefaultErrorPresenter(ctx, gqlErr)
+	errors.HideInternal(gqlErr)
+	return gqlErr
+}
+
+// Localize translates the message of the error code to the language of the request.
+func Localize(ctx context.Context, code string, params errors.Params) string {
 	localizer, err := middleware.LocalizerFromContext(ctx)
 	if err != nil {
-		return err
+		return code
 	}
-	graphqlErr := errors.ToGqlError(err2)
-	defaultMsg := &i18n.Message{
-		ID:    graphqlErr.Message,
-		One:   graphqlErr.Message,
-		Other: graphqlErr.Message,
-	}
-	switch graphqlErr.Message {
-	case errors.ErrLoginPolicy, errors.ErrDisplayNamePolicy:
-		graphqlErr.Message = localizer.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: graphqlErr.Message,
-			TemplateData: map[string]interface{}{
-				"MinLength": validation.MinimumLoginLength,
-				"MaxLength": validation.MaximumLoginLength,
-			},
-			DefaultMessage: defaultMsg,
-		})
-	case errors.ErrPasswordPolicy:
-		graphqlErr.Message = localizer.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: graphqlErr.Message,
-			TemplateData: map[string]interface{}{
-				"MinLength": validation.MinimumPasswordLength,
-				"MaxLength": validation.MaximumPasswordLength,
-			},
-			DefaultMessage: defaultMsg,
-		})
-	default:
-		graphqlErr.Message = localizer.MustLocalize(&i18n.LocalizeConfig{
-			MessageID:      graphqlErr.Message,
-			DefaultMessage: defaultMsg,
-		})
+	return translate(localizer, code, params)
+}
+
+// localizerFromContext falls back to the default language, e.g. outside of the requests.
+func localizerFromContext(ctx context.Context) *i18n.Localizer {
+	localizer, err := middleware.LocalizerFromContext(ctx)
+	if err != nil {
+		return i18n.NewLocalizer(_i18n.Bundle)
 	}
+	return localizer
+}
+
+func localize(localizer *i18n.Localizer, graphqlErr *gqlerror.Error) *gqlerror.Error {
+	graphqlErr.Message = translate(localizer, errors.Code(graphqlErr), errors.GetParams(graphqlErr))
+	errors.HideInternal(graphqlErr)
 	return graphqlErr
 }
+
+func translate(localizer *i18n.Localizer, code string, params errors.Params) string {
+	return localizer.MustLocalize(&i18n.LocalizeConfig{
+		MessageID:    code,
+		TemplateData: map[string]interface{}(params),
+		DefaultMessage: &i18n.Message{
+			ID:    code,
+			One:   code,
+			Other: code,
+		},
+	})
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"backend/errors"
+	_i18n "backend/i18n"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func TestFormatErrorMsg(t *testing.T) {
+	require.Equal(t, nil, _i18n.LoadMessageFiles("../i18n/locales"))
+	ctx := context.Background()
+
+	t.Run("Without localizer", func(t *testing.T) {
+		err := errors.ToGqlError(FormatErrorMsg(ctx, errors.Wrap(errors.ErrInternalServerError, fmt.Errorf("secret"))))
+		require.Equal(t, errors.ErrInternalServerError, errors.Code(err))
+		require.NotEqual(t, errors.ErrInternalServerError, err.Message)
+		require.Equal(t, nil, err.Extensions["internal"])
+	})
+
+	t.Run("PresentError", func(t *testing.T) {
+		err := PresentError(ctx, fmt.Errorf("secret"))
+		require.Equal(t, errors.ErrInternalServerError, errors.Code(err))
+		require.NotContains(t, err.Message, "secret")
+		require.Equal(t, nil, err.Extensions["internal"])
+
+		err = PresentError(ctx, errors.Wrap(errors.ErrUserNotFound, fmt.Errorf("secret")))
+		require.Equal(t, errors.ErrUserNotFound, errors.Code(err))
+		require.Equal(t, nil, err.Extensions["internal"])
+
+		// the errors of the parser and the validation keep their messages
+		err = PresentError(ctx, gqlerror.Errorf("Cannot query field \"x\" on type \"Query\"."))
+		require.Equal(t, "Cannot query field \"x\" on type \"Query\".", err.Message)
+	})
+}
@@ -0,0 +1,15 @@
+package utils
+
+// NormalizeLimit returns defaultLimit for a missing limit and caps the limit at maxLimit (if maxLimit > 0).
+func NormalizeLimit(limit, defaultLimit, maxLimit int) int {
+	if maxLimit > 0 && defaultLimit > maxLimit {
+		defaultLimit = maxLimit
+	}
+	if limit <= 0 {
+		return defaultLimit
+	}
+	if maxLimit > 0 && limit > maxLimit {
+		return maxLimit
+	}
+	return limit
+}
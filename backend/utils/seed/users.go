@@ -17,6 +17,7 @@ func Users(limit int) []models.User {
 		if i%3 == 0 {
 			role = models.UserAdminRole
 		}
+		activated := role == models.UserAdminRole || i%2 == 0
 		users = append(users, models.User{
 			ID:                 i + 150,
 			Slug:               fmt.Sprintf("%d-%s", i, login),
@@ -26,7 +27,7 @@ func Users(limit int) []models.User {
 			Role:               role,
 			ActivationToken:    uuid.New().String(),
 			ResetPasswordToken: uuid.New().String(),
-			Activated:          role == models.UserAdminRole || i%2 == 0,
+			Activated:          &activated,
 			CreatedAt:          time.Now(),
 			UpdatedAt:          time.Now(),
 		})
@@ -0,0 +1,49 @@
+// Package testutil contains the helpers shared by the tests of the packages.
+package testutil
+
+import (
+	"os"
+	"testing"
+
+	_errors "backend/errors"
+	"backend/hasher"
+	"backend/postgres"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Main runs the tests of the package with the bcrypt hasher at its minimal cost,
+// the default argon2id parameters are too slow for the tests. It is called by TestMain.
+func Main(m *testing.M) {
+	hasher.SetDefault(hasher.NewBcrypt(bcrypt.MinCost))
+	os.Exit(m.Run())
+}
+
+// ErrorCode returns the code of the error or of the first validation error.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	if list, ok := err.(gqlerror.List); ok {
+		return _errors.Code(list[0])
+	}
+	return _errors.Code(_errors.ToGqlError(err))
+}
+
+// Violation returns the name of the constraint, or table.column for the not-null violations, violated
+// by the postgres error wrapped in err. It is empty for the other errors.
+func Violation(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, internal := range _errors.Internal(_errors.ToGqlError(err)) {
+		if v, ok := postgres.ViolationFromError(internal); ok {
+			if v.Code == postgres.NotNullViolation {
+				return v.Table + "." + v.Column
+			}
+			return v.Constraint
+		}
+	}
+	return ""
+}